@@ -0,0 +1,394 @@
+package grpcutils
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/pkg/authorization"
+	"github.com/jucrouzet/grpcutils/pkg/concurrencylimit"
+	"github.com/jucrouzet/grpcutils/pkg/deadline"
+	"github.com/jucrouzet/grpcutils/pkg/geoip"
+	"github.com/jucrouzet/grpcutils/pkg/metrics"
+	"github.com/jucrouzet/grpcutils/pkg/ratelimit"
+	"github.com/jucrouzet/grpcutils/pkg/recovery"
+	"github.com/jucrouzet/grpcutils/pkg/remoteaddr"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/sloglogger"
+	"github.com/jucrouzet/grpcutils/pkg/tracing"
+	"github.com/jucrouzet/grpcutils/pkg/validation"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+var (
+	// ErrInvalidOptionValue is returned when trying to use an invalid option value
+	ErrInvalidOptionValue = errors.New("invalid option value")
+	// ErrMissingDependency is returned when a component requires another one which is not set
+	ErrMissingDependency = errors.New("missing dependency")
+)
+
+// Chain builds the server interceptors of grpcutils components in the order their dependencies
+// require, which is, from the outermost to the innermost :
+//
+//   - requestid, so that all components see the request correlation identifier
+//   - tracing, before requestid if it uses trace IDs as request correlation identifiers
+//   - metrics, recording all calls, even rejected ones
+//   - geoip, enriching calls before they are logged
+//   - zaplogger and sloglogger, setting the request loggers and logging all calls, even rejected ones
+//   - recovery, logging panics with the request logger
+//   - deadline, adding deadline fields to the request logger and bounding the following ones
+//   - remoteaddr filter
+//   - concurrencylimit, shedding load before calls are authorized
+//   - authorization
+//   - ratelimit, which can identify clients by their authorized principal
+//   - validation, only validating requests of authorized calls
+//   - interceptors added with WithUnaryInterceptors and WithStreamInterceptors
+//
+// As recovery is placed after metrics, geoip and the loggers so that panics are logged with the
+// request logger and recorded as codes.Internal errors, panics in these components, or in their
+// hooks and field extractors, are not recovered.
+type Chain struct {
+	requestID        bool
+	tracing          *tracing.Tracing
+	metrics          *metrics.Metrics
+	geoIP            *geoip.Enricher
+	zapLogger        *zaplogger.Logger
+	slogLogger       *sloglogger.Logger
+	recovery         *recovery.Recovery
+	deadline         *deadline.Enforcer
+	filter           *remoteaddr.Filter
+	concurrencyLimit *concurrencylimit.Limiter
+	authorization    *authorization.Authorization
+	rateLimit        *ratelimit.Limiter
+	validation       *validation.Validation
+	unary            []grpc.UnaryServerInterceptor
+	stream           []grpc.StreamServerInterceptor
+}
+
+// ChainOption is the Chain option functions type
+type ChainOption func(*Chain) error
+
+// WithRequestID adds the requestid interceptors to the chain
+func WithRequestID() ChainOption {
+	return func(c *Chain) error {
+		c.requestID = true
+		return nil
+	}
+}
+
+// WithTracing adds the tracing interceptors to the chain
+func WithTracing(t *tracing.Tracing) ChainOption {
+	return func(c *Chain) error {
+		if t == nil {
+			return errors.New("cannot use a nil tracing")
+		}
+		c.tracing = t
+		return nil
+	}
+}
+
+// WithMetrics adds the metrics interceptors to the chain
+func WithMetrics(m *metrics.Metrics) ChainOption {
+	return func(c *Chain) error {
+		if m == nil {
+			return errors.New("cannot use nil metrics")
+		}
+		c.metrics = m
+		return nil
+	}
+}
+
+// WithGeoIP adds the geoip enricher interceptors to the chain
+func WithGeoIP(enricher *geoip.Enricher) ChainOption {
+	return func(c *Chain) error {
+		if enricher == nil {
+			return errors.New("cannot use a nil geoip enricher")
+		}
+		c.geoIP = enricher
+		return nil
+	}
+}
+
+// WithZapLogger adds the zaplogger interceptors to the chain.
+// If logger adds the zaplogger.FieldRequestID field, WithRequestID is required. If it adds the
+// zaplogger.FieldGeoCountry or zaplogger.FieldASN fields, WithGeoIP is required. If it adds the
+// zaplogger.FieldTraceID or zaplogger.FieldSpanID fields, WithTracing is required.
+func WithZapLogger(logger *zaplogger.Logger) ChainOption {
+	return func(c *Chain) error {
+		if logger == nil {
+			return errors.New("cannot use a nil zap logger")
+		}
+		c.zapLogger = logger
+		return nil
+	}
+}
+
+// WithSlogLogger adds the sloglogger interceptors to the chain.
+// If logger adds the sloglogger.FieldRequestID field, WithRequestID is required. If it adds the
+// sloglogger.FieldGeoCountry or sloglogger.FieldASN fields, WithGeoIP is required. If it adds the
+// sloglogger.FieldTraceID or sloglogger.FieldSpanID fields, WithTracing is required.
+func WithSlogLogger(logger *sloglogger.Logger) ChainOption {
+	return func(c *Chain) error {
+		if logger == nil {
+			return errors.New("cannot use a nil slog logger")
+		}
+		c.slogLogger = logger
+		return nil
+	}
+}
+
+// WithRecovery adds the recovery interceptors to the chain
+func WithRecovery(r *recovery.Recovery) ChainOption {
+	return func(c *Chain) error {
+		if r == nil {
+			return errors.New("cannot use a nil recovery")
+		}
+		c.recovery = r
+		return nil
+	}
+}
+
+// WithDeadline adds the deadline enforcer interceptors to the chain
+func WithDeadline(e *deadline.Enforcer) ChainOption {
+	return func(c *Chain) error {
+		if e == nil {
+			return errors.New("cannot use a nil deadline enforcer")
+		}
+		c.deadline = e
+		return nil
+	}
+}
+
+// WithRemoteAddrFilter adds the remoteaddr filter interceptors to the chain
+func WithRemoteAddrFilter(filter *remoteaddr.Filter) ChainOption {
+	return func(c *Chain) error {
+		if filter == nil {
+			return errors.New("cannot use a nil remote address filter")
+		}
+		c.filter = filter
+		return nil
+	}
+}
+
+// WithConcurrencyLimit adds the concurrencylimit interceptors to the chain
+func WithConcurrencyLimit(limiter *concurrencylimit.Limiter) ChainOption {
+	return func(c *Chain) error {
+		if limiter == nil {
+			return errors.New("cannot use a nil concurrency limiter")
+		}
+		c.concurrencyLimit = limiter
+		return nil
+	}
+}
+
+// WithAuthorization adds the authorization interceptors to the chain
+func WithAuthorization(a *authorization.Authorization) ChainOption {
+	return func(c *Chain) error {
+		if a == nil {
+			return errors.New("cannot use a nil authorization")
+		}
+		c.authorization = a
+		return nil
+	}
+}
+
+// WithRateLimit adds the ratelimit interceptors to the chain.
+// If limiter identifies clients with ratelimit.KeyByPrincipal, WithAuthorization is required.
+func WithRateLimit(limiter *ratelimit.Limiter) ChainOption {
+	return func(c *Chain) error {
+		if limiter == nil {
+			return errors.New("cannot use a nil rate limiter")
+		}
+		c.rateLimit = limiter
+		return nil
+	}
+}
+
+// WithValidation adds the validation interceptors to the chain
+func WithValidation(v *validation.Validation) ChainOption {
+	return func(c *Chain) error {
+		if v == nil {
+			return errors.New("cannot use a nil validation")
+		}
+		c.validation = v
+		return nil
+	}
+}
+
+// WithUnaryInterceptors adds unary interceptors at the end of the chain, in the order they are
+// specified.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) ChainOption {
+	return func(c *Chain) error {
+		for _, i := range interceptors {
+			if i == nil {
+				return errors.New("cannot use a nil unary interceptor")
+			}
+		}
+		c.unary = append(c.unary, interceptors...)
+		return nil
+	}
+}
+
+// WithStreamInterceptors adds stream interceptors at the end of the chain, in the order they are
+// specified.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) ChainOption {
+	return func(c *Chain) error {
+		for _, i := range interceptors {
+			if i == nil {
+				return errors.New("cannot use a nil stream interceptor")
+			}
+		}
+		c.stream = append(c.stream, interceptors...)
+		return nil
+	}
+}
+
+// NewChain creates a new instance of Chain with specified options, checking that the dependencies
+// of its components are set.
+func NewChain(opts ...ChainOption) (*Chain, error) {
+	c := &Chain{}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// validate checks that the components required by the configured ones are set.
+func (c *Chain) validate() error {
+	type logger struct {
+		name     string
+		hasField func(field string) bool
+	}
+	var loggers []logger
+	if c.zapLogger != nil {
+		loggers = append(loggers, logger{"zaplogger", func(f string) bool { return c.zapLogger.HasField(zaplogger.Field(f)) }})
+	}
+	if c.slogLogger != nil {
+		loggers = append(loggers, logger{"sloglogger", func(f string) bool { return c.slogLogger.HasField(sloglogger.Field(f)) }})
+	}
+	for _, l := range loggers {
+		if l.hasField(zaplogger.FieldRequestID) && !c.requestID {
+			return fmt.Errorf("%w : %s request ID field requires WithRequestID", ErrMissingDependency, l.name)
+		}
+		if (l.hasField(zaplogger.FieldGeoCountry) || l.hasField(zaplogger.FieldASN)) && c.geoIP == nil {
+			return fmt.Errorf("%w : %s geoip fields require WithGeoIP", ErrMissingDependency, l.name)
+		}
+		if (l.hasField(zaplogger.FieldTraceID) || l.hasField(zaplogger.FieldSpanID)) && c.tracing == nil {
+			return fmt.Errorf("%w : %s trace fields require WithTracing", ErrMissingDependency, l.name)
+		}
+	}
+	if c.rateLimit != nil && c.rateLimit.KeysByPrincipal() && c.authorization == nil {
+		return fmt.Errorf("%w : ratelimit principal keys require WithAuthorization", ErrMissingDependency)
+	}
+	return nil
+}
+
+// UnaryInterceptors returns the unary server interceptors of the chain, in order
+func (c *Chain) UnaryInterceptors() []grpc.UnaryServerInterceptor {
+	var interceptors []grpc.UnaryServerInterceptor
+	if c.tracing != nil && c.tracing.RequestIDFromTraceID() {
+		interceptors = append(interceptors, c.tracing.UnaryInterceptor())
+	}
+	if c.requestID {
+		interceptors = append(interceptors, requestid.UnaryServerInterceptor)
+	}
+	if c.tracing != nil && !c.tracing.RequestIDFromTraceID() {
+		interceptors = append(interceptors, c.tracing.UnaryInterceptor())
+	}
+	if c.metrics != nil {
+		interceptors = append(interceptors, c.metrics.UnaryInterceptor())
+	}
+	if c.geoIP != nil {
+		interceptors = append(interceptors, c.geoIP.UnaryInterceptor())
+	}
+	if c.zapLogger != nil {
+		interceptors = append(interceptors, c.zapLogger.UnaryInterceptor())
+	}
+	if c.slogLogger != nil {
+		interceptors = append(interceptors, c.slogLogger.UnaryInterceptor())
+	}
+	if c.recovery != nil {
+		interceptors = append(interceptors, c.recovery.UnaryInterceptor())
+	}
+	if c.deadline != nil {
+		interceptors = append(interceptors, c.deadline.UnaryInterceptor())
+	}
+	if c.filter != nil {
+		interceptors = append(interceptors, c.filter.UnaryInterceptor())
+	}
+	if c.concurrencyLimit != nil {
+		interceptors = append(interceptors, c.concurrencyLimit.UnaryInterceptor())
+	}
+	if c.authorization != nil {
+		interceptors = append(interceptors, c.authorization.UnaryInterceptor())
+	}
+	if c.rateLimit != nil {
+		interceptors = append(interceptors, c.rateLimit.UnaryInterceptor())
+	}
+	if c.validation != nil {
+		interceptors = append(interceptors, c.validation.UnaryInterceptor())
+	}
+	return append(interceptors, c.unary...)
+}
+
+// StreamInterceptors returns the stream server interceptors of the chain, in order
+func (c *Chain) StreamInterceptors() []grpc.StreamServerInterceptor {
+	var interceptors []grpc.StreamServerInterceptor
+	if c.tracing != nil && c.tracing.RequestIDFromTraceID() {
+		interceptors = append(interceptors, c.tracing.StreamInterceptor())
+	}
+	if c.requestID {
+		interceptors = append(interceptors, requestid.StreamServerInterceptor)
+	}
+	if c.tracing != nil && !c.tracing.RequestIDFromTraceID() {
+		interceptors = append(interceptors, c.tracing.StreamInterceptor())
+	}
+	if c.metrics != nil {
+		interceptors = append(interceptors, c.metrics.StreamInterceptor())
+	}
+	if c.geoIP != nil {
+		interceptors = append(interceptors, c.geoIP.StreamInterceptor())
+	}
+	if c.zapLogger != nil {
+		interceptors = append(interceptors, c.zapLogger.StreamInterceptor())
+	}
+	if c.slogLogger != nil {
+		interceptors = append(interceptors, c.slogLogger.StreamInterceptor())
+	}
+	if c.recovery != nil {
+		interceptors = append(interceptors, c.recovery.StreamInterceptor())
+	}
+	if c.deadline != nil {
+		interceptors = append(interceptors, c.deadline.StreamInterceptor())
+	}
+	if c.filter != nil {
+		interceptors = append(interceptors, c.filter.StreamInterceptor())
+	}
+	if c.concurrencyLimit != nil {
+		interceptors = append(interceptors, c.concurrencyLimit.StreamInterceptor())
+	}
+	if c.authorization != nil {
+		interceptors = append(interceptors, c.authorization.StreamInterceptor())
+	}
+	if c.rateLimit != nil {
+		interceptors = append(interceptors, c.rateLimit.StreamInterceptor())
+	}
+	if c.validation != nil {
+		interceptors = append(interceptors, c.validation.StreamInterceptor())
+	}
+	return append(interceptors, c.stream...)
+}
+
+// ServerOptions returns the gRPC server options chaining the unary and stream interceptors
+func (c *Chain) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(c.UnaryInterceptors()...),
+		grpc.ChainStreamInterceptor(c.StreamInterceptors()...),
+	}
+}
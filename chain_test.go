@@ -0,0 +1,132 @@
+package grpcutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/authorization"
+	"github.com/jucrouzet/grpcutils/pkg/ratelimit"
+	"github.com/jucrouzet/grpcutils/pkg/recovery"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/sloglogger"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+type dummyChain struct {
+	foobar.UnimplementedDummyServiceServer
+}
+
+func (d *dummyChain) Foo(_ context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	panic("oops")
+}
+
+func (d *dummyChain) FooS(s foobar.DummyService_FooSServer) error {
+	for {
+		if _, err := s.Recv(); err != nil {
+			return nil
+		}
+	}
+}
+
+func TestNewChain(t *testing.T) {
+	invalids := map[string]ChainOption{
+		"WithTracing":            WithTracing(nil),
+		"WithMetrics":            WithMetrics(nil),
+		"WithGeoIP":              WithGeoIP(nil),
+		"WithZapLogger":          WithZapLogger(nil),
+		"WithSlogLogger":         WithSlogLogger(nil),
+		"WithRecovery":           WithRecovery(nil),
+		"WithDeadline":           WithDeadline(nil),
+		"WithRemoteAddrFilter":   WithRemoteAddrFilter(nil),
+		"WithConcurrencyLimit":   WithConcurrencyLimit(nil),
+		"WithAuthorization":      WithAuthorization(nil),
+		"WithRateLimit":          WithRateLimit(nil),
+		"WithValidation":         WithValidation(nil),
+		"WithUnaryInterceptors":  WithUnaryInterceptors(nil),
+		"WithStreamInterceptors": WithStreamInterceptors(nil),
+	}
+	for name, opt := range invalids {
+		c, err := NewChain(opt)
+		assert.Nil(t, c, "%s() should not return a chain with a nil value", name)
+		assert.ErrorIs(t, err, ErrInvalidOptionValue, "%s() should return a ErrInvalidOptionValue error with a nil value", name)
+	}
+
+	zl, _ := zaplogger.New(zaplogger.WithLogger(zap.NewNop()), zaplogger.WithFields(zaplogger.FieldRequestID))
+	_, err := NewChain(WithZapLogger(zl))
+	assert.ErrorIs(t, err, ErrMissingDependency, "NewChain() should return a ErrMissingDependency error with a request ID field and no requestid")
+	_, err = NewChain(WithZapLogger(zl), WithRequestID())
+	assert.Nil(t, err, "NewChain() should not return an error with a request ID field and requestid")
+
+	sl, _ := sloglogger.New(sloglogger.WithFields(sloglogger.FieldASN))
+	_, err = NewChain(WithSlogLogger(sl))
+	assert.ErrorIs(t, err, ErrMissingDependency, "NewChain() should return a ErrMissingDependency error with a geoip field and no geoip")
+
+	zl, _ = zaplogger.New(zaplogger.WithLogger(zap.NewNop()), zaplogger.WithFields(zaplogger.FieldTraceID))
+	_, err = NewChain(WithZapLogger(zl))
+	assert.ErrorIs(t, err, ErrMissingDependency, "NewChain() should return a ErrMissingDependency error with a trace field and no tracing")
+
+	rl, _ := ratelimit.New(ratelimit.WithKeyFunc(ratelimit.KeyByPrincipal(func(user string) string { return user }), ratelimit.KeyByRemoteIP))
+	_, err = NewChain(WithRateLimit(rl))
+	assert.ErrorIs(t, err, ErrMissingDependency, "NewChain() should return a ErrMissingDependency error with principal rate limit keys and no authorization")
+	a, _ := authorization.New(authorization.WithMethodFunction("user", func(_ context.Context, credential string) (any, error) {
+		return credential, nil
+	}))
+	_, err = NewChain(WithRateLimit(rl), WithAuthorization(a))
+	assert.Nil(t, err, "NewChain() should not return an error with principal rate limit keys and authorization")
+	rl, _ = ratelimit.New()
+	_, err = NewChain(WithRateLimit(rl))
+	assert.Nil(t, err, "NewChain() should not return an error with remote address rate limit keys and no authorization")
+}
+
+func TestChain_ServerOptions(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.InfoLevel)
+	zl, _ := zaplogger.New(
+		zaplogger.WithLogger(zap.New(core)),
+		zaplogger.WithFields(zaplogger.FieldRequestID),
+		zaplogger.WithAccessLog(),
+	)
+	r, _ := recovery.New()
+	var unaryHasLogger, streamHasRequestID bool
+	c, err := NewChain(
+		WithZapLogger(zl),
+		WithRecovery(r),
+		WithRequestID(),
+		WithUnaryInterceptors(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			_, err := zaplogger.GetFromContext(ctx)
+			unaryHasLogger = err == nil
+			return handler(ctx, req)
+		}),
+		WithStreamInterceptors(func(srv interface{}, s grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			streamHasRequestID = requestid.GetFromContext(s.Context()) != ""
+			return handler(srv, s)
+		}),
+	)
+	assert.Nil(t, err, "NewChain() should not return an error")
+	assert.Len(t, c.UnaryInterceptors(), 4, "UnaryInterceptors() should return an interceptor per component")
+	assert.Len(t, c.StreamInterceptors(), 4, "StreamInterceptors() should return an interceptor per component")
+
+	_, _, _, err = utils.TestCallFoo(t, &dummyChain{}, nil, c.ServerOptions())
+	assert.Equal(t, codes.Internal, status.Code(err), "panics should be recovered")
+	assert.True(t, unaryHasLogger, "added unary interceptors should be called after the logger")
+	logs := recordedLogs.FilterMessage("recovered from panic").All()
+	if assert.Len(t, logs, 1, "recovered panics should be logged") {
+		assert.NotEmpty(t, logs[0].ContextMap()[zaplogger.FieldRequestID], "recovered panics should be logged with the request logger")
+	}
+	logs = recordedLogs.FilterMessage("finished unary call").All()
+	if assert.Len(t, logs, 1, "recovered calls should be access logged") {
+		assert.Equal(t, codes.Internal.String(), logs[0].ContextMap()[zaplogger.AccessLogFieldCode], "recovered calls should be access logged with their code")
+	}
+
+	utils.TestCallFooS(t, &dummyChain{}, nil, c.ServerOptions())
+	assert.True(t, streamHasRequestID, "added stream interceptors should be called after requestid")
+}
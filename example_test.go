@@ -0,0 +1,35 @@
+package grpcutils_test
+
+import (
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils"
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/recovery"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// ExampleNewChain wires the request ID, logger and recovery interceptors in the right order
+func ExampleNewChain() {
+	l, err := zaplogger.New(
+		zaplogger.WithFields(zaplogger.FieldMethod, zaplogger.FieldRequestID),
+		zaplogger.WithAccessLog(),
+	)
+	if err != nil {
+		panic(err)
+	}
+	r, err := recovery.New()
+	if err != nil {
+		panic(err)
+	}
+	chain, err := grpcutils.NewChain(
+		grpcutils.WithRequestID(),
+		grpcutils.WithZapLogger(l),
+		grpcutils.WithRecovery(r),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(chain.ServerOptions()...)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
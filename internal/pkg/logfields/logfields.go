@@ -0,0 +1,270 @@
+// Package logfields extracts the fields of a gRPC call added to request loggers, independently of
+// the logging library, so that zaplogger and sloglogger stay consistent.
+package logfields
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jucrouzet/grpcutils/pkg/geoip"
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+	"github.com/jucrouzet/grpcutils/pkg/remoteaddr"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+)
+
+const (
+	// ServerName is the key of the server name field
+	ServerName = "server_name"
+	// ServerType is the key of the server type field
+	ServerType = "server_type"
+	// RemoteAddr is the key of the caller remote address field
+	RemoteAddr = "remote_addr"
+	// Method is the key of the called method field
+	Method = "method"
+	// RequestID is the key of the request correlation identifier field
+	RequestID = "requestid"
+	// GeoCountry is the key of the caller remote address country field
+	GeoCountry = "geo_country"
+	// ASN is the key of the caller remote address autonomous system number field
+	ASN = "asn"
+	// TraceID is the key of the call OpenTelemetry trace ID field
+	TraceID = "trace_id"
+	// SpanID is the key of the call OpenTelemetry span ID field
+	SpanID = "span_id"
+	// Attempt is the key of the call attempt number field
+	Attempt = "attempt"
+)
+
+// AttemptMetadataName is the name of the metadata holding the attempt number, starting at 1, of a
+// retried call
+const AttemptMetadataName = "x-attempt"
+
+// FailurePolicy is the behaviour when the value of a field is unavailable
+type FailurePolicy int
+
+const (
+	// FailureSkip omits the field
+	FailureSkip FailurePolicy = iota
+	// FailurePlaceholder uses a placeholder value for the field
+	FailurePlaceholder
+	// FailureFail fails the extraction
+	FailureFail
+)
+
+// DefaultPlaceholder is the placeholder value of unavailable fields when none is configured
+const DefaultPlaceholder = "unknown"
+
+// ErrUnavailable is returned when a field value is unavailable
+var ErrUnavailable = errors.New("field value unavailable")
+
+// Policy is the failure policy of a field
+type Policy struct {
+	Failure     FailurePolicy
+	Placeholder string
+}
+
+// Valid returns whether the failure policy is a known one
+func (p FailurePolicy) Valid() bool {
+	return p >= FailureSkip && p <= FailureFail
+}
+
+// Field is a log field, which value is String, or Uint if IsUint is true.
+type Field struct {
+	Key    string
+	String string
+	Uint   uint
+	IsUint bool
+}
+
+// MaxFields is the maximum number of fields extracted from a call, which can be used as capacity of
+// the fields passed to Plan.Extract to avoid allocations.
+const MaxFields = 9
+
+// Plan is the compiled extraction of a set of fields, built once by loggers so that calls don't look
+// up their configuration.
+// A nil policy means that the field is not extracted.
+type Plan struct {
+	remoteAddr *Policy
+	method     *Policy
+	serverType *Policy
+	requestID  *Policy
+	geoCountry *Policy
+	asn        *Policy
+	traceID    *Policy
+	spanID     *Policy
+	attempt    *Policy
+	size       int
+}
+
+// NewPlan compiles the extraction of keys, unavailable fields being handled according to their
+// policy in policies, skipped by default.
+// Unknown keys, like ServerName which value is constant, are ignored.
+func NewPlan(keys []string, policies map[string]Policy) *Plan {
+	p := &Plan{}
+	for _, key := range keys {
+		var target **Policy
+		switch key {
+		case RemoteAddr:
+			target = &p.remoteAddr
+		case Method:
+			target = &p.method
+		case ServerType:
+			target = &p.serverType
+		case RequestID:
+			target = &p.requestID
+		case GeoCountry:
+			target = &p.geoCountry
+		case ASN:
+			target = &p.asn
+		case TraceID:
+			target = &p.traceID
+		case SpanID:
+			target = &p.spanID
+		case Attempt:
+			target = &p.attempt
+		default:
+			continue
+		}
+		if *target == nil {
+			policy := policies[key]
+			*target = &policy
+			p.size++
+		}
+	}
+	return p
+}
+
+// Len returns the maximum number of fields extracted by the plan
+func (p *Plan) Len() int {
+	return p.size
+}
+
+// unavailable applies the policy of the field key, which value is unavailable because of err.
+func unavailable(fields []Field, policy *Policy, key string, err error) ([]Field, error) {
+	switch policy.Failure {
+	case FailureFail:
+		return nil, fmt.Errorf("failed extracting %s field: %w", key, err)
+	case FailurePlaceholder:
+		placeholder := policy.Placeholder
+		if placeholder == "" {
+			placeholder = DefaultPlaceholder
+		}
+		return append(fields, Field{Key: key, String: placeholder}), nil
+	}
+	return fields, nil
+}
+
+// Extract appends the fields of a call to fields, in a stable order, and returns them.
+// Unavailable fields are handled according to their policy.
+// server is the gRPC service implementation.
+func (p *Plan) Extract(ctx context.Context, fullMethod string, server interface{}, fields []Field) ([]Field, error) {
+	var err error
+	if p.remoteAddr != nil {
+		if addr, addrErr := remoteaddr.GetFromContext(ctx); addrErr == nil {
+			fields = append(fields, Field{Key: RemoteAddr, String: addr.String()})
+		} else if fields, err = unavailable(fields, p.remoteAddr, RemoteAddr, addrErr); err != nil {
+			return nil, err
+		}
+	}
+	if p.serverType != nil {
+		fields = append(fields, Field{Key: ServerType, String: fmt.Sprintf("%T", server)})
+	}
+	md := metadatautil.FromIncoming(ctx)
+	if fields, err = p.extractCall(ctx, fullMethod, requestid.GetFromContext(ctx), attemptFromMeta(md), fields); err != nil {
+		return nil, err
+	}
+	if p.geoCountry == nil && p.asn == nil {
+		return fields, nil
+	}
+	record, geoErr := geoip.GetFromContext(ctx)
+	if geoErr != nil {
+		record = &geoip.Record{}
+	} else {
+		geoErr = ErrUnavailable
+	}
+	if p.geoCountry != nil {
+		if record.Country != "" {
+			fields = append(fields, Field{Key: GeoCountry, String: record.Country})
+		} else if fields, err = unavailable(fields, p.geoCountry, GeoCountry, geoErr); err != nil {
+			return nil, err
+		}
+	}
+	if p.asn != nil {
+		if record.ASN != 0 {
+			fields = append(fields, Field{Key: ASN, Uint: record.ASN, IsUint: true})
+		} else if fields, err = unavailable(fields, p.asn, ASN, geoErr); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// ExtractClient appends the fields of a client call to fields, in a stable order, and returns them.
+// Request ID and attempt number are read from the outgoing metadata of ctx, and server side fields,
+// like RemoteAddr or GeoCountry, are not extracted.
+// Unavailable fields are handled according to their policy.
+func (p *Plan) ExtractClient(ctx context.Context, fullMethod string, fields []Field) ([]Field, error) {
+	md := metadatautil.FromOutgoing(ctx)
+	return p.extractCall(ctx, fullMethod, requestid.GetFromMeta(md), attemptFromMeta(md), fields)
+}
+
+// extractCall appends the fields common to server and client calls to fields, attempt being 0 if
+// the call has none.
+func (p *Plan) extractCall(ctx context.Context, fullMethod, requestID string, attempt uint, fields []Field) ([]Field, error) {
+	var err error
+	if p.method != nil {
+		fields = append(fields, Field{Key: Method, String: fullMethod})
+	}
+	if p.requestID != nil {
+		if requestID != "" {
+			fields = append(fields, Field{Key: RequestID, String: requestID})
+		} else if fields, err = unavailable(fields, p.requestID, RequestID, ErrUnavailable); err != nil {
+			return nil, err
+		}
+	}
+	if p.attempt != nil {
+		if attempt > 0 {
+			fields = append(fields, Field{Key: Attempt, Uint: attempt, IsUint: true})
+		} else if fields, err = unavailable(fields, p.attempt, Attempt, ErrUnavailable); err != nil {
+			return nil, err
+		}
+	}
+	if p.traceID != nil || p.spanID != nil {
+		return p.extractTrace(ctx, fields)
+	}
+	return fields, nil
+}
+
+// extractTrace appends the trace fields of a call to fields, from the span in ctx.
+func (p *Plan) extractTrace(ctx context.Context, fields []Field) ([]Field, error) {
+	var err error
+	sc := trace.SpanContextFromContext(ctx)
+	if p.traceID != nil {
+		if sc.HasTraceID() {
+			fields = append(fields, Field{Key: TraceID, String: sc.TraceID().String()})
+		} else if fields, err = unavailable(fields, p.traceID, TraceID, ErrUnavailable); err != nil {
+			return nil, err
+		}
+	}
+	if p.spanID != nil {
+		if sc.HasSpanID() {
+			fields = append(fields, Field{Key: SpanID, String: sc.SpanID().String()})
+		} else if fields, err = unavailable(fields, p.spanID, SpanID, ErrUnavailable); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// attemptFromMeta returns the attempt number of a call from its metadata, or 0 if it has none.
+func attemptFromMeta(md metadata.MD) uint {
+	attempt, err := metadatautil.GetInt(md, AttemptMetadataName)
+	if err != nil || attempt < 0 {
+		return 0
+	}
+	return uint(attempt)
+}
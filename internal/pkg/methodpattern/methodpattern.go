@@ -0,0 +1,35 @@
+// Package methodpattern validates and matches the method patterns used to configure components per
+// method: a full method name like "/package.Service/Method" for a single method, or
+// "/package.Service/*" for all the methods of a service.
+package methodpattern
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var patternRegex = regexp.MustCompile(`^/[^/\s]+/[^/\s]+$`)
+
+// Validate returns an error if pattern is not a valid method pattern.
+func Validate(pattern string) error {
+	if !patternRegex.MatchString(pattern) {
+		return fmt.Errorf(`invalid method pattern "%s"`, pattern)
+	}
+	return nil
+}
+
+// Lookup returns the value of the longest pattern of patterns matching fullMethod, the method name
+// then its service one, with the matched pattern. ok is false if no pattern matches.
+func Lookup[V any](patterns map[string]V, fullMethod string) (pattern string, value V, ok bool) {
+	if value, ok = patterns[fullMethod]; ok {
+		return fullMethod, value, true
+	}
+	if i := strings.LastIndex(fullMethod, "/"); i > 0 {
+		pattern = fullMethod[:i+1] + "*"
+		if value, ok = patterns[pattern]; ok {
+			return pattern, value, true
+		}
+	}
+	return "", value, false
+}
@@ -0,0 +1,33 @@
+package methodpattern
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	for _, pattern := range []string{"/foobar.DummyService/Foo", "/foobar.DummyService/*"} {
+		assert.Nil(t, Validate(pattern), "%s should be a valid pattern", pattern)
+	}
+	for _, pattern := range []string{"", "*", "foo", "/foobar.DummyService", "/foobar.DummyService/", "/foo bar/Foo", "/a/b/c"} {
+		assert.NotNil(t, Validate(pattern), "%s should be an invalid pattern", pattern)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	patterns := map[string]int{
+		"/foobar.DummyService/*":   1,
+		"/foobar.DummyService/Foo": 2,
+	}
+	check := func(fullMethod, expectedPattern string, expected int, expectedOK bool) {
+		pattern, value, ok := Lookup(patterns, fullMethod)
+		assert.Equal(t, expectedOK, ok, "Lookup(%s) should return ok %v", fullMethod, expectedOK)
+		assert.Equal(t, expectedPattern, pattern, "Lookup(%s) should match %s", fullMethod, expectedPattern)
+		assert.Equal(t, expected, value, "Lookup(%s) should return %d", fullMethod, expected)
+	}
+	check("/foobar.DummyService/Foo", "/foobar.DummyService/Foo", 2, true)
+	check("/foobar.DummyService/FooS", "/foobar.DummyService/*", 1, true)
+	check("/admin.Service/Foo", "", 0, false)
+	check("invalid", "", 0, false)
+}
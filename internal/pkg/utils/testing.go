@@ -4,32 +4,29 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net"
 	"testing"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/grpcutilstest"
 )
 
-func testDialer(impl foobar.DummyServiceServer, opts ...grpc.ServerOption) func(context.Context, string) (net.Conn, error) {
-	listener := bufconn.Listen(1024 * 1024)
-
-	server := grpc.NewServer(opts...)
-
-	foobar.RegisterDummyServiceServer(server, impl)
-
-	go func() {
-		if err := server.Serve(listener); err != nil {
-			panic(fmt.Errorf("Failed launching DummyService server : %w", err))
-		}
-	}()
-
-	return func(context.Context, string) (net.Conn, error) {
-		return listener.Dial()
-	}
+// dummyService starts a DummyService server with impl, returning a client to it
+func dummyService(
+	t *testing.T,
+	impl foobar.DummyServiceServer,
+	clientOpts []grpc.DialOption,
+	serverOpts []grpc.ServerOption,
+) foobar.DummyServiceClient {
+	s := grpcutilstest.New(
+		t,
+		func(server *grpc.Server) { foobar.RegisterDummyServiceServer(server, impl) },
+		grpcutilstest.WithServerOptions(serverOpts...),
+		grpcutilstest.WithDialOptions(clientOpts...),
+	)
+	return foobar.NewDummyServiceClient(s.Conn)
 }
 
 // TestCallFoo for tests
@@ -44,16 +41,7 @@ func TestCallFoo(
 	if len(clientContext) > 0 {
 		ctx = clientContext[0]
 	}
-	opts := []grpc.DialOption{
-		grpc.WithInsecure(),
-		grpc.WithContextDialer(testDialer(impl, serverOpts...)),
-	}
-	opts = append(opts, clientOpts...)
-	conn, err := grpc.DialContext(ctx, "", opts...)
-	if err != nil {
-		t.Fatal(fmt.Errorf("Failed creating DummyService : %w", err))
-	}
-	client := foobar.NewDummyServiceClient(conn)
+	client := dummyService(t, impl, clientOpts, serverOpts)
 	var header, trailer metadata.MD
 	v, err := client.Foo(ctx, &foobar.Empty{}, grpc.Header(&header), grpc.Trailer(&trailer))
 	return v, header, trailer, err
@@ -71,16 +59,7 @@ func TestCallFooS(
 	if len(clientContext) > 0 {
 		ctx = clientContext[0]
 	}
-	opts := []grpc.DialOption{
-		grpc.WithInsecure(),
-		grpc.WithContextDialer(testDialer(impl, serverOpts...)),
-	}
-	opts = append(opts, clientOpts...)
-	conn, err := grpc.DialContext(ctx, "", opts...)
-	if err != nil {
-		t.Fatal(fmt.Errorf("Failed creating DummyService : %w", err))
-	}
-	client := foobar.NewDummyServiceClient(conn)
+	client := dummyService(t, impl, clientOpts, serverOpts)
 	var header, trailer metadata.MD
 	s, err := client.FooS(ctx, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
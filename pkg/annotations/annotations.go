@@ -0,0 +1,37 @@
+// Package annotations holds the grpcutils protobuf custom options, defined in
+// proto/grpcutils/annotations.proto.
+//
+// To use them, add the proto directory of this repository to the protoc import paths and import
+// "grpcutils/annotations.proto" in your protobuf files :
+//
+//	message User {
+//	    string name = 1 [(grpcutils.rules) = {required: true, max_len: 64}];
+//	    string password = 2 [(grpcutils.sensitive) = true];
+//	}
+package annotations
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// IsSensitive returns whether a field is marked with the (grpcutils.sensitive) option.
+func IsSensitive(fd protoreflect.FieldDescriptor) bool {
+	opts := fd.Options()
+	if opts == nil {
+		return false
+	}
+	sensitive, _ := proto.GetExtension(opts, E_Sensitive).(bool)
+	return sensitive
+}
+
+// GetRules returns the validation rules of a field set with the (grpcutils.rules) option, or nil if
+// it has none.
+func GetRules(fd protoreflect.FieldDescriptor) *FieldRules {
+	opts := fd.Options()
+	if opts == nil || !proto.HasExtension(opts, E_Rules) {
+		return nil
+	}
+	rules, _ := proto.GetExtension(opts, E_Rules).(*FieldRules)
+	return rules
+}
@@ -0,0 +1,27 @@
+package annotations_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/annotations"
+)
+
+func TestIsSensitive(t *testing.T) {
+	fields := (&foobar.User{}).ProtoReflect().Descriptor().Fields()
+	assert.True(t, annotations.IsSensitive(fields.ByName("password")), "IsSensitive() should return true for sensitive fields")
+	assert.False(t, annotations.IsSensitive(fields.ByName("name")), "IsSensitive() should return false for other fields")
+}
+
+func TestGetRules(t *testing.T) {
+	fields := (&foobar.Order{}).ProtoReflect().Descriptor().Fields()
+	rules := annotations.GetRules(fields.ByName("quantity"))
+	if assert.NotNil(t, rules, "GetRules() should return the rules of fields with rules") {
+		assert.Equal(t, 1.0, rules.GetGte(), "GetRules() should return the gte rule")
+		assert.Equal(t, 100.0, rules.GetLte(), "GetRules() should return the lte rule")
+	}
+	assert.Equal(t, []string{"EUR", "USD"}, annotations.GetRules(fields.ByName("currency")).GetIn(), "GetRules() should return the in rule")
+	assert.Nil(t, annotations.GetRules(fields.ByName("items_by_label")), "GetRules() should return nil for fields without rules")
+}
@@ -15,7 +15,8 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 
-	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
 )
 
 const (
@@ -112,6 +113,21 @@ func GetFromContext(ctx context.Context, dest interface{}) error {
 	return nil
 }
 
+// GetMethodFromMeta returns the authorization method of the credential in a gRPC metadata map, as
+// sent by the caller, or an empty string if there is no valid credential.
+// The method is not checked against the configured ones, so it must not be trusted.
+func GetMethodFromMeta(md metadata.MD) string {
+	value, ok := metadatautil.First(md, MetadataName)
+	if !ok {
+		return ""
+	}
+	res := authorizationMetaRegex.FindStringSubmatch(value)
+	if res == nil || validateMethod(res[1]) != nil {
+		return ""
+	}
+	return res[1]
+}
+
 // AppendToOutgoingContext will return a new context with authentification metadata for a given method
 // appended to the outgoing context.
 // `method` must be a non empty string with lowercase alphanumeric characters, not containing whitespaces.
@@ -121,10 +137,7 @@ func AppendToOutgoingContext(ctx context.Context, method, credential string) (co
 		return nil, err
 	}
 	val := fmt.Sprintf("%s %s", strings.ToLower(method), credential)
-	md, ok := metadata.FromOutgoingContext(ctx)
-	if !ok {
-		md = metadata.MD{}
-	}
+	md := metadatautil.CopyOutgoing(ctx)
 	md.Set(MetadataName, val)
 	return metadata.NewOutgoingContext(ctx, md), nil
 }
@@ -149,7 +162,7 @@ func (a *Authorization) StreamInterceptor() grpc.StreamServerInterceptor {
 		_ *grpc.StreamServerInfo,
 		handler grpc.StreamHandler,
 	) error {
-		ns := &utils.ServerStream{
+		ns := &streamutil.ServerStream{
 			ServerStream: stream,
 			Ctx:          context.WithValue(stream.Context(), contextValueKey, a.parseMeta(stream.Context())),
 		}
@@ -160,11 +173,11 @@ func (a *Authorization) StreamInterceptor() grpc.StreamServerInterceptor {
 var authorizationMetaRegex = regexp.MustCompile(`(?m)^([^\s]+)\s+(.*)`)
 
 func (a *Authorization) parseMeta(ctx context.Context) any {
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok || len(md[MetadataName]) < 1 {
+	value, ok := metadatautil.First(metadatautil.FromIncoming(ctx), MetadataName)
+	if !ok {
 		return ErrMissing
 	}
-	res := authorizationMetaRegex.FindStringSubmatch(md[MetadataName][0])
+	res := authorizationMetaRegex.FindStringSubmatch(value)
 	if res == nil {
 		return fmt.Errorf("%w: invalid format for authorization metadata", ErrInvalid)
 	}
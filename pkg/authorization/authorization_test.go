@@ -191,6 +191,13 @@ func TestAppendToOutgoingContext(t *testing.T) {
 	assert.Equal(t, "method2 value2", md.Get(MetadataName)[0], "SetAppendToOutgoingContext() should return a context with the valid MetadataName value")
 }
 
+func TestGetMethodFromMeta(t *testing.T) {
+	assert.Equal(t, "", GetMethodFromMeta(nil), "GetMethodFromMeta() should return an empty string without metadata")
+	assert.Equal(t, "", GetMethodFromMeta(metadata.Pairs(MetadataName, "bearer")), "GetMethodFromMeta() should return an empty string with an invalid credential")
+	assert.Equal(t, "", GetMethodFromMeta(metadata.Pairs(MetadataName, "Bearer 42")), "GetMethodFromMeta() should return an empty string with an invalid method")
+	assert.Equal(t, "bearer", GetMethodFromMeta(metadata.Pairs(MetadataName, "bearer 42")), "GetMethodFromMeta() should return the credential method")
+}
+
 func Test_validateMethod(t *testing.T) {
 	checkValid := func(method string) {
 		assert.Nil(t, validateMethod(method), fmt.Sprintf(`validateMethod("%s") should not return an error`, strings.Replace(method, `"`, `\"`, -1)))
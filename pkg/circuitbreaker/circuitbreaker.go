@@ -0,0 +1,392 @@
+// Package circuitbreaker stops sending calls to failing downstream methods, failing them fast until
+// the downstream recovers, with a circuit breaker per client connection target and method.
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// StateClosed is the state of a circuit breaker letting calls through
+	StateClosed State = iota
+	// StateOpen is the state of a circuit breaker failing calls fast
+	StateOpen
+	// StateHalfOpen is the state of a circuit breaker letting a few calls through, to probe whether
+	// the downstream recovered
+	StateHalfOpen
+)
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+const (
+	// DefaultFailureThreshold is the default number of consecutive failures opening a circuit breaker
+	DefaultFailureThreshold = 5
+	// DefaultSuccessThreshold is the default number of consecutive successes closing a half-open
+	// circuit breaker
+	DefaultSuccessThreshold = 1
+	// DefaultOpenTimeout is the default duration a circuit breaker stays open before probing the
+	// downstream
+	DefaultOpenTimeout = 30 * time.Second
+)
+
+const (
+	// FieldFromState is the log field holding the previous state of a circuit breaker
+	FieldFromState = "from_state"
+	// FieldToState is the log field holding the new state of a circuit breaker
+	FieldToState = "to_state"
+)
+
+// ErrInvalidOptionValue is returned when trying to use an invalid option value
+var ErrInvalidOptionValue = errors.New("invalid option value")
+
+// StateChangeFunc is called when the circuit breaker of a target and method changes state
+type StateChangeFunc func(target, method string, from, to State)
+
+// Breaker is a set of circuit breakers, one per client connection target and method
+type Breaker struct {
+	failureThreshold int
+	successThreshold int
+	openTimeout      time.Duration
+	failureCodes     map[codes.Code]struct{}
+	onStateChange    []StateChangeFunc
+	now              func() time.Time
+
+	mu       sync.Mutex
+	breakers map[key]*breaker
+}
+
+// Option is the Breaker option functions type
+type Option func(*Breaker) error
+
+// WithFailureThreshold sets the number of consecutive failures opening a circuit breaker.
+// If not set, DefaultFailureThreshold is used.
+func WithFailureThreshold(n int) Option {
+	return func(b *Breaker) error {
+		if n < 1 {
+			return errors.New("failure threshold must be at least 1")
+		}
+		b.failureThreshold = n
+		return nil
+	}
+}
+
+// WithSuccessThreshold sets the number of consecutive successful calls closing a half-open circuit
+// breaker, which is also the number of concurrent calls let through while half-open.
+// If not set, DefaultSuccessThreshold is used.
+func WithSuccessThreshold(n int) Option {
+	return func(b *Breaker) error {
+		if n < 1 {
+			return errors.New("success threshold must be at least 1")
+		}
+		b.successThreshold = n
+		return nil
+	}
+}
+
+// WithOpenTimeout sets the duration a circuit breaker stays open before becoming half-open.
+// If not set, DefaultOpenTimeout is used.
+func WithOpenTimeout(d time.Duration) Option {
+	return func(b *Breaker) error {
+		if d <= 0 {
+			return errors.New("open timeout must be positive")
+		}
+		b.openTimeout = d
+		return nil
+	}
+}
+
+// WithFailureCodes sets the status codes of calls counted as failures, calls failing with other
+// codes being counted as successes as the downstream answered.
+// If not set, codes.Unavailable and codes.DeadlineExceeded are failures.
+func WithFailureCodes(c ...codes.Code) Option {
+	return func(b *Breaker) error {
+		if len(c) == 0 {
+			return errors.New("at least one failure code is required")
+		}
+		b.failureCodes = make(map[codes.Code]struct{}, len(c))
+		for _, code := range c {
+			if code == codes.OK {
+				return errors.New("codes.OK cannot be a failure code")
+			}
+			b.failureCodes[code] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithOnStateChange calls f each time a circuit breaker changes state.
+// f is called synchronously by the call changing the state, so it should not block.
+// Can be used several times to set several functions.
+func WithOnStateChange(f StateChangeFunc) Option {
+	return func(b *Breaker) error {
+		if f == nil {
+			return errors.New("cannot use a nil function")
+		}
+		b.onStateChange = append(b.onStateChange, f)
+		return nil
+	}
+}
+
+// WithLogger logs state changes with logger, with the zaplogger.FieldTarget, zaplogger.FieldMethod,
+// FieldFromState and FieldToState fields, at warn level when opening and info level otherwise.
+func WithLogger(logger *zaplogger.Logger) Option {
+	return func(b *Breaker) error {
+		if logger == nil {
+			return errors.New("cannot use a nil logger")
+		}
+		return WithOnStateChange(func(target, method string, from, to State) {
+			log := logger.GetLogger().Info
+			if to == StateOpen {
+				log = logger.GetLogger().Warn
+			}
+			log("circuit breaker state changed",
+				zap.String(zaplogger.FieldTarget, target),
+				zap.String(zaplogger.FieldMethod, method),
+				zap.Stringer(FieldFromState, from),
+				zap.Stringer(FieldToState, to),
+			)
+		})(b)
+	}
+}
+
+// New creates a new instance of Breaker with specified options
+func New(opts ...Option) (*Breaker, error) {
+	b := &Breaker{
+		failureThreshold: DefaultFailureThreshold,
+		successThreshold: DefaultSuccessThreshold,
+		openTimeout:      DefaultOpenTimeout,
+		failureCodes: map[codes.Code]struct{}{
+			codes.Unavailable:      {},
+			codes.DeadlineExceeded: {},
+		},
+		now:      time.Now,
+		breakers: make(map[key]*breaker),
+	}
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	return b, nil
+}
+
+// State returns the state of the circuit breaker of a target and method
+func (b *Breaker) State(target, method string) State {
+	b.mu.Lock()
+	cb, ok := b.breakers[key{target: target, method: method}]
+	b.mu.Unlock()
+	if !ok {
+		return StateClosed
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == StateOpen && b.now().Sub(cb.changedAt) >= b.openTimeout {
+		return StateHalfOpen
+	}
+	return cb.state
+}
+
+// UnaryClientInterceptor returns a gRPC client unary interceptor that fails calls with a
+// codes.Unavailable error while the circuit breaker of their target and method is open.
+// It must be placed after retry interceptors so that each attempt is counted.
+func (b *Breaker) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		cb := b.breaker(cc.Target(), method)
+		generation, err := b.allow(cb, cc.Target(), method)
+		if err != nil {
+			return err
+		}
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		b.record(cb, cc.Target(), method, generation, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a gRPC client stream interceptor that fails streams with a
+// codes.Unavailable error while the circuit breaker of their target and method is open.
+// A stream result is counted when receiving a message fails, or when the response of a client
+// streaming call is received, so streams must be received until their end.
+func (b *Breaker) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		target := cc.Target()
+		cb := b.breaker(target, method)
+		generation, err := b.allow(cb, target, method)
+		if err != nil {
+			return nil, err
+		}
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			b.record(cb, target, method, generation, err)
+			return nil, err
+		}
+		return &breakerClientStream{
+			ClientStream:  stream,
+			serverStreams: desc.ServerStreams,
+			record: func(err error) {
+				b.record(cb, target, method, generation, err)
+			},
+		}, nil
+	}
+}
+
+// breaker returns the circuit breaker of a target and method, creating it if needed.
+func (b *Breaker) breaker(target, method string) *breaker {
+	k := key{target: target, method: method}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb, ok := b.breakers[k]
+	if !ok {
+		cb = &breaker{changedAt: b.now()}
+		b.breakers[k] = cb
+	}
+	return cb
+}
+
+// allow returns whether a call can be sent, with the generation of the circuit breaker state the
+// call is counted in, or a codes.Unavailable error if it cannot.
+func (b *Breaker) allow(cb *breaker, target, method string) (uint64, error) {
+	cb.mu.Lock()
+	now := b.now()
+	from := cb.state
+	if cb.state == StateOpen && now.Sub(cb.changedAt) >= b.openTimeout {
+		cb.setState(StateHalfOpen, now)
+	}
+	if cb.state == StateHalfOpen && cb.probes >= b.successThreshold && now.Sub(cb.changedAt) >= b.openTimeout {
+		// probes were never recorded, like abandoned streams, probe again
+		cb.setState(StateHalfOpen, now)
+	}
+	to := cb.state
+	allowed := cb.state == StateClosed || (cb.state == StateHalfOpen && cb.probes < b.successThreshold)
+	if allowed && cb.state == StateHalfOpen {
+		cb.probes++
+	}
+	generation := cb.generation
+	cb.mu.Unlock()
+
+	b.notify(target, method, from, to)
+	if !allowed {
+		return 0, status.Errorf(codes.Unavailable, "circuit breaker of %s is open", method)
+	}
+	return generation, nil
+}
+
+// record counts the result of a call, changing the circuit breaker state if thresholds are
+// reached.
+// Results of calls allowed in a previous state generation are ignored.
+func (b *Breaker) record(cb *breaker, target, method string, generation uint64, err error) {
+	failure := false
+	if err != nil {
+		_, failure = b.failureCodes[status.Code(err)]
+	}
+	cb.mu.Lock()
+	if generation != cb.generation {
+		cb.mu.Unlock()
+		return
+	}
+	from := cb.state
+	now := b.now()
+	switch cb.state {
+	case StateClosed:
+		if !failure {
+			cb.failures = 0
+			break
+		}
+		cb.failures++
+		if cb.failures >= b.failureThreshold {
+			cb.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		cb.probes--
+		if failure {
+			cb.setState(StateOpen, now)
+			break
+		}
+		cb.successes++
+		if cb.successes >= b.successThreshold {
+			cb.setState(StateClosed, now)
+		}
+	}
+	to := cb.state
+	cb.mu.Unlock()
+
+	b.notify(target, method, from, to)
+}
+
+// notify calls state change functions if the state changed.
+func (b *Breaker) notify(target, method string, from, to State) {
+	if from == to {
+		return
+	}
+	for _, f := range b.onStateChange {
+		f(target, method, from, to)
+	}
+}
+
+// key is the key of the circuit breaker of a target and method
+type key struct {
+	target string
+	method string
+}
+
+// breaker is the circuit breaker of a target and method
+type breaker struct {
+	mu        sync.Mutex
+	state     State
+	changedAt time.Time
+	// generation is incremented on each state change so that results of calls allowed before are
+	// ignored
+	generation uint64
+	failures   int
+	successes  int
+	probes     int
+}
+
+// setState changes the state of the circuit breaker, resetting its counters.
+func (cb *breaker) setState(state State, now time.Time) {
+	cb.state = state
+	cb.changedAt = now
+	cb.generation++
+	cb.failures = 0
+	cb.successes = 0
+	cb.probes = 0
+}
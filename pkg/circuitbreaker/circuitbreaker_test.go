@@ -0,0 +1,220 @@
+package circuitbreaker
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+const testMethod = "/foobar.DummyService/Foo"
+
+type stateChange struct {
+	target, method string
+	from, to       State
+}
+
+// newTestBreaker returns a Breaker with a controllable clock, the state changes it records and a
+// client connection to call its interceptors with
+func newTestBreaker(t *testing.T, opts ...Option) (*Breaker, *time.Time, *[]stateChange, *grpc.ClientConn) {
+	changes := &[]stateChange{}
+	b, err := New(append(opts, WithOnStateChange(func(target, method string, from, to State) {
+		*changes = append(*changes, stateChange{target, method, from, to})
+	}))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	cc, err := grpc.Dial("passthrough:///downstream", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cc.Close() })
+	return b, &now, changes, cc
+}
+
+// unaryCall calls the unary interceptor of b with an invoker returning err, returning
+// whether the invoker was called and the interceptor error.
+func unaryCall(b *Breaker, cc *grpc.ClientConn, err error) (bool, error) {
+	invoked := false
+	res := b.UnaryClientInterceptor()(context.Background(), testMethod, nil, nil, cc, func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		invoked = true
+		return err
+	})
+	return invoked, res
+}
+
+func TestNew(t *testing.T) {
+	invalids := map[string]Option{
+		"WithFailureThreshold": WithFailureThreshold(0),
+		"WithSuccessThreshold": WithSuccessThreshold(0),
+		"WithOpenTimeout":      WithOpenTimeout(0),
+		"WithFailureCodes":     WithFailureCodes(),
+		"WithOnStateChange":    WithOnStateChange(nil),
+		"WithLogger":           WithLogger(nil),
+	}
+	for name, opt := range invalids {
+		b, err := New(opt)
+		assert.Nil(t, b, "%s() should not return a Breaker with an invalid value", name)
+		assert.ErrorIs(t, err, ErrInvalidOptionValue, "%s() should return a ErrInvalidOptionValue error with an invalid value", name)
+	}
+	_, err := New(WithFailureCodes(codes.OK))
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithFailureCodes() should not accept codes.OK")
+}
+
+func TestState_String(t *testing.T) {
+	assert.Equal(t, "closed", StateClosed.String(), "closed state should have a name")
+	assert.Equal(t, "open", StateOpen.String(), "open state should have a name")
+	assert.Equal(t, "half-open", StateHalfOpen.String(), "half-open state should have a name")
+	assert.Equal(t, "State(42)", State(42).String(), "unknown state should have a name")
+}
+
+func TestBreaker_UnaryClientInterceptor(t *testing.T) {
+	b, now, changes, cc := newTestBreaker(t, WithFailureThreshold(2), WithSuccessThreshold(2), WithOpenTimeout(time.Minute))
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	_, _ = unaryCall(b, cc, unavailable)
+	_, _ = unaryCall(b, cc, nil)
+	_, _ = unaryCall(b, cc, unavailable)
+	_, _ = unaryCall(b, cc, status.Error(codes.NotFound, "not found"))
+	assert.Equal(t, StateClosed, b.State(cc.Target(), testMethod), "non consecutive failures and non failure codes should not open the breaker")
+
+	_, _ = unaryCall(b, cc, unavailable)
+	_, _ = unaryCall(b, cc, status.Error(codes.DeadlineExceeded, "deadline exceeded"))
+	assert.Equal(t, StateOpen, b.State(cc.Target(), testMethod), "consecutive failures should open the breaker")
+	invoked, err := unaryCall(b, cc, nil)
+	assert.False(t, invoked, "call should not be sent while the breaker is open")
+	assert.Equal(t, codes.Unavailable, status.Code(err), "call should fail fast with an unavailable error")
+	assert.Equal(t, StateClosed, b.State(cc.Target(), "/foobar.DummyService/FooS"), "breakers should be per method")
+
+	*now = now.Add(time.Minute)
+	assert.Equal(t, StateHalfOpen, b.State(cc.Target(), testMethod), "breaker should be half-open after the open timeout")
+	invoked, _ = unaryCall(b, cc, nil)
+	assert.True(t, invoked, "call should be sent while the breaker is half-open")
+	assert.Equal(t, StateHalfOpen, b.State(cc.Target(), testMethod), "breaker should stay half-open until the success threshold")
+	_, _ = unaryCall(b, cc, nil)
+	assert.Equal(t, StateClosed, b.State(cc.Target(), testMethod), "consecutive successes should close the breaker")
+
+	_, _ = unaryCall(b, cc, unavailable)
+	_, _ = unaryCall(b, cc, unavailable)
+	*now = now.Add(time.Minute)
+	_, _ = unaryCall(b, cc, unavailable)
+	assert.Equal(t, StateOpen, b.State(cc.Target(), testMethod), "a failure while half-open should open the breaker again")
+
+	expected := []stateChange{
+		{cc.Target(), testMethod, StateClosed, StateOpen},
+		{cc.Target(), testMethod, StateOpen, StateHalfOpen},
+		{cc.Target(), testMethod, StateHalfOpen, StateClosed},
+		{cc.Target(), testMethod, StateClosed, StateOpen},
+		{cc.Target(), testMethod, StateOpen, StateHalfOpen},
+		{cc.Target(), testMethod, StateHalfOpen, StateOpen},
+	}
+	assert.Equal(t, expected, *changes, "state changes should be notified")
+}
+
+func TestBreaker_halfOpenProbes(t *testing.T) {
+	b, now, _, cc := newTestBreaker(t, WithFailureThreshold(1), WithOpenTimeout(time.Minute))
+	_, _ = unaryCall(b, cc, status.Error(codes.Unavailable, "unavailable"))
+	*now = now.Add(time.Minute)
+
+	cb := b.breaker(cc.Target(), testMethod)
+	generation, err := b.allow(cb, cc.Target(), testMethod)
+	assert.Nil(t, err, "a probe should be allowed while half-open")
+	_, err = b.allow(cb, cc.Target(), testMethod)
+	assert.Equal(t, codes.Unavailable, status.Code(err), "probes should be limited to the success threshold while half-open")
+
+	*now = now.Add(time.Minute)
+	_, err = b.allow(cb, cc.Target(), testMethod)
+	assert.Nil(t, err, "a new probe should be allowed if probes were not recorded after the open timeout")
+	b.record(cb, cc.Target(), testMethod, generation, status.Error(codes.Unavailable, "unavailable"))
+	assert.Equal(t, StateHalfOpen, b.State(cc.Target(), testMethod), "results of previous probes should be ignored")
+}
+
+type fakeClientStream struct {
+	grpc.ClientStream
+	err error
+}
+
+func (s *fakeClientStream) RecvMsg(interface{}) error {
+	return s.err
+}
+
+func TestBreaker_StreamClientInterceptor(t *testing.T) {
+	b, _, _, cc := newTestBreaker(t, WithFailureThreshold(2))
+	desc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+	stream := func(streamErr, recvErr error) error {
+		s, err := b.StreamClientInterceptor()(context.Background(), desc, cc, testMethod, func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			if streamErr != nil {
+				return nil, streamErr
+			}
+			return &fakeClientStream{err: recvErr}, nil
+		})
+		if err != nil {
+			return err
+		}
+		_ = s.RecvMsg(nil)
+		return s.RecvMsg(nil)
+	}
+
+	_ = stream(nil, status.Error(codes.Unavailable, "unavailable"))
+	assert.Equal(t, StateClosed, b.State(cc.Target(), testMethod), "a failed stream should count once")
+	_ = stream(status.Error(codes.Unavailable, "unavailable"), nil)
+	assert.Equal(t, StateOpen, b.State(cc.Target(), testMethod), "failed streams should open the breaker")
+	err := stream(nil, io.EOF)
+	assert.Equal(t, codes.Unavailable, status.Code(err), "stream should fail fast while the breaker is open")
+}
+
+func TestWithLogger(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := zaplogger.New(zaplogger.WithLogger(zap.New(core)))
+	b, now, _, cc := newTestBreaker(t, WithFailureThreshold(1), WithOpenTimeout(time.Minute), WithLogger(l))
+
+	_, _ = unaryCall(b, cc, status.Error(codes.Unavailable, "unavailable"))
+	*now = now.Add(time.Minute)
+	_, _ = unaryCall(b, cc, nil)
+	logs := recordedLogs.TakeAll()
+	if assert.Equal(t, 3, len(logs), "state changes should be logged") {
+		assert.Equal(t, zapcore.WarnLevel, logs[0].Level, "opening should be logged at warn level")
+		assert.Equal(t, zapcore.InfoLevel, logs[2].Level, "closing should be logged at info level")
+		fields := logs[0].ContextMap()
+		assert.Equal(t, cc.Target(), fields[zaplogger.FieldTarget], "log should have the target")
+		assert.Equal(t, testMethod, fields[zaplogger.FieldMethod], "log should have the method")
+		assert.Equal(t, "closed", fields[FieldFromState], "log should have the previous state")
+		assert.Equal(t, "open", fields[FieldToState], "log should have the new state")
+	}
+}
+
+type dummyBreaker struct {
+	foobar.UnimplementedDummyServiceServer
+	calls int
+}
+
+func (d *dummyBreaker) Foo(context.Context, *foobar.Empty) (*foobar.Empty, error) {
+	d.calls++
+	return nil, status.Error(codes.Unavailable, "unavailable")
+}
+
+func TestBreaker_calls(t *testing.T) {
+	b, _ := New(WithFailureThreshold(2))
+	clientOpts := []grpc.DialOption{grpc.WithUnaryInterceptor(b.UnaryClientInterceptor())}
+	impl := &dummyBreaker{}
+	for i := 0; i < 4; i++ {
+		_, _, _, err := utils.TestCallFoo(t, impl, clientOpts, nil)
+		assert.Equal(t, codes.Unavailable, status.Code(err), "call should fail")
+	}
+	assert.Equal(t, 2, impl.calls, "calls should not be sent once the breaker is open")
+}
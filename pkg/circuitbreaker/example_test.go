@@ -0,0 +1,39 @@
+package circuitbreaker_test
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/jucrouzet/grpcutils/pkg/circuitbreaker"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// ExampleNew fails calls fast while a downstream method is failing, logging state changes
+func ExampleNew() {
+	l, err := zaplogger.New()
+	if err != nil {
+		panic(err)
+	}
+	b, err := circuitbreaker.New(
+		circuitbreaker.WithFailureThreshold(5),
+		circuitbreaker.WithFailureCodes(codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted),
+		circuitbreaker.WithOpenTimeout(10*time.Second),
+		circuitbreaker.WithSuccessThreshold(2),
+		circuitbreaker.WithLogger(l),
+	)
+	if err != nil {
+		panic(err)
+	}
+	_, err = grpc.Dial(
+		"localhost:50051",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(b.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(b.StreamClientInterceptor()),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,32 @@
+package circuitbreaker
+
+import (
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// breakerClientStream records the result of a client stream once, when it ends
+type breakerClientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	record        func(err error)
+	once          sync.Once
+}
+
+// RecvMsg receives a message, recording the stream result if it ended
+func (s *breakerClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		if !s.serverStreams {
+			s.once.Do(func() { s.record(nil) })
+		}
+	case err == io.EOF:
+		s.once.Do(func() { s.record(nil) })
+	default:
+		s.once.Do(func() { s.record(err) })
+	}
+	return err
+}
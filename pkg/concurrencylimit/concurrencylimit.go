@@ -0,0 +1,286 @@
+// Package concurrencylimit protects gRPC servers from overload by limiting the number of in-flight
+// calls and open streams, shedding the calls exceeding the limits.
+package concurrencylimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/methodpattern"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+var (
+	// ErrInvalidOptionValue is returned when using an invalid option value
+	ErrInvalidOptionValue = errors.New("invalid option value")
+)
+
+// AIMD configures an additive increase / multiplicative decrease adaptive limit of in-flight unary
+// calls : each call completing within TargetLatency raises the limit by 1/limit, up to the
+// WithMaxCalls limit, and each call taking longer or exceeding its deadline multiplies it by Backoff,
+// down to MinLimit.
+type AIMD struct {
+	// MinLimit is the lowest limit of in-flight calls
+	MinLimit int
+	// TargetLatency is the maximum latency of a call to be considered as successful
+	TargetLatency time.Duration
+	// Backoff is the factor applied to the limit on slow calls, in ]0, 1[
+	Backoff float64
+}
+
+// Limiter limits the number of in-flight unary calls and open streams, globally and per method.
+//
+// When a limit is reached, calls wait for a slot for at most the WithMaxWait duration, then are
+// shed with a codes.Unavailable error. Calls which context is done while waiting, including when
+// their deadline is reached, are not shed but fail with the codes.Canceled or
+// codes.DeadlineExceeded error of their context.
+type Limiter struct {
+	maxCalls     int
+	maxStreams   int
+	methodLimits map[string]int
+	maxWait      time.Duration
+	aimd         *AIMD
+	logger       *zaplogger.Logger
+
+	calls   *semaphore
+	streams *semaphore
+
+	mu      sync.Mutex
+	methods map[string]*semaphore
+}
+
+// Option is the Limiter option functions type
+type Option func(*Limiter) error
+
+// WithMaxCalls sets the maximum number of in-flight unary calls.
+func WithMaxCalls(n int) Option {
+	return func(l *Limiter) error {
+		if n < 1 {
+			return errors.New("maximum calls must be at least 1")
+		}
+		l.maxCalls = n
+		return nil
+	}
+}
+
+// WithMaxStreams sets the maximum number of open streams.
+func WithMaxStreams(n int) Option {
+	return func(l *Limiter) error {
+		if n < 1 {
+			return errors.New("maximum streams must be at least 1")
+		}
+		l.maxStreams = n
+		return nil
+	}
+}
+
+// WithMethodLimit sets the maximum number of in-flight calls or open streams of the methods
+// matching `method`, like "/package.Service/Method" or "/package.Service/*".
+// Method limits apply in addition to WithMaxCalls and WithMaxStreams ones.
+func WithMethodLimit(method string, n int) Option {
+	return func(l *Limiter) error {
+		if err := methodpattern.Validate(method); err != nil {
+			return err
+		}
+		if n < 1 {
+			return errors.New("method limit must be at least 1")
+		}
+		l.methodLimits[method] = n
+		return nil
+	}
+}
+
+// WithMaxWait sets the maximum duration a call waits for a slot before being shed.
+// If not set, calls are shed as soon as a limit is reached.
+func WithMaxWait(d time.Duration) Option {
+	return func(l *Limiter) error {
+		if d < 0 {
+			return errors.New("maximum wait cannot be negative")
+		}
+		l.maxWait = d
+		return nil
+	}
+}
+
+// WithAdaptiveLimit makes the limit of in-flight unary calls adapt to the observed latency.
+// WithMaxCalls must be set and is used as the initial and highest limit.
+func WithAdaptiveLimit(aimd AIMD) Option {
+	return func(l *Limiter) error {
+		if aimd.MinLimit < 1 {
+			return errors.New("adaptive minimum limit must be at least 1")
+		}
+		if aimd.TargetLatency <= 0 {
+			return errors.New("adaptive target latency must be positive")
+		}
+		if aimd.Backoff <= 0 || aimd.Backoff >= 1 {
+			return errors.New("adaptive backoff must be between 0 and 1")
+		}
+		l.aimd = &aimd
+		return nil
+	}
+}
+
+// WithLogger logs shed calls with the logger returned by logger.ForCall().
+func WithLogger(logger *zaplogger.Logger) Option {
+	return func(l *Limiter) error {
+		if logger == nil {
+			return errors.New("cannot use a nil logger")
+		}
+		l.logger = logger
+		return nil
+	}
+}
+
+// New creates a new instance of Limiter with specified options
+func New(opts ...Option) (*Limiter, error) {
+	l := &Limiter{
+		methodLimits: make(map[string]int),
+		methods:      make(map[string]*semaphore),
+	}
+	for _, opt := range opts {
+		if err := opt(l); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	if l.aimd != nil {
+		if l.maxCalls == 0 {
+			return nil, fmt.Errorf("%w : adaptive limit needs a maximum calls limit", ErrInvalidOptionValue)
+		}
+		if l.aimd.MinLimit > l.maxCalls {
+			return nil, fmt.Errorf("%w : adaptive minimum limit is above maximum calls limit", ErrInvalidOptionValue)
+		}
+	}
+	if l.maxCalls > 0 {
+		l.calls = newSemaphore(l.maxCalls)
+	}
+	if l.maxStreams > 0 {
+		l.streams = newSemaphore(l.maxStreams)
+	}
+	return l, nil
+}
+
+// CallsLimit returns the current limit of in-flight unary calls, 0 meaning no limit.
+// It only changes over time with WithAdaptiveLimit.
+func (l *Limiter) CallsLimit() int {
+	if l.calls == nil {
+		return 0
+	}
+	return int(l.calls.currentLimit())
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that sheds calls exceeding the limits
+// with a codes.Unavailable error.
+func (l *Limiter) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		infos *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		release, ok := l.acquire(ctx, l.calls, infos.FullMethod)
+		if !ok {
+			return nil, l.reject(ctx, infos.FullMethod)
+		}
+		defer release()
+		start := time.Now()
+		res, err := handler(ctx, req)
+		l.adapt(time.Since(start), err)
+		return res, err
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that sheds streams exceeding the
+// limits with a codes.Unavailable error.
+func (l *Limiter) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		infos *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		release, ok := l.acquire(stream.Context(), l.streams, infos.FullMethod)
+		if !ok {
+			return l.reject(stream.Context(), infos.FullMethod)
+		}
+		defer release()
+		return handler(srv, stream)
+	}
+}
+
+func (l *Limiter) acquire(ctx context.Context, global *semaphore, fullMethod string) (func(), bool) {
+	deadline := time.Now().Add(l.maxWait)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	var acquired []*semaphore
+	release := func() {
+		for _, s := range acquired {
+			s.release()
+		}
+	}
+	for _, s := range []*semaphore{global, l.methodSemaphore(fullMethod)} {
+		if s == nil {
+			continue
+		}
+		if !s.acquire(ctx, deadline) {
+			release()
+			return nil, false
+		}
+		acquired = append(acquired, s)
+	}
+	return release, true
+}
+
+func (l *Limiter) methodSemaphore(fullMethod string) *semaphore {
+	pattern, limit, ok := methodpattern.Lookup(l.methodLimits, fullMethod)
+	if !ok {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.methods[pattern]
+	if !ok {
+		s = newSemaphore(limit)
+		l.methods[pattern] = s
+	}
+	return s
+}
+
+func (l *Limiter) adapt(latency time.Duration, err error) {
+	if l.aimd == nil {
+		return
+	}
+	slow := latency > l.aimd.TargetLatency || status.Code(err) == codes.DeadlineExceeded
+	l.calls.update(func(limit float64) float64 {
+		if slow {
+			return math.Max(float64(l.aimd.MinLimit), limit*l.aimd.Backoff)
+		}
+		return math.Min(float64(l.maxCalls), limit+1/limit)
+	})
+}
+
+// reject returns the error of a call that could not take a slot : the one of its context if the
+// client gave up waiting, else the call is shed.
+func (l *Limiter) reject(ctx context.Context, fullMethod string) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	// the wait may end right before the context is done
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return status.FromContextError(context.DeadlineExceeded).Err()
+	}
+	return l.shed(ctx, fullMethod)
+}
+
+func (l *Limiter) shed(ctx context.Context, fullMethod string) error {
+	l.logger.ForCall(ctx, fullMethod).Warn("call shed, server is overloaded")
+	return status.Error(codes.Unavailable, "server is overloaded")
+}
@@ -0,0 +1,216 @@
+package concurrencylimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+func TestNew(t *testing.T) {
+	invalids := map[string][]Option{
+		"a zero max calls":           {WithMaxCalls(0)},
+		"a zero max streams":         {WithMaxStreams(0)},
+		"an invalid method":          {WithMethodLimit("Foo", 1)},
+		"a zero method limit":        {WithMethodLimit("/foobar.DummyService/Foo", 0)},
+		"a negative max wait":        {WithMaxWait(-time.Second)},
+		"a nil logger":               {WithLogger(nil)},
+		"an invalid adaptive min":    {WithMaxCalls(10), WithAdaptiveLimit(AIMD{MinLimit: 0, TargetLatency: time.Second, Backoff: 0.5})},
+		"an invalid adaptive target": {WithMaxCalls(10), WithAdaptiveLimit(AIMD{MinLimit: 1, TargetLatency: 0, Backoff: 0.5})},
+		"an invalid adaptive factor": {WithMaxCalls(10), WithAdaptiveLimit(AIMD{MinLimit: 1, TargetLatency: time.Second, Backoff: 1})},
+		"adaptive without max calls": {WithAdaptiveLimit(AIMD{MinLimit: 1, TargetLatency: time.Second, Backoff: 0.5})},
+		"adaptive min above max":     {WithMaxCalls(1), WithAdaptiveLimit(AIMD{MinLimit: 2, TargetLatency: time.Second, Backoff: 0.5})},
+	}
+	for name, opts := range invalids {
+		l, err := New(opts...)
+		assert.Nil(t, l, "New() should not return a Limiter with %s", name)
+		assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with %s", name)
+	}
+	l, err := New()
+	assert.Nil(t, err, "New() should not return an error without options")
+	assert.Equal(t, 0, l.CallsLimit(), "CallsLimit() should return 0 without limit")
+}
+
+// blockingCall runs a unary call in a goroutine, blocking in handler until unblock is closed.
+// It returns once the call is in handler or has been shed.
+func blockingCall(l *Limiter, method string, unblock chan struct{}) chan error {
+	res := make(chan error, 1)
+	started := make(chan struct{})
+	go func() {
+		_, err := l.UnaryInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+			close(started)
+			<-unblock
+			return nil, nil
+		})
+		res <- err
+		select {
+		case <-started:
+		default:
+			close(started)
+		}
+	}()
+	<-started
+	return res
+}
+
+func unaryCall(ctx context.Context, l *Limiter, method string) error {
+	_, err := l.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	return err
+}
+
+func TestLimiter_UnaryInterceptor(t *testing.T) {
+	l, _ := New(WithMaxCalls(2), WithMethodLimit("/foobar.DummyService/*", 1))
+	unblock := make(chan struct{})
+
+	first := blockingCall(l, "/foobar.DummyService/Foo", unblock)
+	err := unaryCall(context.Background(), l, "/foobar.DummyService/Bar")
+	assert.Equal(t, codes.Unavailable, status.Code(err), "UnaryInterceptor() should shed calls exceeding method limit")
+
+	second := blockingCall(l, "/other.Service/Foo", unblock)
+	err = unaryCall(context.Background(), l, "/other.Service/Foo")
+	assert.Equal(t, codes.Unavailable, status.Code(err), "UnaryInterceptor() should shed calls exceeding global limit")
+
+	close(unblock)
+	assert.Nil(t, <-first, "UnaryInterceptor() should not fail calls within limits")
+	assert.Nil(t, <-second, "UnaryInterceptor() should not fail calls within limits")
+	assert.Nil(t, unaryCall(context.Background(), l, "/foobar.DummyService/Bar"), "UnaryInterceptor() should release slots after calls")
+
+	_, _, _, err = utils.TestCallFoo(t, &foobar.UnimplementedDummyServiceServer{}, nil, []grpc.ServerOption{
+		grpc.UnaryInterceptor(l.UnaryInterceptor()),
+	})
+	assert.Equal(t, codes.Unimplemented, status.Code(err), "UnaryInterceptor() should return handler errors")
+}
+
+func TestLimiter_maxWait(t *testing.T) {
+	l, _ := New(WithMaxCalls(1), WithMaxWait(time.Second))
+	unblock := make(chan struct{})
+	first := blockingCall(l, "/foobar.DummyService/Foo", unblock)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(unblock)
+	}()
+	assert.Nil(t, unaryCall(context.Background(), l, "/foobar.DummyService/Foo"), "UnaryInterceptor() should wait for a slot")
+	assert.Nil(t, <-first, "UnaryInterceptor() should not fail calls within limits")
+
+	unblock = make(chan struct{})
+	defer close(unblock)
+	blockingCall(l, "/foobar.DummyService/Foo", unblock)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := unaryCall(ctx, l, "/foobar.DummyService/Foo")
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err), "UnaryInterceptor() should fail calls that reach their deadline while waiting with their context error")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "UnaryInterceptor() should not wait after call deadline")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	err = unaryCall(ctx, l, "/foobar.DummyService/Foo")
+	assert.Equal(t, codes.Canceled, status.Code(err), "UnaryInterceptor() should fail calls canceled while waiting with their context error")
+
+	l, _ = New(WithMaxCalls(1), WithMaxWait(20*time.Millisecond))
+	blockingCall(l, "/foobar.DummyService/Foo", unblock)
+	err = unaryCall(context.Background(), l, "/foobar.DummyService/Foo")
+	assert.Equal(t, codes.Unavailable, status.Code(err), "UnaryInterceptor() should shed calls when no slot is available after max wait")
+}
+
+func TestLimiter_StreamInterceptor(t *testing.T) {
+	l, _ := New(WithMaxStreams(1))
+	infos := &grpc.StreamServerInfo{FullMethod: "/foobar.DummyService/FooS"}
+	stream := &streamutil.ServerStream{Ctx: context.Background()}
+
+	var inner error
+	err := l.StreamInterceptor()(nil, stream, infos, func(interface{}, grpc.ServerStream) error {
+		inner = l.StreamInterceptor()(nil, stream, infos, func(interface{}, grpc.ServerStream) error {
+			return nil
+		})
+		return nil
+	})
+	assert.Nil(t, err, "StreamInterceptor() should not fail streams within limits")
+	assert.Equal(t, codes.Unavailable, status.Code(inner), "StreamInterceptor() should shed streams exceeding limit")
+
+	err = l.StreamInterceptor()(nil, stream, infos, func(interface{}, grpc.ServerStream) error {
+		return nil
+	})
+	assert.Nil(t, err, "StreamInterceptor() should release slots after streams")
+	assert.Nil(t, unaryCall(context.Background(), l, "/foobar.DummyService/Foo"), "StreamInterceptor() should not limit unary calls")
+}
+
+func TestLimiter_adaptive(t *testing.T) {
+	l, _ := New(
+		WithMaxCalls(10),
+		WithAdaptiveLimit(AIMD{MinLimit: 2, TargetLatency: 10 * time.Millisecond, Backoff: 0.5}),
+	)
+	assert.Equal(t, 10, l.CallsLimit(), "adaptive limit should start at maximum calls")
+
+	slow := func(context.Context, interface{}) (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return nil, nil
+	}
+	infos := &grpc.UnaryServerInfo{FullMethod: "/foobar.DummyService/Foo"}
+	_, _ = l.UnaryInterceptor()(context.Background(), nil, infos, slow)
+	assert.Equal(t, 5, l.CallsLimit(), "slow calls should decrease limit")
+	_, _ = l.UnaryInterceptor()(context.Background(), nil, infos, slow)
+	_, _ = l.UnaryInterceptor()(context.Background(), nil, infos, slow)
+	assert.Equal(t, 2, l.CallsLimit(), "limit should not decrease below minimum")
+
+	_, _ = l.UnaryInterceptor()(context.Background(), nil, infos, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.DeadlineExceeded, "too late")
+	})
+	assert.Equal(t, 2, l.CallsLimit(), "calls exceeding deadline should decrease limit")
+
+	for i := 0; i < 20; i++ {
+		_ = unaryCall(context.Background(), l, "/foobar.DummyService/Foo")
+	}
+	assert.Greater(t, l.CallsLimit(), 5, "fast calls should increase limit")
+	for i := 0; i < 200; i++ {
+		_ = unaryCall(context.Background(), l, "/foobar.DummyService/Foo")
+	}
+	assert.Equal(t, 10, l.CallsLimit(), "limit should not increase above maximum calls")
+}
+
+func TestLimiter_logging(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	logger, _ := zaplogger.New(zaplogger.WithLogger(zap.New(core)))
+	l, _ := New(WithMaxCalls(1), WithLogger(logger))
+	unblock := make(chan struct{})
+	defer close(unblock)
+	blockingCall(l, "/foobar.DummyService/Foo", unblock)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestid.MetadataName, "I'm a unique ID"))
+	err := unaryCall(ctx, l, "/foobar.DummyService/Foo")
+	assert.Equal(t, codes.Unavailable, status.Code(err), "UnaryInterceptor() should shed calls exceeding limit")
+	if assert.Equal(t, 1, len(recordedLogs.All()), "shed calls should be logged") {
+		fields := recordedLogs.All()[0].ContextMap()
+		assert.Equal(t, "I'm a unique ID", fields[zaplogger.FieldRequestID], "shed calls should be logged with request ID")
+		assert.Equal(t, "/foobar.DummyService/Foo", fields[zaplogger.FieldMethod], "shed calls should be logged with method")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	l, _ = New(WithMaxCalls(1), WithMaxWait(time.Second), WithLogger(logger))
+	blockingCall(l, "/foobar.DummyService/Foo", unblock)
+	recordedLogs.TakeAll()
+	err = unaryCall(ctx, l, "/foobar.DummyService/Foo")
+	assert.Equal(t, codes.Canceled, status.Code(err), "UnaryInterceptor() should fail canceled calls with their context error")
+	assert.Empty(t, recordedLogs.All(), "canceled calls should not be logged as shed")
+}
@@ -0,0 +1,41 @@
+package concurrencylimit_test
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/concurrencylimit"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// ExampleNew limits a server to 100 in-flight calls, adapting to latency, 20 open streams and 5
+// concurrent calls of an expensive method, logging shed calls
+func ExampleNew() {
+	logger, err := zaplogger.New()
+	if err != nil {
+		panic(err)
+	}
+	l, err := concurrencylimit.New(
+		concurrencylimit.WithMaxCalls(100),
+		concurrencylimit.WithAdaptiveLimit(concurrencylimit.AIMD{
+			MinLimit:      10,
+			TargetLatency: 200 * time.Millisecond,
+			Backoff:       0.9,
+		}),
+		concurrencylimit.WithMaxStreams(20),
+		concurrencylimit.WithMethodLimit("/foobar.DummyService/Foo", 5),
+		concurrencylimit.WithMaxWait(50*time.Millisecond),
+		concurrencylimit.WithLogger(logger),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor, l.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor, l.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
@@ -0,0 +1,94 @@
+package concurrencylimit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// semaphore is a counting semaphore with a modifiable limit and a FIFO queue of waiters.
+type semaphore struct {
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	waiters  *list.List
+}
+
+func newSemaphore(limit int) *semaphore {
+	return &semaphore{
+		limit:   float64(limit),
+		waiters: list.New(),
+	}
+}
+
+// acquire takes a slot, waiting for one until deadline or ctx is done if none is available.
+// It returns false if no slot could be taken.
+func (s *semaphore) acquire(ctx context.Context, deadline time.Time) bool {
+	s.mu.Lock()
+	if s.inFlight < int(s.limit) && s.waiters.Len() == 0 {
+		s.inFlight++
+		s.mu.Unlock()
+		return true
+	}
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		s.mu.Unlock()
+		return false
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(ready)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-ready:
+		// Slot has been granted while giving up
+		return true
+	default:
+	}
+	s.waiters.Remove(elem)
+	return false
+}
+
+// release frees a slot, granting it to the first waiter if any.
+func (s *semaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	s.grant()
+}
+
+// update changes the semaphore limit with fn, atomically, granting slots to waiters if it has been
+// raised.
+func (s *semaphore) update(fn func(limit float64) float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = fn(s.limit)
+	s.grant()
+}
+
+func (s *semaphore) currentLimit() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit
+}
+
+// grant must be called with s.mu locked.
+func (s *semaphore) grant() {
+	for s.inFlight < int(s.limit) && s.waiters.Len() > 0 {
+		ready := s.waiters.Remove(s.waiters.Front()).(chan struct{})
+		s.inFlight++
+		close(ready)
+	}
+}
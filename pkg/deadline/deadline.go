@@ -0,0 +1,226 @@
+// Package deadline enforces the deadlines of gRPC server calls, applying default timeouts to calls
+// without deadline, capping long ones and rejecting calls which cannot complete in time.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/methodpattern"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+const (
+	// FieldTimeout is the request logger field holding the remaining duration before the call
+	// deadline, when the call started
+	FieldTimeout = "timeout"
+	// FieldDeadlineSource is the request logger field holding the source of the call deadline, one
+	// of SourceClient, SourceDefault, SourceCapped or SourceNone
+	FieldDeadlineSource = "deadline_source"
+)
+
+const (
+	// SourceClient is the deadline source of calls which deadline is set by the client
+	SourceClient = "client"
+	// SourceDefault is the deadline source of calls which deadline is a default timeout
+	SourceDefault = "default"
+	// SourceCapped is the deadline source of calls which deadline is capped by WithMaxTimeout
+	SourceCapped = "capped"
+	// SourceNone is the deadline source of calls without deadline
+	SourceNone = "none"
+)
+
+// ErrInvalidOptionValue is returned when trying to use an invalid option value
+var ErrInvalidOptionValue = errors.New("invalid option value")
+
+// Enforcer enforces the deadlines of calls
+type Enforcer struct {
+	defaultTimeout time.Duration
+	methodTimeouts map[string]time.Duration
+	maxTimeout     time.Duration
+	minTimeout     time.Duration
+	logger         *zaplogger.Logger
+}
+
+// Option is the Enforcer option functions type
+type Option func(*Enforcer) error
+
+// WithDefaultTimeout sets the timeout of calls without deadline.
+// If not set, calls without deadline, nor method timeout, have none.
+func WithDefaultTimeout(d time.Duration) Option {
+	return func(e *Enforcer) error {
+		if d <= 0 {
+			return errors.New("default timeout must be positive")
+		}
+		e.defaultTimeout = d
+		return nil
+	}
+}
+
+// WithMethodTimeout sets the timeout of calls without deadline to the methods matching `method`,
+// like "/package.Service/Method" or "/package.Service/*".
+// Method timeouts take precedence over WithDefaultTimeout.
+func WithMethodTimeout(method string, d time.Duration) Option {
+	return func(e *Enforcer) error {
+		if err := methodpattern.Validate(method); err != nil {
+			return err
+		}
+		if d <= 0 {
+			return errors.New("method timeout must be positive")
+		}
+		e.methodTimeouts[method] = d
+		return nil
+	}
+}
+
+// WithMaxTimeout caps the deadline of calls to d from their start, including default ones.
+func WithMaxTimeout(d time.Duration) Option {
+	return func(e *Enforcer) error {
+		if d <= 0 {
+			return errors.New("maximum timeout must be positive")
+		}
+		e.maxTimeout = d
+		return nil
+	}
+}
+
+// WithMinTimeout rejects calls which remaining duration before their deadline is below d, with a
+// codes.DeadlineExceeded error, as they are unlikely to complete in time.
+func WithMinTimeout(d time.Duration) Option {
+	return func(e *Enforcer) error {
+		if d <= 0 {
+			return errors.New("minimum timeout must be positive")
+		}
+		e.minTimeout = d
+		return nil
+	}
+}
+
+// WithLogger logs calls rejected for their too short deadline with the logger returned by
+// logger.ForCall().
+func WithLogger(logger *zaplogger.Logger) Option {
+	return func(e *Enforcer) error {
+		if logger == nil {
+			return errors.New("cannot use a nil logger")
+		}
+		e.logger = logger
+		return nil
+	}
+}
+
+// New creates a new instance of Enforcer with specified options
+func New(opts ...Option) (*Enforcer, error) {
+	e := &Enforcer{
+		methodTimeouts: make(map[string]time.Duration),
+	}
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	if e.maxTimeout > 0 && e.minTimeout >= e.maxTimeout {
+		return nil, fmt.Errorf("%w : minimum timeout must be below maximum timeout", ErrInvalidOptionValue)
+	}
+	if e.minTimeout > 0 {
+		// calls given a default timeout below the minimum one would always be rejected
+		if e.defaultTimeout > 0 && e.defaultTimeout <= e.minTimeout {
+			return nil, fmt.Errorf("%w : default timeout must be above minimum timeout", ErrInvalidOptionValue)
+		}
+		for method, d := range e.methodTimeouts {
+			if d <= e.minTimeout {
+				return nil, fmt.Errorf(`%w : timeout of "%s" must be above minimum timeout`, ErrInvalidOptionValue, method)
+			}
+		}
+	}
+	return e, nil
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that enforces calls deadline.
+// To add the deadline fields to the request logger, it must be used after zaplogger's interceptor.
+func (e *Enforcer) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		infos *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, cancel, err := e.enforce(ctx, infos.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that enforces streams deadline.
+// To add the deadline fields to the request logger, it must be used after zaplogger's interceptor.
+func (e *Enforcer) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		infos *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, cancel, err := e.enforce(stream.Context(), infos.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		return handler(srv, &streamutil.ServerStream{ServerStream: stream, Ctx: ctx})
+	}
+}
+
+// enforce returns the context of a call with its enforced deadline, or a codes.DeadlineExceeded
+// error if the call is rejected.
+func (e *Enforcer) enforce(ctx context.Context, fullMethod string) (context.Context, context.CancelFunc, error) {
+	cancel := context.CancelFunc(func() {})
+	source := SourceClient
+	deadline, ok := ctx.Deadline()
+	switch {
+	case !ok:
+		timeout := e.timeoutFor(fullMethod)
+		source = SourceDefault
+		if e.maxTimeout > 0 && (timeout == 0 || timeout > e.maxTimeout) {
+			timeout = e.maxTimeout
+			source = SourceCapped
+		}
+		if timeout == 0 {
+			_ = zaplogger.AddFields(ctx, zap.String(FieldDeadlineSource, SourceNone))
+			return ctx, cancel, nil
+		}
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	case e.maxTimeout > 0 && time.Until(deadline) > e.maxTimeout:
+		ctx, cancel = context.WithTimeout(ctx, e.maxTimeout)
+		source = SourceCapped
+	}
+	deadline, _ = ctx.Deadline()
+	remaining := time.Until(deadline)
+	if e.minTimeout > 0 && remaining < e.minTimeout {
+		cancel()
+		return nil, nil, e.reject(ctx, fullMethod, remaining)
+	}
+	_ = zaplogger.AddFields(ctx, zap.String(FieldDeadlineSource, source), zap.Duration(FieldTimeout, remaining))
+	return ctx, cancel, nil
+}
+
+// timeoutFor returns the default timeout of a method, or 0 if it has none.
+func (e *Enforcer) timeoutFor(fullMethod string) time.Duration {
+	if _, d, ok := methodpattern.Lookup(e.methodTimeouts, fullMethod); ok {
+		return d
+	}
+	return e.defaultTimeout
+}
+
+func (e *Enforcer) reject(ctx context.Context, fullMethod string, remaining time.Duration) error {
+	e.logger.ForCall(ctx, fullMethod).Warn("call rejected, deadline is too short", zap.Duration(FieldTimeout, remaining))
+	return status.Error(codes.DeadlineExceeded, "deadline is too short")
+}
@@ -0,0 +1,149 @@
+package deadline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// unaryCall calls the unary interceptor of e, returning the remaining duration before the
+// handler context deadline, or 0 if it has none.
+func unaryCall(ctx context.Context, e *Enforcer, method string) (time.Duration, error) {
+	var remaining time.Duration
+	_, err := e.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		if deadline, ok := ctx.Deadline(); ok {
+			remaining = time.Until(deadline)
+		}
+		return nil, nil
+	})
+	return remaining, err
+}
+
+func TestNew(t *testing.T) {
+	invalids := map[string][]Option{
+		"a zero default timeout":      {WithDefaultTimeout(0)},
+		"an invalid method":           {WithMethodTimeout("Foo", time.Second)},
+		"a negative method timeout":   {WithMethodTimeout("/foobar.DummyService/Foo", -time.Second)},
+		"a zero maximum timeout":      {WithMaxTimeout(0)},
+		"a zero minimum timeout":      {WithMinTimeout(0)},
+		"a nil logger":                {WithLogger(nil)},
+		"a minimum above the maximum": {WithMinTimeout(time.Minute), WithMaxTimeout(time.Second)},
+		"a minimum equal the maximum": {WithMinTimeout(time.Second), WithMaxTimeout(time.Second)},
+		"a default below the minimum": {WithMinTimeout(time.Second), WithDefaultTimeout(500 * time.Millisecond)},
+		"a default equal the minimum": {WithDefaultTimeout(time.Second), WithMinTimeout(time.Second)},
+		"a method below the minimum":  {WithMinTimeout(time.Second), WithMethodTimeout("/foobar.DummyService/*", time.Millisecond)},
+	}
+	for name, opts := range invalids {
+		e, err := New(opts...)
+		assert.Nil(t, e, "New() should not return an Enforcer with %s", name)
+		assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with %s", name)
+	}
+}
+
+func TestEnforcer_UnaryInterceptor(t *testing.T) {
+	e, _ := New(
+		WithDefaultTimeout(10*time.Second),
+		WithMethodTimeout("/foobar.DummyService/*", 5*time.Second),
+		WithMethodTimeout("/foobar.DummyService/Foo", time.Second),
+		WithMaxTimeout(time.Minute),
+		WithMinTimeout(100*time.Millisecond),
+	)
+	remaining, err := unaryCall(context.Background(), e, "/foobar.DummyService/Foo")
+	assert.Nil(t, err, "calls without deadline should not be rejected")
+	assert.InDelta(t, time.Second, remaining, float64(100*time.Millisecond), "method timeout should apply to calls without deadline")
+	remaining, _ = unaryCall(context.Background(), e, "/foobar.DummyService/Bar")
+	assert.InDelta(t, 5*time.Second, remaining, float64(100*time.Millisecond), "service timeout should apply to calls without deadline")
+	remaining, _ = unaryCall(context.Background(), e, "/other.Service/Bar")
+	assert.InDelta(t, 10*time.Second, remaining, float64(100*time.Millisecond), "default timeout should apply to calls without deadline")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	remaining, _ = unaryCall(ctx, e, "/foobar.DummyService/Foo")
+	assert.InDelta(t, 30*time.Second, remaining, float64(100*time.Millisecond), "client deadline should be kept")
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	remaining, _ = unaryCall(ctx, e, "/foobar.DummyService/Foo")
+	assert.InDelta(t, time.Minute, remaining, float64(100*time.Millisecond), "long client deadline should be capped")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = unaryCall(ctx, e, "/foobar.DummyService/Foo")
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err), "calls with a too short deadline should be rejected")
+
+	e, _ = New()
+	remaining, _ = unaryCall(context.Background(), e, "/foobar.DummyService/Foo")
+	assert.Equal(t, time.Duration(0), remaining, "calls without deadline should not have one without default timeout")
+	e, _ = New(WithMaxTimeout(time.Minute))
+	remaining, _ = unaryCall(context.Background(), e, "/foobar.DummyService/Foo")
+	assert.InDelta(t, time.Minute, remaining, float64(100*time.Millisecond), "calls without deadline should be capped")
+}
+
+func TestEnforcer_Logging(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := zaplogger.New(zaplogger.WithLogger(zap.New(core)), zaplogger.WithAccessLog())
+	e, _ := New(WithDefaultTimeout(time.Second), WithMinTimeout(100*time.Millisecond))
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(l.UnaryInterceptor(), e.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(l.StreamInterceptor(), e.StreamInterceptor()),
+	}
+
+	utils.TestCallFoo(t, &foobar.UnimplementedDummyServiceServer{}, nil, opts)
+	logs := recordedLogs.FilterMessage("finished unary call").TakeAll()
+	if assert.Equal(t, 1, len(logs), "call should be access logged") {
+		assert.Equal(t, SourceDefault, logs[0].ContextMap()[FieldDeadlineSource], "access log should have the deadline source")
+		assert.Contains(t, logs[0].ContextMap(), FieldTimeout, "access log should have the timeout")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, _, err := utils.TestCallFoo(t, &foobar.UnimplementedDummyServiceServer{}, nil, opts, ctx)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err), "calls with a too short deadline should be rejected")
+	assert.Equal(t, 1, recordedLogs.FilterMessage("call rejected, deadline is too short").Len(), "rejected calls should be logged")
+
+	// Without request logger
+	core, recordedLogs = observer.New(zapcore.DebugLevel)
+	l, _ = zaplogger.New(zaplogger.WithLogger(zap.New(core)))
+	e, _ = New(WithMinTimeout(time.Second), WithLogger(l))
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = unaryCall(ctx, e, "/foobar.DummyService/Foo")
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err), "calls with a too short deadline should be rejected")
+	logs = recordedLogs.TakeAll()
+	if assert.Equal(t, 1, len(logs), "rejected calls should be logged with the logger") {
+		assert.Equal(t, "/foobar.DummyService/Foo", logs[0].ContextMap()[zaplogger.FieldMethod], "rejected calls should be logged with method")
+	}
+}
+
+type dummyDeadline struct {
+	foobar.UnimplementedDummyServiceServer
+	hasDeadline bool
+}
+
+func (d *dummyDeadline) FooS(s foobar.DummyService_FooSServer) error {
+	_, d.hasDeadline = s.Context().Deadline()
+	for {
+		if _, err := s.Recv(); err != nil {
+			return nil
+		}
+	}
+}
+
+func TestEnforcer_StreamInterceptor(t *testing.T) {
+	e, _ := New(WithDefaultTimeout(time.Minute))
+	impl := &dummyDeadline{}
+	utils.TestCallFooS(t, impl, nil, []grpc.ServerOption{grpc.StreamInterceptor(e.StreamInterceptor())})
+	assert.True(t, impl.hasDeadline, "default timeout should apply to streams without deadline")
+}
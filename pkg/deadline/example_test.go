@@ -0,0 +1,35 @@
+package deadline_test
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/deadline"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// ExampleNew applies a default timeout to calls without deadline and rejects calls that cannot
+// complete in time
+func ExampleNew() {
+	l, err := zaplogger.New(zaplogger.WithAccessLog())
+	if err != nil {
+		panic(err)
+	}
+	e, err := deadline.New(
+		deadline.WithDefaultTimeout(10*time.Second),
+		deadline.WithMethodTimeout("/foobar.DummyService/FooS", time.Minute),
+		deadline.WithMaxTimeout(5*time.Minute),
+		deadline.WithMinTimeout(50*time.Millisecond),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		// deadline interceptors must be after zaplogger's ones to add deadline fields to access logs
+		grpc.ChainUnaryInterceptor(l.UnaryInterceptor(), e.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(l.StreamInterceptor(), e.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
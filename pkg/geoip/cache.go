@@ -0,0 +1,57 @@
+package geoip
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a fixed size, least recently used evicted, cache of records by IP address.
+// Missing records (nil) are cached as well.
+// Records are copied when added and got, so that callers modifying them don't alter the cache.
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type cacheEntry struct {
+	key    string
+	record *Record
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+func (c *lruCache) get(key string) (*Record, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).record.clone(), true
+}
+
+func (c *lruCache) add(key string, record *Record) {
+	record = record.clone()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).record = record
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, record: record})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,55 @@
+package geoip_test
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/geoip"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// ExampleNew enriches calls with GeoLite2 country and ASN databases, and logs them with zaplogger
+func ExampleNew() {
+	countries, err := geoip.OpenMMDB("/var/lib/GeoIP/GeoLite2-Country.mmdb")
+	if err != nil {
+		panic(err)
+	}
+	asns, err := geoip.OpenMMDB("/var/lib/GeoIP/GeoLite2-ASN.mmdb")
+	if err != nil {
+		panic(err)
+	}
+	e, err := geoip.New(
+		geoip.WithDatabase(countries),
+		geoip.WithDatabase(asns),
+	)
+	if err != nil {
+		panic(err)
+	}
+	l, err := zaplogger.New(
+		zaplogger.WithFields(zaplogger.FieldGeoCountry, zaplogger.FieldASN),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		// geoip interceptors must be before zaplogger's ones
+		grpc.ChainUnaryInterceptor(e.UnaryInterceptor(), l.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(e.StreamInterceptor(), l.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
+
+// ExampleGetFromContext shows how to get the remote address information in a gRPC method handler
+func ExampleGetFromContext() {
+	record, err := geoip.GetFromContext(ctx)
+	if err != nil {
+		// No information is available for client's remote address
+		return
+	}
+	fmt.Println(record.Country, record.ASN)
+}
+
+var ctx context.Context
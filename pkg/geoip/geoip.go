@@ -0,0 +1,230 @@
+// Package geoip enriches gRPC calls with geographical and network information (country,
+// autonomous system) about the client's remote IP address, looked up in a local database like
+// MaxMind's GeoIP2 / GeoLite2 ones.
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/pkg/remoteaddr"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+)
+
+// Record holds the information known about an IP address
+type Record struct {
+	// Country is the ISO 3166-1 alpha-2 code of the country of the IP address ("FR")
+	Country string
+	// ASN is the number of the autonomous system the IP address belongs to
+	ASN uint
+	// ASOrganization is the name of the organization owning the autonomous system
+	ASOrganization string
+}
+
+// Database is the interface of IP address information databases.
+// Lookup must return a nil Record and a nil error if the IP address is not in the database.
+type Database interface {
+	Lookup(ip net.IP) (*Record, error)
+}
+
+// Enricher looks up client's remote IP address information in databases and sets it in the call
+// context.
+type Enricher struct {
+	databases []Database
+	cache     *lruCache
+	cacheSize int
+	logger    *zap.Logger
+}
+
+// Option is the Enricher option functions type
+type Option func(*Enricher) error
+
+// DefaultCacheSize is the default number of IP addresses which information is kept in cache
+const DefaultCacheSize = 4096
+
+var (
+	// ErrInvalidOptionValue is returned when using an invalid option value
+	ErrInvalidOptionValue = errors.New("invalid option value")
+	// ErrNotAvailable is returned when no information is available for the call remote address
+	ErrNotAvailable = errors.New("remote address information is not available")
+)
+
+type contextValueKeyType string
+
+var contextValueKey = contextValueKeyType("github.com/jucrouzet/grpcutils/geoip value")
+
+// WithDatabase adds a database to look up IP addresses in.
+// When several databases are used, like a country and an ASN one, records are merged, the first
+// database having a value for a record field winning.
+func WithDatabase(db Database) Option {
+	return func(e *Enricher) error {
+		if db == nil {
+			return errors.New("cannot use a nil database")
+		}
+		e.databases = append(e.databases, db)
+		return nil
+	}
+}
+
+// WithCacheSize sets the number of IP addresses which information is kept in cache.
+// Setting 0 disables caching.
+// If not set, DefaultCacheSize is used.
+func WithCacheSize(size int) Option {
+	return func(e *Enricher) error {
+		if size < 0 {
+			return errors.New("cache size cannot be negative")
+		}
+		e.cacheSize = size
+		return nil
+	}
+}
+
+// WithLogger sets the logger on which calls that cannot be enriched, because their remote IP
+// address is unavailable or cannot be looked up, are logged at debug level.
+// If not set, these calls are not logged.
+func WithLogger(logger *zap.Logger) Option {
+	return func(e *Enricher) error {
+		if logger == nil {
+			return errors.New("cannot use a nil logger")
+		}
+		e.logger = logger
+		return nil
+	}
+}
+
+// New creates a new instance of Enricher with specified options
+func New(opts ...Option) (*Enricher, error) {
+	e := &Enricher{
+		cacheSize: DefaultCacheSize,
+		logger:    zap.NewNop(),
+	}
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	if len(e.databases) == 0 {
+		return nil, fmt.Errorf("%w : at least one database is needed", ErrInvalidOptionValue)
+	}
+	if e.cacheSize > 0 {
+		e.cache = newLRUCache(e.cacheSize)
+	}
+	return e, nil
+}
+
+// GetFromContext returns the information about the client's remote address that has been set in
+// UnaryInterceptor or StreamInterceptor.
+// ErrNotAvailable is returned if no information is available.
+func GetFromContext(ctx context.Context) (*Record, error) {
+	record, ok := ctx.Value(contextValueKey).(*Record)
+	if !ok || record == nil {
+		return nil, ErrNotAvailable
+	}
+	return record, nil
+}
+
+// Lookup returns the information about an IP address from cache or databases.
+// A nil Record is returned if the IP address is not found in any database.
+// The returned Record is owned by the caller, which can modify it without altering the cache.
+func (e *Enricher) Lookup(ip net.IP) (*Record, error) {
+	key := ip.String()
+	if e.cache != nil {
+		if record, ok := e.cache.get(key); ok {
+			return record, nil
+		}
+	}
+	var record *Record
+	for _, db := range e.databases {
+		r, err := db.Lookup(ip)
+		if err != nil {
+			return nil, fmt.Errorf("failed looking up %s: %w", key, err)
+		}
+		record = merge(record, r)
+	}
+	if e.cache != nil {
+		e.cache.add(key, record)
+	}
+	return record, nil
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that sets remote address information
+// in call context.
+// Calls are never failed by the interceptor : if remote address is not available or cannot be
+// looked up, no information is set.
+func (e *Enricher) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		return handler(e.enrich(ctx), req)
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that sets remote address information
+// in stream context.
+// Calls are never failed by the interceptor : if remote address is not available or cannot be
+// looked up, no information is set.
+func (e *Enricher) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		_ *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ns := &streamutil.ServerStream{
+			ServerStream: stream,
+			Ctx:          e.enrich(stream.Context()),
+		}
+		return handler(srv, ns)
+	}
+}
+
+func (e *Enricher) enrich(ctx context.Context) context.Context {
+	ip, err := remoteaddr.GetIPFromContext(ctx)
+	if err != nil {
+		e.logger.Debug("cannot read remote IP address to enrich call", zap.Error(err))
+		return ctx
+	}
+	record, err := e.Lookup(ip)
+	if err != nil {
+		e.logger.Debug("cannot look up remote IP address to enrich call", zap.Error(err))
+		return ctx
+	}
+	if record == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextValueKey, record)
+}
+
+// clone returns a copy of r, or nil if r is nil.
+func (r *Record) clone() *Record {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	return &c
+}
+
+func merge(dst, src *Record) *Record {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		return src.clone()
+	}
+	if dst.Country == "" {
+		dst.Country = src.Country
+	}
+	if dst.ASN == 0 {
+		dst.ASN = src.ASN
+		dst.ASOrganization = src.ASOrganization
+	}
+	return dst
+}
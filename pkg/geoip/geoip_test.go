@@ -0,0 +1,208 @@
+package geoip
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/grpcutilstest"
+)
+
+type fakeDatabase struct {
+	records map[string]*Record
+	err     error
+	calls   int
+}
+
+func (f *fakeDatabase) Lookup(ip net.IP) (*Record, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.records[ip.String()], nil
+}
+
+func TestNew(t *testing.T) {
+	e, err := New()
+	assert.Nil(t, e, "New() should not return an Enricher without database")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error without database")
+
+	e, err = New(WithDatabase(nil))
+	assert.Nil(t, e, "New() should not return an Enricher with a nil database")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with a nil database")
+
+	e, err = New(WithDatabase(&fakeDatabase{}), WithLogger(nil))
+	assert.Nil(t, e, "New() should not return an Enricher with a nil logger")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with a nil logger")
+
+	e, err = New(WithDatabase(&fakeDatabase{}), WithCacheSize(-1))
+	assert.Nil(t, e, "New() should not return an Enricher with a negative cache size")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with a negative cache size")
+}
+
+func TestEnricher_Lookup(t *testing.T) {
+	countries := &fakeDatabase{records: map[string]*Record{
+		"192.0.2.1": {Country: "FR"},
+		"192.0.2.2": {Country: "DE"},
+	}}
+	asns := &fakeDatabase{records: map[string]*Record{
+		"192.0.2.1": {ASN: 64496, ASOrganization: "Example Org"},
+	}}
+	e, err := New(WithDatabase(countries), WithDatabase(asns), WithCacheSize(2))
+	assert.Nil(t, err, "New() should not return an error with valid options")
+
+	r, err := e.Lookup(net.ParseIP("192.0.2.1"))
+	assert.Nil(t, err, "Lookup() should not return an error")
+	assert.Equal(t, &Record{Country: "FR", ASN: 64496, ASOrganization: "Example Org"}, r, "Lookup() should merge records of all databases")
+	r, err = e.Lookup(net.ParseIP("192.0.2.3"))
+	assert.Nil(t, err, "Lookup() should not return an error for an unknown IP")
+	assert.Nil(t, r, "Lookup() should return a nil record for an unknown IP")
+	assert.Equal(t, 2, countries.calls, "Lookup() should look up uncached addresses in databases")
+
+	_, _ = e.Lookup(net.ParseIP("192.0.2.1"))
+	_, _ = e.Lookup(net.ParseIP("192.0.2.3"))
+	assert.Equal(t, 2, countries.calls, "Lookup() should not look up cached addresses in databases")
+
+	_, _ = e.Lookup(net.ParseIP("192.0.2.2"))
+	_, _ = e.Lookup(net.ParseIP("192.0.2.1"))
+	assert.Equal(t, 4, countries.calls, "Lookup() should evict least recently used addresses from cache")
+
+	e, _ = New(WithDatabase(countries), WithCacheSize(0))
+	_, _ = e.Lookup(net.ParseIP("192.0.2.1"))
+	_, _ = e.Lookup(net.ParseIP("192.0.2.1"))
+	assert.Equal(t, 6, countries.calls, "Lookup() should not cache addresses if cache is disabled")
+
+	failing := &fakeDatabase{err: errors.New("boom")}
+	e, _ = New(WithDatabase(failing))
+	_, err = e.Lookup(net.ParseIP("192.0.2.1"))
+	assert.NotNil(t, err, "Lookup() should return database errors")
+	_, err = e.Lookup(net.ParseIP("192.0.2.1"))
+	assert.NotNil(t, err, "Lookup() should not cache database errors")
+	assert.Equal(t, 2, failing.calls, "Lookup() should not cache database errors")
+}
+
+func TestEnricher_Lookup_copies(t *testing.T) {
+	db := &fakeDatabase{records: map[string]*Record{"192.0.2.1": {Country: "FR"}}}
+	e, _ := New(WithDatabase(db))
+
+	r, _ := e.Lookup(net.ParseIP("192.0.2.1"))
+	r.Country = "DE"
+	r, _ = e.Lookup(net.ParseIP("192.0.2.1"))
+	assert.Equal(t, "FR", r.Country, "modifying a looked up record should not alter the cache")
+	r.Country = "DE"
+	r, _ = e.Lookup(net.ParseIP("192.0.2.1"))
+	assert.Equal(t, "FR", r.Country, "modifying a cached record should not alter the cache")
+	assert.Equal(t, "FR", db.records["192.0.2.1"].Country, "modifying a looked up record should not alter the database one")
+	assert.Equal(t, 1, db.calls, "records should be cached")
+}
+
+func TestGetFromContext(t *testing.T) {
+	r, err := GetFromContext(context.Background())
+	assert.Nil(t, r, "GetFromContext() should not return a record from a context without one")
+	assert.ErrorIs(t, err, ErrNotAvailable, "GetFromContext() should return a ErrNotAvailable error from a context without record")
+}
+
+type dummyGeoIP struct {
+	foobar.UnimplementedDummyServiceServer
+	t        *testing.T
+	expected *Record
+}
+
+func (d *dummyGeoIP) check(ctx context.Context) {
+	r, err := GetFromContext(ctx)
+	if d.expected == nil {
+		assert.Nil(d.t, r, "GetFromContext() should not return a record")
+		assert.ErrorIs(d.t, err, ErrNotAvailable, "GetFromContext() should return a ErrNotAvailable error")
+		return
+	}
+	assert.Nil(d.t, err, "GetFromContext() should not return an error")
+	assert.Equal(d.t, d.expected, r, "GetFromContext() should return the remote address record")
+}
+
+func (d *dummyGeoIP) Foo(ctx context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	d.check(ctx)
+	return &foobar.Empty{}, nil
+}
+
+func (d *dummyGeoIP) FooS(s foobar.DummyService_FooSServer) error {
+	d.check(s.Context())
+	for {
+		_, err := s.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			d.t.Fatal(err)
+		}
+	}
+	return nil
+}
+
+// callFoo calls Foo and FooS on a DummyService server with the enricher, from ip if it is not
+// empty, impl checking the record of calls
+func callFoo(t *testing.T, e *Enricher, impl *dummyGeoIP, ip string) error {
+	opts := []grpcutilstest.Option{
+		grpcutilstest.WithServerOptions(
+			grpc.UnaryInterceptor(e.UnaryInterceptor()),
+			grpc.StreamInterceptor(e.StreamInterceptor()),
+		),
+	}
+	if ip != "" {
+		opts = append(opts, grpcutilstest.WithPeerAddr(&net.TCPAddr{IP: net.ParseIP(ip), Port: 1234}))
+	}
+	s := grpcutilstest.New(t, func(server *grpc.Server) {
+		foobar.RegisterDummyServiceServer(server, impl)
+	}, opts...)
+	client := foobar.NewDummyServiceClient(s.Conn)
+	if _, err := client.Foo(context.Background(), &foobar.Empty{}); err != nil {
+		return err
+	}
+	stream, err := client.FooS(context.Background())
+	if err != nil {
+		return err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return err
+	}
+	if _, err = stream.Recv(); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func TestEnricher_Interceptors(t *testing.T) {
+	db := &fakeDatabase{records: map[string]*Record{
+		"192.0.2.1":   {Country: "FR", ASN: 64496},
+		"2001:db8::1": {Country: "DE", ASN: 64497},
+	}}
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	e, _ := New(WithDatabase(db), WithLogger(zap.New(core)))
+
+	err := callFoo(t, e, &dummyGeoIP{t: t, expected: &Record{Country: "FR", ASN: 64496}}, "192.0.2.1")
+	assert.Nil(t, err, "interceptors should not fail calls")
+	err = callFoo(t, e, &dummyGeoIP{t: t, expected: &Record{Country: "DE", ASN: 64497}}, "2001:db8::1")
+	assert.Nil(t, err, "interceptors should enrich calls from IPv6 addresses")
+	assert.Empty(t, recordedLogs.TakeAll(), "enriched calls should not be logged")
+	err = callFoo(t, e, &dummyGeoIP{t: t}, "192.0.2.2")
+	assert.Nil(t, err, "interceptors should not fail calls from unknown addresses")
+	// bufconn addresses are not IP addresses
+	err = callFoo(t, e, &dummyGeoIP{t: t}, "")
+	assert.Nil(t, err, "interceptors should not fail calls without remote IP")
+	if assert.Equal(t, 2, recordedLogs.Len(), "calls without remote IP should be logged") {
+		assert.Equal(t, zapcore.DebugLevel, recordedLogs.All()[0].Level, "calls without remote IP should be logged at debug level")
+	}
+
+	db.err = errors.New("boom")
+	e, _ = New(WithDatabase(db), WithCacheSize(0))
+	err = callFoo(t, e, &dummyGeoIP{t: t}, "192.0.2.1")
+	assert.Nil(t, err, "interceptors should not fail calls on database errors")
+}
@@ -0,0 +1,54 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MMDB is a Database reading a MaxMind DB file, like GeoIP2 / GeoLite2 Country, City or ASN
+// databases.
+type MMDB struct {
+	reader *maxminddb.Reader
+}
+
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	ASN            uint   `maxminddb:"autonomous_system_number"`
+	ASOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// OpenMMDB opens a MaxMind DB file.
+func OpenMMDB(path string) (*MMDB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening MaxMind database: %w", err)
+	}
+	return &MMDB{reader: reader}, nil
+}
+
+// Lookup returns the information about an IP address, or nil if the IP address is not in the
+// database.
+func (m *MMDB) Lookup(ip net.IP) (*Record, error) {
+	var r mmdbRecord
+	_, ok, err := m.reader.LookupNetwork(ip, &r)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &Record{
+		Country:        r.Country.ISOCode,
+		ASN:            r.ASN,
+		ASOrganization: r.ASOrganization,
+	}, nil
+}
+
+// Close closes the database file.
+func (m *MMDB) Close() error {
+	return m.reader.Close()
+}
@@ -0,0 +1,181 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeMMDB generates a minimal IPv4 MaxMind DB file with networks associated to their data.
+func writeMMDB(t *testing.T, networks map[string]map[string]interface{}) string {
+	type node struct {
+		children [2]*node
+		data     int
+	}
+	root := &node{data: -1}
+	data := &bytes.Buffer{}
+	cidrs := make([]string, 0, len(networks))
+	for cidr := range networks {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := network.Mask.Size()
+		n := root
+		for i := 0; i < ones; i++ {
+			b := bitAt(network.IP.To4(), i)
+			if n.children[b] == nil {
+				n.children[b] = &node{data: -1}
+			}
+			n = n.children[b]
+		}
+		n.data = data.Len()
+		encodeMMDB(data, networks[cidr])
+	}
+
+	var nodes []*node
+	index := make(map[*node]int)
+	queue := []*node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		index[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			if c != nil && c.data < 0 {
+				queue = append(queue, c)
+			}
+		}
+	}
+	count := uint32(len(nodes))
+	file := &bytes.Buffer{}
+	for _, n := range nodes {
+		for _, c := range n.children {
+			record := count
+			if c != nil && c.data >= 0 {
+				record = count + 16 + uint32(c.data)
+			} else if c != nil {
+				record = uint32(index[c])
+			}
+			file.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+		}
+	}
+	file.Write(make([]byte, 16))
+	file.Write(data.Bytes())
+	file.WriteString("\xAB\xCD\xEFMaxMind.com")
+	encodeMMDB(file, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1655107200),
+		"database_type":               "grpcutils-test",
+		"description":                 map[string]interface{}{"en": "grpcutils test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []string{"en"},
+		"node_count":                  count,
+		"record_size":                 uint16(24),
+	})
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, file.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func encodeMMDB(buf *bytes.Buffer, value interface{}) {
+	control := func(typ, size int) {
+		var extra []byte
+		if size >= 29 {
+			extra = []byte{byte(size - 29)}
+			size = 29
+		}
+		if typ > 7 {
+			buf.Write([]byte{byte(size), byte(typ - 7)})
+		} else {
+			buf.WriteByte(byte(typ<<5 | size))
+		}
+		buf.Write(extra)
+	}
+	unsigned := func(typ int, v uint64, width int) {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v)
+		b = bytes.TrimLeft(b[8-width:], "\x00")
+		control(typ, len(b))
+		buf.Write(b)
+	}
+	switch v := value.(type) {
+	case string:
+		control(2, len(v))
+		buf.WriteString(v)
+	case uint16:
+		unsigned(5, uint64(v), 2)
+	case uint32:
+		unsigned(6, uint64(v), 4)
+	case uint64:
+		unsigned(9, v, 8)
+	case []string:
+		control(11, len(v))
+		for _, s := range v {
+			encodeMMDB(buf, s)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		control(7, len(v))
+		for _, k := range keys {
+			encodeMMDB(buf, k)
+			encodeMMDB(buf, v[k])
+		}
+	}
+}
+
+func bitAt(ip net.IP, i int) int {
+	return int(ip[i/8]>>(7-uint(i%8))) & 1
+}
+
+func TestOpenMMDB(t *testing.T) {
+	db, err := OpenMMDB(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Nil(t, db, "OpenMMDB() should not return a database with a missing file")
+	assert.NotNil(t, err, "OpenMMDB() should return an error with a missing file")
+
+	path := writeMMDB(t, map[string]map[string]interface{}{
+		"192.0.2.0/24": {
+			"country": map[string]interface{}{"iso_code": "FR"},
+		},
+		"198.51.100.0/24": {
+			"autonomous_system_number":       uint32(64496),
+			"autonomous_system_organization": "Example Org",
+		},
+	})
+	db, err = OpenMMDB(path)
+	assert.Nil(t, err, "OpenMMDB() should not return an error with a valid file")
+	defer db.Close()
+
+	r, err := db.Lookup(net.ParseIP("192.0.2.42"))
+	assert.Nil(t, err, "Lookup() should not return an error for a known IP")
+	assert.Equal(t, &Record{Country: "FR"}, r, "Lookup() should return the record of a known IP")
+
+	r, err = db.Lookup(net.ParseIP("198.51.100.1"))
+	assert.Nil(t, err, "Lookup() should not return an error for a known IP")
+	assert.Equal(t, &Record{ASN: 64496, ASOrganization: "Example Org"}, r, "Lookup() should return the record of a known IP")
+
+	r, err = db.Lookup(net.ParseIP("203.0.113.1"))
+	assert.Nil(t, err, "Lookup() should not return an error for an unknown IP")
+	assert.Nil(t, r, "Lookup() should return a nil record for an unknown IP")
+
+	_, err = db.Lookup(net.ParseIP("2001:db8::1"))
+	assert.NotNil(t, err, "Lookup() should return an error for an IPv6 address in an IPv4 database")
+}
@@ -0,0 +1,34 @@
+package grpcutilstest_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/grpcutilstest"
+	"github.com/jucrouzet/grpcutils/pkg/remoteaddr"
+)
+
+// ExampleNew tests that a remote address filter denies calls from outside a private network
+func ExampleNew() {
+	var t *testing.T // the test *testing.T
+	f, err := remoteaddr.NewFilter(remoteaddr.WithAllow("/foobar.DummyService/*", "10.0.0.0/8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpcutilstest.New(
+		t,
+		func(server *grpc.Server) {
+			foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+		},
+		grpcutilstest.WithServerOptions(grpc.UnaryInterceptor(f.UnaryInterceptor())),
+		grpcutilstest.WithPeerAddr(&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}),
+	)
+	_, err = foobar.NewDummyServiceClient(s.Conn).Foo(context.Background(), &foobar.Empty{})
+	if err == nil {
+		t.Error("call should be denied")
+	}
+}
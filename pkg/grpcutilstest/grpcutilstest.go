@@ -0,0 +1,209 @@
+// Package grpcutilstest runs gRPC services in process for tests, over a bufconn listener, so that
+// interceptors can be tested with real calls.
+//
+// Tests can inject the peer address and TLS connection state seen by the server, which are set at
+// the transport level like for actual network connections.
+package grpcutilstest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// DefaultBufferSize is the default size of the bufconn listener buffer
+const DefaultBufferSize = 1024 * 1024
+
+// ErrInvalidOptionValue is returned when trying to use an invalid option value
+var ErrInvalidOptionValue = errors.New("invalid option value")
+
+// Server is a gRPC server running in process, with a client connection to it
+type Server struct {
+	// Conn is the client connection to the server
+	Conn *grpc.ClientConn
+
+	bufferSize  int
+	serverOpts  []grpc.ServerOption
+	dialOpts    []grpc.DialOption
+	peerAddr    net.Addr
+	tlsInfo     *credentials.TLSInfo
+	server      *grpc.Server
+	listener    *bufconn.Listener
+	serveResult chan error
+}
+
+// Option is the Server option functions type
+type Option func(*Server) error
+
+// WithServerOptions adds options to the gRPC server, like its interceptors.
+// Can be used several times to add several options.
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(s *Server) error {
+		s.serverOpts = append(s.serverOpts, opts...)
+		return nil
+	}
+}
+
+// WithDialOptions adds options to the client connection, like its interceptors.
+// Can be used several times to add several options.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(s *Server) error {
+		s.dialOpts = append(s.dialOpts, opts...)
+		return nil
+	}
+}
+
+// WithPeerAddr sets the remote address of the client connection, as seen by the server.
+// By default, it is a bufconn address, which is not an IP address.
+func WithPeerAddr(addr net.Addr) Option {
+	return func(s *Server) error {
+		if addr == nil {
+			return errors.New("peer address cannot be nil")
+		}
+		s.peerAddr = addr
+		return nil
+	}
+}
+
+// WithTLSInfo sets the TLS connection state of the client connection, as seen by the server, like
+// the client certificates in state.PeerCertificates.
+// The server uses credentials providing it without any handshake, replacing the ones set with
+// WithServerOptions.
+func WithTLSInfo(state tls.ConnectionState) Option {
+	return func(s *Server) error {
+		s.tlsInfo = &credentials.TLSInfo{
+			State:          state,
+			CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+		}
+		return nil
+	}
+}
+
+// WithBufferSize sets the size of the bufconn listener buffer.
+// Default is DefaultBufferSize.
+func WithBufferSize(size int) Option {
+	return func(s *Server) error {
+		if size <= 0 {
+			return fmt.Errorf("buffer size must be positive, got %d", size)
+		}
+		s.bufferSize = size
+		return nil
+	}
+}
+
+// New starts a gRPC server with the services registered by register, and connects to it.
+// Server and connection are closed when the test and its subtests complete. The test fails if
+// options are invalid or the server cannot be started.
+func New(t testing.TB, register func(*grpc.Server), opts ...Option) *Server {
+	t.Helper()
+	s := &Server{bufferSize: DefaultBufferSize}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			t.Fatalf("%s", fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error()))
+		}
+	}
+
+	serverOpts := s.serverOpts
+	if s.tlsInfo != nil {
+		serverOpts = append(serverOpts, grpc.Creds(&tlsInfoCredentials{info: *s.tlsInfo}))
+	}
+	s.server = grpc.NewServer(serverOpts...)
+	register(s.server)
+	s.listener = bufconn.Listen(s.bufferSize)
+	s.serveResult = make(chan error, 1)
+	go func() {
+		s.serveResult <- s.server.Serve(&peerListener{Listener: s.listener, addr: s.peerAddr})
+	}()
+	t.Cleanup(func() {
+		s.server.Stop()
+		if err := <-s.serveResult; err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			t.Errorf("in process gRPC server failed : %s", err)
+		}
+	})
+
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, s.dialOpts...)
+	conn, err := grpc.Dial("passthrough:///bufnet", dialOpts...)
+	if err != nil {
+		t.Fatalf("failed connecting to in process gRPC server : %s", err)
+	}
+	s.Conn = conn
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return s
+}
+
+// Metadata holds the header and trailer metadata returned by the server on a call
+type Metadata struct {
+	Header  metadata.MD
+	Trailer metadata.MD
+}
+
+// CallOptions returns the call options capturing the header and trailer of a call in m
+func (m *Metadata) CallOptions() []grpc.CallOption {
+	return []grpc.CallOption{grpc.Header(&m.Header), grpc.Trailer(&m.Trailer)}
+}
+
+// peerListener replaces the remote address of accepted connections, if set
+type peerListener struct {
+	net.Listener
+	addr net.Addr
+}
+
+func (l *peerListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil || l.addr == nil {
+		return conn, err
+	}
+	return &peerConn{Conn: conn, addr: l.addr}, nil
+}
+
+// peerConn is a connection with a replaced remote address
+type peerConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c *peerConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// tlsInfoCredentials are server transport credentials providing a TLS connection state without any
+// handshake
+type tlsInfoCredentials struct {
+	info credentials.TLSInfo
+}
+
+func (c *tlsInfoCredentials) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, c.info, nil
+}
+
+func (c *tlsInfoCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, c.info, nil
+}
+
+func (c *tlsInfoCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "tls"}
+}
+
+func (c *tlsInfoCredentials) Clone() credentials.TransportCredentials {
+	return &tlsInfoCredentials{info: c.info}
+}
+
+func (c *tlsInfoCredentials) OverrideServerName(string) error {
+	return nil
+}
@@ -0,0 +1,127 @@
+package grpcutilstest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+)
+
+// peerService returns the peer of calls in header metadata
+type peerService struct {
+	foobar.UnimplementedDummyServiceServer
+}
+
+func (s *peerService) Foo(ctx context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "no peer")
+	}
+	md := metadata.Pairs("peer-addr", p.Addr.String())
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		md.Set("peer-tls-server-name", info.State.ServerName)
+		for _, cert := range info.State.PeerCertificates {
+			md.Append("peer-tls-cn", cert.Subject.CommonName)
+		}
+	}
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		return nil, err
+	}
+	grpc.SetTrailer(ctx, metadata.Pairs("trailer", "foo"))
+	return &foobar.Empty{}, nil
+}
+
+func (s *peerService) FooS(stream foobar.DummyService_FooSServer) error {
+	p, _ := peer.FromContext(stream.Context())
+	return stream.SendHeader(metadata.Pairs("peer-addr", p.Addr.String()))
+}
+
+func register(s *grpc.Server) {
+	foobar.RegisterDummyServiceServer(s, &peerService{})
+}
+
+func TestNew(t *testing.T) {
+	s := New(t, register)
+	md := &Metadata{}
+	_, err := foobar.NewDummyServiceClient(s.Conn).Foo(context.Background(), &foobar.Empty{}, md.CallOptions()...)
+	assert.Nil(t, err, "call should not fail")
+	assert.Equal(t, []string{"bufconn"}, md.Header.Get("peer-addr"), "default peer address should be a bufconn one")
+	assert.Empty(t, md.Header.Get("peer-tls-server-name"), "connection should not be TLS by default")
+	assert.Equal(t, []string{"foo"}, md.Trailer.Get("trailer"), "trailer should be captured")
+}
+
+func TestWithPeerAddr(t *testing.T) {
+	s := New(t, register, WithPeerAddr(&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 1234}))
+	client := foobar.NewDummyServiceClient(s.Conn)
+	md := &Metadata{}
+	_, err := client.Foo(context.Background(), &foobar.Empty{}, md.CallOptions()...)
+	assert.Nil(t, err, "call should not fail")
+	assert.Equal(t, []string{"10.1.2.3:1234"}, md.Header.Get("peer-addr"), "peer address should be injected")
+
+	stream, err := client.FooS(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := stream.Header()
+	assert.Nil(t, err, "stream header should be received")
+	assert.Equal(t, []string{"10.1.2.3:1234"}, header.Get("peer-addr"), "peer address should be injected on streams")
+
+	assert.Nil(t, WithPeerAddr(&net.TCPAddr{})(&Server{}), "address should be valid")
+	assert.NotNil(t, WithPeerAddr(nil)(&Server{}), "nil address should be invalid")
+}
+
+func TestWithTLSInfo(t *testing.T) {
+	state := tls.ConnectionState{
+		ServerName:       "foo.example.com",
+		PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "client"}}},
+	}
+	s := New(t, register, WithTLSInfo(state))
+	md := &Metadata{}
+	_, err := foobar.NewDummyServiceClient(s.Conn).Foo(context.Background(), &foobar.Empty{}, md.CallOptions()...)
+	assert.Nil(t, err, "call should not fail")
+	assert.Equal(t, []string{"foo.example.com"}, md.Header.Get("peer-tls-server-name"), "TLS state should be injected")
+	assert.Equal(t, []string{"client"}, md.Header.Get("peer-tls-cn"), "peer certificates should be injected")
+}
+
+func TestWithServerOptions(t *testing.T) {
+	var foo []string
+	s := New(t, register,
+		WithServerOptions(grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			foo = md.Get("foo")
+			return handler(ctx, req)
+		})),
+		WithDialOptions(grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(metadata.AppendToOutgoingContext(ctx, "foo", "bar"), method, req, reply, cc, opts...)
+		})),
+	)
+	_, err := foobar.NewDummyServiceClient(s.Conn).Foo(context.Background(), &foobar.Empty{})
+	assert.Nil(t, err, "call should not fail")
+	assert.Equal(t, []string{"bar"}, foo, "server and dial options should be used")
+}
+
+func TestWithBufferSize(t *testing.T) {
+	assert.Nil(t, WithBufferSize(1024)(&Server{}), "positive size should be valid")
+	assert.NotNil(t, WithBufferSize(0)(&Server{}), "zero size should be invalid")
+}
+
+func TestNew_cleanup(t *testing.T) {
+	var s *Server
+	t.Run("subtest", func(t *testing.T) {
+		s = New(t, register)
+	})
+	_, err := foobar.NewDummyServiceClient(s.Conn).Foo(context.Background(), &foobar.Empty{})
+	assert.Equal(t, codes.Canceled, status.Code(err), "connection should be closed when the test completes")
+}
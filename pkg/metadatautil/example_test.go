@@ -0,0 +1,40 @@
+package metadatautil_test
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+)
+
+// ExampleGetInt reads a typed value of the incoming metadata
+func ExampleGetInt() {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("page-size", "50"))
+	size, err := metadatautil.GetInt(metadatautil.FromIncoming(ctx), "page-size")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(size)
+	// Output: 50
+}
+
+// ExampleNewForwarder forwards the tenant identifier and B3 propagation headers of server calls to
+// the calls made by handlers
+func ExampleNewForwarder() {
+	f, err := metadatautil.NewForwarder(
+		metadatautil.WithKeys("tenant-id"),
+		metadatautil.WithPrefixes("x-b3-"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(f.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(f.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
@@ -0,0 +1,130 @@
+package metadatautil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+)
+
+// ErrInvalidOptionValue is returned when trying to use an invalid option value
+var ErrInvalidOptionValue = errors.New("invalid option value")
+
+// Forwarder forwards an allowlist of incoming metadata keys to the outgoing calls made by method
+// handlers
+type Forwarder struct {
+	keys     map[string]struct{}
+	prefixes []string
+}
+
+// ForwarderOption is the Forwarder option functions type
+type ForwarderOption func(*Forwarder) error
+
+// WithKeys forwards the given incoming metadata keys.
+// Can be used several times to add several keys.
+func WithKeys(keys ...string) ForwarderOption {
+	return func(f *Forwarder) error {
+		for _, key := range keys {
+			if key == "" || strings.HasPrefix(key, ":") {
+				return fmt.Errorf(`cannot forward metadata key "%s"`, key)
+			}
+			f.keys[strings.ToLower(key)] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithPrefixes forwards the incoming metadata keys starting with one of the given prefixes, like
+// "x-b3-".
+// Can be used several times to add several prefixes.
+func WithPrefixes(prefixes ...string) ForwarderOption {
+	return func(f *Forwarder) error {
+		for _, prefix := range prefixes {
+			if prefix == "" || strings.HasPrefix(prefix, ":") {
+				return fmt.Errorf(`cannot forward metadata prefix "%s"`, prefix)
+			}
+			f.prefixes = append(f.prefixes, strings.ToLower(prefix))
+		}
+		return nil
+	}
+}
+
+// NewForwarder creates a new instance of Forwarder with specified options
+func NewForwarder(opts ...ForwarderOption) (*Forwarder, error) {
+	f := &Forwarder{keys: make(map[string]struct{})}
+	for _, opt := range opts {
+		if err := opt(f); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	if len(f.keys) == 0 && len(f.prefixes) == 0 {
+		return nil, fmt.Errorf("%w : at least one key or prefix to forward is required", ErrInvalidOptionValue)
+	}
+	return f, nil
+}
+
+// Forward returns ctx with the allowlisted keys of its incoming metadata added to its outgoing
+// metadata, so that calls made with it forward them.
+// Keys already in the outgoing metadata are replaced.
+func (f *Forwarder) Forward(ctx context.Context) context.Context {
+	var forwarded metadata.MD
+	for key, values := range FromIncoming(ctx) {
+		if !f.allowed(key) {
+			continue
+		}
+		if forwarded == nil {
+			forwarded = CopyOutgoing(ctx)
+		}
+		forwarded[key] = append([]string(nil), values...)
+	}
+	if forwarded == nil {
+		return ctx
+	}
+	return metadata.NewOutgoingContext(ctx, forwarded)
+}
+
+// allowed returns whether a key is forwarded.
+func (f *Forwarder) allowed(key string) bool {
+	if _, ok := f.keys[key]; ok {
+		return true
+	}
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that forwards the allowlisted incoming
+// metadata to the calls made by handlers with the call context.
+// Handlers replacing the outgoing metadata of the context with metadata.NewOutgoingContext
+// discard forwarded metadata, metadata.AppendToOutgoingContext must be used instead.
+func (f *Forwarder) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		return handler(f.Forward(ctx), req)
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that forwards the allowlisted
+// incoming metadata to the calls made by handlers with the stream context.
+func (f *Forwarder) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		_ *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, &streamutil.ServerStream{ServerStream: stream, Ctx: f.Forward(stream.Context())})
+	}
+}
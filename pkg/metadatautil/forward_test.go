@@ -0,0 +1,82 @@
+package metadatautil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNewForwarder(t *testing.T) {
+	_, err := NewForwarder()
+	assert.True(t, errors.Is(err, ErrInvalidOptionValue), "forwarder without keys nor prefixes should be invalid")
+	_, err = NewForwarder(WithKeys(""))
+	assert.True(t, errors.Is(err, ErrInvalidOptionValue), "empty key should be invalid")
+	_, err = NewForwarder(WithKeys(":authority"))
+	assert.True(t, errors.Is(err, ErrInvalidOptionValue), "pseudo header key should be invalid")
+	_, err = NewForwarder(WithPrefixes(""))
+	assert.True(t, errors.Is(err, ErrInvalidOptionValue), "empty prefix should be invalid")
+	f, err := NewForwarder(WithKeys("tenant-id"), WithPrefixes("x-b3-"))
+	assert.Nil(t, err, "valid options should not return an error")
+	assert.NotNil(t, f, "valid options should return a forwarder")
+}
+
+func TestForwarder_Forward(t *testing.T) {
+	f, _ := NewForwarder(WithKeys("Tenant-ID"), WithPrefixes("X-B3-"))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"tenant-id", "acme",
+		"x-b3-traceid", "1234",
+		"x-b3-spanid", "5678",
+		"authorization", "secret",
+	))
+	ctx = metadata.AppendToOutgoingContext(ctx, "tenant-id", "other", "foo", "bar")
+	md := FromOutgoing(f.Forward(ctx))
+	assert.Equal(t, []string{"acme"}, md.Get("tenant-id"), "forwarded key should replace outgoing value")
+	assert.Equal(t, []string{"1234"}, md.Get("x-b3-traceid"), "keys matching a prefix should be forwarded")
+	assert.Equal(t, []string{"5678"}, md.Get("x-b3-spanid"), "keys matching a prefix should be forwarded")
+	assert.Equal(t, []string{"bar"}, md.Get("foo"), "outgoing metadata should be kept")
+	assert.Empty(t, md.Get("authorization"), "keys not allowlisted should not be forwarded")
+	assert.Equal(t, []string{"other"}, FromOutgoing(ctx).Get("tenant-id"), "original context should not be modified")
+
+	ctx = context.Background()
+	assert.Equal(t, ctx, f.Forward(ctx), "context without allowlisted keys should be returned as is")
+}
+
+func TestForwarder_UnaryInterceptor(t *testing.T) {
+	f, _ := NewForwarder(WithKeys("tenant-id"))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant-id", "acme"))
+	var tenant string
+	_, err := f.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		tenant = GetString(FromOutgoing(ctx), "tenant-id")
+		return nil, nil
+	})
+	assert.Nil(t, err, "interceptor should not fail")
+	assert.Equal(t, "acme", tenant, "handler context should forward allowlisted keys")
+}
+
+// fakeServerStream has a context
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestForwarder_StreamInterceptor(t *testing.T) {
+	f, _ := NewForwarder(WithKeys("tenant-id"))
+	stream := &fakeServerStream{
+		ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant-id", "acme")),
+	}
+	var tenant string
+	err := f.StreamInterceptor()(nil, stream, &grpc.StreamServerInfo{}, func(_ interface{}, s grpc.ServerStream) error {
+		tenant = GetString(FromOutgoing(s.Context()), "tenant-id")
+		return nil
+	})
+	assert.Nil(t, err, "interceptor should not fail")
+	assert.Equal(t, "acme", tenant, "stream context should forward allowlisted keys")
+}
@@ -0,0 +1,206 @@
+// Package metadatautil reads and writes typed values of gRPC metadata, and forwards an allowlist of
+// incoming metadata to the outgoing calls made by method handlers.
+//
+// Typed getters read the first value of a key, and return an error wrapping ErrMissing if the key
+// has no value, or ErrInvalid if its first value cannot be parsed.
+package metadatautil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// BinarySuffix is the suffix of the keys of binary metadata, which values are base64 encoded on the
+// wire by gRPC
+const BinarySuffix = "-bin"
+
+var (
+	// ErrMissing is returned when reading a metadata key without value
+	ErrMissing = errors.New("missing metadata")
+	// ErrInvalid is returned when reading a metadata value which cannot be parsed
+	ErrInvalid = errors.New("invalid metadata value")
+)
+
+// FromIncoming returns the incoming metadata of ctx, or an empty metadata if it has none.
+// The returned metadata must not be modified, use CopyIncoming for that.
+func FromIncoming(ctx context.Context) metadata.MD {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return metadata.MD{}
+	}
+	return md
+}
+
+// CopyIncoming returns a copy of the incoming metadata of ctx, or an empty metadata if it has none.
+func CopyIncoming(ctx context.Context) metadata.MD {
+	return FromIncoming(ctx).Copy()
+}
+
+// FromOutgoing returns the outgoing metadata of ctx, or an empty metadata if it has none.
+// The returned metadata must not be modified, use CopyOutgoing for that.
+func FromOutgoing(ctx context.Context) metadata.MD {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		return metadata.MD{}
+	}
+	return md
+}
+
+// CopyOutgoing returns a copy of the outgoing metadata of ctx, or an empty metadata if it has none.
+func CopyOutgoing(ctx context.Context) metadata.MD {
+	return FromOutgoing(ctx).Copy()
+}
+
+// First returns the first value of a key, ok being false if it has none.
+func First(md metadata.MD, key string) (value string, ok bool) {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// Last returns the last value of a key, ok being false if it has none.
+func Last(md metadata.MD, key string) (value string, ok bool) {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// Values returns the values of a key, splitting comma separated lists like HTTP headers, and
+// trimming spaces around values. Empty values are skipped.
+func Values(md metadata.MD, key string) []string {
+	var values []string
+	for _, v := range md.Get(key) {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// GetString returns the first value of a key, or an empty string if it has none.
+func GetString(md metadata.MD, key string) string {
+	value, _ := First(md, key)
+	return value
+}
+
+// GetInt returns the first value of a key as a base 10 integer.
+func GetInt(md metadata.MD, key string) (int64, error) {
+	value, err := first(md, key)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, invalid(key, err)
+	}
+	return i, nil
+}
+
+// GetBool returns the first value of a key as a boolean, accepting the values accepted by
+// strconv.ParseBool, like "true", "false", "1" or "0".
+func GetBool(md metadata.MD, key string) (bool, error) {
+	value, err := first(md, key)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, invalid(key, err)
+	}
+	return b, nil
+}
+
+// GetDuration returns the first value of a key as a duration, either an integer number of seconds,
+// like the HTTP Retry-After header, or a Go duration like "1m30s".
+func GetDuration(md metadata.MD, key string) (time.Duration, error) {
+	value, err := first(md, key)
+	if err != nil {
+		return 0, err
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, invalid(key, err)
+	}
+	return d, nil
+}
+
+// GetTime returns the first value of a key as a RFC 3339 time.
+func GetTime(md metadata.MD, key string) (time.Time, error) {
+	value, err := first(md, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, invalid(key, err)
+	}
+	return t, nil
+}
+
+// GetBinary returns the first value of a binary key, which name must end with BinarySuffix.
+func GetBinary(md metadata.MD, key string) ([]byte, error) {
+	if !strings.HasSuffix(strings.ToLower(key), BinarySuffix) {
+		return nil, fmt.Errorf(`%w: "%s" is not a binary metadata key`, ErrInvalid, key)
+	}
+	value, err := first(md, key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// SetInt sets the value of a key to a base 10 integer.
+func SetInt(md metadata.MD, key string, value int64) {
+	md.Set(key, strconv.FormatInt(value, 10))
+}
+
+// SetBool sets the value of a key to a boolean.
+func SetBool(md metadata.MD, key string, value bool) {
+	md.Set(key, strconv.FormatBool(value))
+}
+
+// SetDuration sets the value of a key to a Go duration.
+func SetDuration(md metadata.MD, key string, value time.Duration) {
+	md.Set(key, value.String())
+}
+
+// SetTime sets the value of a key to a RFC 3339 time.
+func SetTime(md metadata.MD, key string, value time.Time) {
+	md.Set(key, value.Format(time.RFC3339Nano))
+}
+
+// SetBinary sets the value of a binary key, which name must end with BinarySuffix.
+func SetBinary(md metadata.MD, key string, value []byte) error {
+	if !strings.HasSuffix(strings.ToLower(key), BinarySuffix) {
+		return fmt.Errorf(`%w: "%s" is not a binary metadata key`, ErrInvalid, key)
+	}
+	md.Set(key, string(value))
+	return nil
+}
+
+func first(md metadata.MD, key string) (string, error) {
+	value, ok := First(md, key)
+	if !ok {
+		return "", fmt.Errorf(`%w: "%s" has no value`, ErrMissing, key)
+	}
+	return value, nil
+}
+
+func invalid(key string, err error) error {
+	return fmt.Errorf(`%w: "%s" : %s`, ErrInvalid, key, err.Error())
+}
@@ -0,0 +1,130 @@
+package metadatautil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestFromIncoming(t *testing.T) {
+	assert.Equal(t, metadata.MD{}, FromIncoming(context.Background()), "context without metadata should return empty metadata")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("foo", "bar"))
+	assert.Equal(t, []string{"bar"}, FromIncoming(ctx).Get("foo"), "incoming metadata should be returned")
+	md := CopyIncoming(ctx)
+	md.Set("foo", "baz")
+	assert.Equal(t, []string{"bar"}, FromIncoming(ctx).Get("foo"), "modifying a copy should not modify context metadata")
+	assert.Equal(t, metadata.MD{}, FromOutgoing(ctx), "incoming metadata should not be returned as outgoing")
+}
+
+func TestFromOutgoing(t *testing.T) {
+	assert.Equal(t, metadata.MD{}, FromOutgoing(context.Background()), "context without metadata should return empty metadata")
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "foo", "bar")
+	assert.Equal(t, []string{"bar"}, FromOutgoing(ctx).Get("foo"), "outgoing metadata should be returned")
+	md := CopyOutgoing(ctx)
+	md.Set("foo", "baz")
+	assert.Equal(t, []string{"bar"}, FromOutgoing(ctx).Get("foo"), "modifying a copy should not modify context metadata")
+}
+
+func TestFirstLast(t *testing.T) {
+	md := metadata.Pairs("foo", "a", "foo", "b", "Bar", "c")
+	v, ok := First(md, "foo")
+	assert.True(t, ok, "existing key should be found")
+	assert.Equal(t, "a", v, "first value should be returned")
+	v, ok = Last(md, "foo")
+	assert.True(t, ok, "existing key should be found")
+	assert.Equal(t, "b", v, "last value should be returned")
+	v, _ = First(md, "BAR")
+	assert.Equal(t, "c", v, "keys should be case insensitive")
+	_, ok = First(md, "baz")
+	assert.False(t, ok, "missing key should not be found")
+	_, ok = Last(md, "baz")
+	assert.False(t, ok, "missing key should not be found")
+	assert.Equal(t, "", GetString(md, "baz"), "missing key should return an empty string")
+	assert.Equal(t, "a", GetString(md, "foo"), "first value should be returned")
+}
+
+func TestValues(t *testing.T) {
+	md := metadata.Pairs("foo", "a, b", "foo", " c ,,", "foo", "")
+	assert.Equal(t, []string{"a", "b", "c"}, Values(md, "foo"), "values should be split, trimmed, and empty ones skipped")
+	assert.Nil(t, Values(md, "bar"), "missing key should return no values")
+}
+
+func TestGetInt(t *testing.T) {
+	md := metadata.Pairs("valid", "-42", "invalid", "4.2")
+	i, err := GetInt(md, "valid")
+	assert.Nil(t, err, "valid integer should be parsed")
+	assert.Equal(t, int64(-42), i, "valid integer should be parsed")
+	_, err = GetInt(md, "invalid")
+	assert.True(t, errors.Is(err, ErrInvalid), "invalid integer should return ErrInvalid")
+	_, err = GetInt(md, "missing")
+	assert.True(t, errors.Is(err, ErrMissing), "missing key should return ErrMissing")
+
+	SetInt(md, "set", 1234)
+	assert.Equal(t, []string{"1234"}, md.Get("set"), "integer should be set")
+}
+
+func TestGetBool(t *testing.T) {
+	md := metadata.Pairs("true", "true", "one", "1", "false", "false", "invalid", "yes")
+	for key, expected := range map[string]bool{"true": true, "one": true, "false": false} {
+		b, err := GetBool(md, key)
+		assert.Nil(t, err, "valid boolean %s should be parsed", key)
+		assert.Equal(t, expected, b, "valid boolean %s should be parsed", key)
+	}
+	_, err := GetBool(md, "invalid")
+	assert.True(t, errors.Is(err, ErrInvalid), "invalid boolean should return ErrInvalid")
+	_, err = GetBool(md, "missing")
+	assert.True(t, errors.Is(err, ErrMissing), "missing key should return ErrMissing")
+
+	SetBool(md, "set", true)
+	assert.Equal(t, []string{"true"}, md.Get("set"), "boolean should be set")
+}
+
+func TestGetDuration(t *testing.T) {
+	md := metadata.Pairs("seconds", "30", "duration", "1m30s", "invalid", "soon")
+	d, err := GetDuration(md, "seconds")
+	assert.Nil(t, err, "integer number of seconds should be parsed")
+	assert.Equal(t, 30*time.Second, d, "integer number of seconds should be parsed")
+	d, err = GetDuration(md, "duration")
+	assert.Nil(t, err, "Go duration should be parsed")
+	assert.Equal(t, 90*time.Second, d, "Go duration should be parsed")
+	_, err = GetDuration(md, "invalid")
+	assert.True(t, errors.Is(err, ErrInvalid), "invalid duration should return ErrInvalid")
+	_, err = GetDuration(md, "missing")
+	assert.True(t, errors.Is(err, ErrMissing), "missing key should return ErrMissing")
+
+	SetDuration(md, "set", 1500*time.Millisecond)
+	d, _ = GetDuration(md, "set")
+	assert.Equal(t, 1500*time.Millisecond, d, "set duration should be read back")
+}
+
+func TestGetTime(t *testing.T) {
+	now := time.Date(2022, 6, 13, 12, 30, 0, 123456789, time.UTC)
+	md := metadata.Pairs("invalid", "yesterday")
+	SetTime(md, "set", now)
+	assert.Equal(t, []string{"2022-06-13T12:30:00.123456789Z"}, md.Get("set"), "time should be set in RFC 3339")
+	tm, err := GetTime(md, "set")
+	assert.Nil(t, err, "valid time should be parsed")
+	assert.True(t, now.Equal(tm), "set time should be read back")
+	_, err = GetTime(md, "invalid")
+	assert.True(t, errors.Is(err, ErrInvalid), "invalid time should return ErrInvalid")
+	_, err = GetTime(md, "missing")
+	assert.True(t, errors.Is(err, ErrMissing), "missing key should return ErrMissing")
+}
+
+func TestGetBinary(t *testing.T) {
+	md := metadata.MD{}
+	assert.Nil(t, SetBinary(md, "foo-bin", []byte{0, 1, 2}), "binary key should be set")
+	b, err := GetBinary(md, "foo-bin")
+	assert.Nil(t, err, "binary key should be read")
+	assert.Equal(t, []byte{0, 1, 2}, b, "set value should be read back")
+	_, err = GetBinary(md, "bar-bin")
+	assert.True(t, errors.Is(err, ErrMissing), "missing key should return ErrMissing")
+
+	assert.True(t, errors.Is(SetBinary(md, "foo", []byte{0}), ErrInvalid), "setting a non binary key should return ErrInvalid")
+	_, err = GetBinary(md, "foo")
+	assert.True(t, errors.Is(err, ErrInvalid), "reading a non binary key should return ErrInvalid")
+}
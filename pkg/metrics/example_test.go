@@ -0,0 +1,28 @@
+package metrics_test
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/metrics"
+)
+
+// ExampleNew records calls metrics and exposes them to Prometheus on /metrics
+func ExampleNew() {
+	m, err := metrics.New(
+		metrics.WithNamespace("myservice"),
+		metrics.WithAuthorizationMethodLabel("bearer", "basic"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(m.UnaryInterceptor()),
+		grpc.StreamInterceptor(m.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+
+	http.Handle("/metrics", m.Handler())
+}
@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// snapshot is a consistent copy of a series
+type snapshot struct {
+	labels
+	series
+}
+
+// Handler returns an HTTP handler writing metrics in the Prometheus text exposition format, to be
+// registered on the metrics endpoint scraped by Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_ = m.Write(w)
+	})
+}
+
+// Write writes metrics to w in the Prometheus text exposition format
+func (m *Metrics) Write(w io.Writer) error {
+	snapshots := m.snapshots()
+	bw := bufio.NewWriter(w)
+	m.writeFamily(bw, "grpc_server_started_total", "counter", "Total number of calls started on the server.", snapshots, func(s *snapshot, name, labels string) {
+		writeSample(bw, name, labels, float64(s.started))
+	})
+	m.writeFamily(bw, "grpc_server_handled_total", "counter", "Total number of calls completed on the server, regardless of success or failure.", snapshots, func(s *snapshot, name, labels string) {
+		handled := make([]codes.Code, 0, len(s.handled))
+		for code := range s.handled {
+			handled = append(handled, code)
+		}
+		sort.Slice(handled, func(i, j int) bool { return handled[i] < handled[j] })
+		for _, code := range handled {
+			writeSample(bw, name, labels+`,grpc_code="`+code.String()+`"`, float64(s.handled[code]))
+		}
+	})
+	m.writeFamily(bw, "grpc_server_handling_seconds", "histogram", "Histogram of calls latency, in seconds, until completed by the server.", snapshots, func(s *snapshot, name, labels string) {
+		for i, le := range m.buckets {
+			writeSample(bw, name+"_bucket", labels+`,le="`+formatFloat(le)+`"`, float64(s.buckets[i]))
+		}
+		writeSample(bw, name+"_bucket", labels+`,le="+Inf"`, float64(s.count))
+		writeSample(bw, name+"_sum", labels, s.sum)
+		writeSample(bw, name+"_count", labels, float64(s.count))
+	})
+	m.writeFamily(bw, "grpc_server_in_flight", "gauge", "Number of calls currently handled by the server.", snapshots, func(s *snapshot, name, labels string) {
+		writeSample(bw, name, labels, float64(s.inFlight))
+	})
+	m.writeFamily(bw, "grpc_server_msg_received_total", "counter", "Total number of stream messages received by the server.", snapshots, func(s *snapshot, name, labels string) {
+		if s.typ != TypeUnary {
+			writeSample(bw, name, labels, float64(s.msgReceived))
+		}
+	})
+	m.writeFamily(bw, "grpc_server_msg_sent_total", "counter", "Total number of stream messages sent by the server.", snapshots, func(s *snapshot, name, labels string) {
+		if s.typ != TypeUnary {
+			writeSample(bw, name, labels, float64(s.msgSent))
+		}
+	})
+	return bw.Flush()
+}
+
+// snapshots returns a copy of all series, sorted by labels.
+func (m *Metrics) snapshots() []*snapshot {
+	m.mu.RLock()
+	snapshots := make([]*snapshot, 0, len(m.series))
+	for l, s := range m.series {
+		s.mu.Lock()
+		snap := &snapshot{labels: l}
+		snap.started = s.started
+		snap.inFlight = s.inFlight
+		snap.sum = s.sum
+		snap.count = s.count
+		snap.msgReceived = s.msgReceived
+		snap.msgSent = s.msgSent
+		snap.buckets = append([]uint64(nil), s.buckets...)
+		snap.handled = make(map[codes.Code]uint64, len(s.handled))
+		for code, n := range s.handled {
+			snap.handled[code] = n
+		}
+		s.mu.Unlock()
+		snapshots = append(snapshots, snap)
+	}
+	m.mu.RUnlock()
+	sort.Slice(snapshots, func(i, j int) bool {
+		a, b := snapshots[i].labels, snapshots[j].labels
+		if a.service != b.service {
+			return a.service < b.service
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		if a.typ != b.typ {
+			return a.typ < b.typ
+		}
+		return a.authMethod < b.authMethod
+	})
+	return snapshots
+}
+
+// writeFamily writes the HELP and TYPE lines of a metric family, then its samples written by
+// sample for each series.
+func (m *Metrics) writeFamily(
+	w *bufio.Writer,
+	name, typ, help string,
+	snapshots []*snapshot,
+	sample func(s *snapshot, name, labels string),
+) {
+	name = m.namespace + name
+	_, _ = w.WriteString("# HELP " + name + " " + help + "\n")
+	_, _ = w.WriteString("# TYPE " + name + " " + typ + "\n")
+	for _, s := range snapshots {
+		sample(s, name, m.formatLabels(s.labels))
+	}
+}
+
+// formatLabels returns the labels of a series, without braces.
+func (m *Metrics) formatLabels(l labels) string {
+	var b strings.Builder
+	b.WriteString(`grpc_type="` + escapeLabelValue(l.typ) + `"`)
+	b.WriteString(`,grpc_service="` + escapeLabelValue(l.service) + `"`)
+	b.WriteString(`,grpc_method="` + escapeLabelValue(l.method) + `"`)
+	if m.authMethods != nil {
+		b.WriteString(`,auth_method="` + escapeLabelValue(l.authMethod) + `"`)
+	}
+	return b.String()
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	_, _ = w.WriteString(name + "{" + labels + "} " + formatFloat(value) + "\n")
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,304 @@
+// Package metrics records RED (rate, errors, duration) metrics of gRPC server calls, exposed in the
+// Prometheus text format.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/pkg/authorization"
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+)
+
+const (
+	// TypeUnary is the grpc_type label value of unary calls
+	TypeUnary = "unary"
+	// TypeClientStream is the grpc_type label value of client streaming calls
+	TypeClientStream = "client_stream"
+	// TypeServerStream is the grpc_type label value of server streaming calls
+	TypeServerStream = "server_stream"
+	// TypeBidiStream is the grpc_type label value of bidirectional streaming calls
+	TypeBidiStream = "bidi_stream"
+	// OtherLabel is the label value of methods above WithMaxMethods and of authorization methods not
+	// set in WithAuthorizationMethodLabel
+	OtherLabel = "other"
+	// NoneLabel is the auth_method label value of calls without authorization credential
+	NoneLabel = "none"
+)
+
+// DefaultBuckets are the default upper bounds, in seconds, of the calls latency histogram buckets
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ErrInvalidOptionValue is returned when trying to use an invalid option value
+var ErrInvalidOptionValue = errors.New("invalid option value")
+
+// Metrics records the metrics of gRPC server calls
+type Metrics struct {
+	namespace   string
+	buckets     []float64
+	maxMethods  int
+	authMethods map[string]bool
+
+	mu      sync.RWMutex
+	methods map[string]bool
+	series  map[labels]*series
+}
+
+// Option is the Metrics option functions type
+type Option func(*Metrics) error
+
+// labels are the labels of a calls series
+type labels struct {
+	typ        string
+	service    string
+	method     string
+	authMethod string
+}
+
+// series holds the metrics of the calls with the same labels
+type series struct {
+	mu          sync.Mutex
+	started     uint64
+	handled     map[codes.Code]uint64
+	inFlight    int64
+	buckets     []uint64
+	sum         float64
+	count       uint64
+	msgReceived uint64
+	msgSent     uint64
+}
+
+var namespaceRegex = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// WithNamespace prefixes metrics names with namespace, followed by an underscore
+func WithNamespace(namespace string) Option {
+	return func(m *Metrics) error {
+		if !namespaceRegex.MatchString(namespace) {
+			return fmt.Errorf("invalid namespace %q", namespace)
+		}
+		m.namespace = namespace + "_"
+		return nil
+	}
+}
+
+// WithBuckets sets the upper bounds, in seconds, of the calls latency histogram buckets, which
+// must be increasing.
+// If not set, DefaultBuckets is used.
+func WithBuckets(buckets ...float64) Option {
+	return func(m *Metrics) error {
+		if len(buckets) == 0 {
+			return errors.New("buckets cannot be empty")
+		}
+		for i := 1; i < len(buckets); i++ {
+			if buckets[i] <= buckets[i-1] {
+				return errors.New("buckets must be increasing")
+			}
+		}
+		m.buckets = append([]float64(nil), buckets...)
+		return nil
+	}
+}
+
+// WithMaxMethods limits the number of methods having their own series to n, calls to other methods
+// being recorded with the OtherLabel grpc_service and grpc_method labels.
+// It bounds metrics cardinality when using an unknown service handler.
+// If not set, the number of methods is not limited.
+func WithMaxMethods(n int) Option {
+	return func(m *Metrics) error {
+		if n < 1 {
+			return errors.New("max methods must be positive")
+		}
+		m.maxMethods = n
+		return nil
+	}
+}
+
+// WithAuthorizationMethodLabel adds the auth_method label to calls metrics, holding the
+// authorization method of the call credential (see github.com/jucrouzet/grpcutils/pkg/authorization).
+// Methods not in methods are labeled OtherLabel, and calls without credential NoneLabel, so that
+// callers cannot increase metrics cardinality.
+func WithAuthorizationMethodLabel(methods ...string) Option {
+	return func(m *Metrics) error {
+		if len(methods) == 0 {
+			return errors.New("authorization methods cannot be empty")
+		}
+		m.authMethods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			if method == "" {
+				return errors.New("authorization method cannot be empty")
+			}
+			m.authMethods[method] = true
+		}
+		return nil
+	}
+}
+
+// New creates a new instance of Metrics with specified options
+func New(opts ...Option) (*Metrics, error) {
+	m := &Metrics{
+		buckets: DefaultBuckets,
+		methods: make(map[string]bool),
+		series:  make(map[labels]*series),
+	}
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	return m, nil
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that records calls metrics
+func (m *Metrics) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		infos *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		s := m.seriesFor(ctx, TypeUnary, infos.FullMethod)
+		s.start()
+		start := time.Now()
+		res, err := handler(ctx, req)
+		s.finish(status.Code(err), time.Since(start), m.buckets)
+		return res, err
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that records calls and messages
+// metrics
+func (m *Metrics) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		infos *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		typ := TypeBidiStream
+		switch {
+		case !infos.IsServerStream:
+			typ = TypeClientStream
+		case !infos.IsClientStream:
+			typ = TypeServerStream
+		}
+		s := m.seriesFor(stream.Context(), typ, infos.FullMethod)
+		s.start()
+		start := time.Now()
+		err := handler(srv, &streamutil.ServerStream{
+			ServerStream: stream,
+			RecvMsgHook:  s.countMsg(&s.msgReceived),
+			SendMsgHook:  s.countMsg(&s.msgSent),
+		})
+		s.finish(status.Code(err), time.Since(start), m.buckets)
+		return err
+	}
+}
+
+// seriesFor returns the series of a call, creating it if needed.
+func (m *Metrics) seriesFor(ctx context.Context, typ, fullMethod string) *series {
+	key := labels{typ: typ, authMethod: m.authMethodLabel(ctx)}
+	m.mu.RLock()
+	s, ok := m.series[m.withMethod(key, fullMethod)]
+	m.mu.RUnlock()
+	if ok {
+		return s
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key = m.withMethod(key, fullMethod)
+	if key.method != OtherLabel {
+		m.methods[fullMethod] = true
+	}
+	s, ok = m.series[key]
+	if !ok {
+		s = &series{
+			handled: make(map[codes.Code]uint64),
+			buckets: make([]uint64, len(m.buckets)),
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// withMethod returns key with the service and method labels of fullMethod, or OtherLabel if the
+// maximum number of methods is reached.
+// Must be called with m.mu locked.
+func (m *Metrics) withMethod(key labels, fullMethod string) labels {
+	if m.maxMethods > 0 && !m.methods[fullMethod] && len(m.methods) >= m.maxMethods {
+		key.service, key.method = OtherLabel, OtherLabel
+		return key
+	}
+	key.service, key.method = splitMethod(fullMethod)
+	return key
+}
+
+// authMethodLabel returns the auth_method label value of a call, or an empty string if the label
+// is not used.
+func (m *Metrics) authMethodLabel(ctx context.Context) string {
+	if m.authMethods == nil {
+		return ""
+	}
+	method := authorization.GetMethodFromMeta(metadatautil.FromIncoming(ctx))
+	switch {
+	case method == "":
+		return NoneLabel
+	case !m.authMethods[method]:
+		return OtherLabel
+	}
+	return method
+}
+
+// splitMethod returns the service and method names of a full method name, or OtherLabel if it is
+// not one.
+func splitMethod(fullMethod string) (string, string) {
+	i := strings.LastIndex(fullMethod, "/")
+	if i < 1 {
+		return OtherLabel, OtherLabel
+	}
+	return fullMethod[1:i], fullMethod[i+1:]
+}
+
+func (s *series) start() {
+	s.mu.Lock()
+	s.started++
+	s.inFlight++
+	s.mu.Unlock()
+}
+
+func (s *series) finish(code codes.Code, latency time.Duration, buckets []float64) {
+	seconds := latency.Seconds()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	s.handled[code]++
+	s.sum += seconds
+	s.count++
+	for i, le := range buckets {
+		if seconds <= le {
+			s.buckets[i]++
+		}
+	}
+}
+
+// countMsg returns a stream hook incrementing counter for each message actually received or sent
+func (s *series) countMsg(counter *uint64) streamutil.MsgHook {
+	return func(m interface{}, next streamutil.MsgHandler) error {
+		err := next(m)
+		if err == nil {
+			s.mu.Lock()
+			*counter++
+			s.mu.Unlock()
+		}
+		return err
+	}
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/authorization"
+)
+
+type dummyMetrics struct {
+	foobar.UnimplementedDummyServiceServer
+}
+
+func (d *dummyMetrics) Foo(ctx context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if len(md.Get("fail")) > 0 {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return &foobar.Empty{}, nil
+}
+
+func (d *dummyMetrics) FooS(s foobar.DummyService_FooSServer) error {
+	for {
+		if _, err := s.Recv(); err != nil {
+			return nil
+		}
+		if err := s.Send(&foobar.Empty{}); err != nil {
+			return err
+		}
+	}
+}
+
+func write(m *Metrics) string {
+	var buf bytes.Buffer
+	_ = m.Write(&buf)
+	return buf.String()
+}
+
+func TestNew(t *testing.T) {
+	invalids := map[string]Option{
+		"an empty namespace":                  WithNamespace(""),
+		"an invalid namespace":                WithNamespace("my-service"),
+		"empty buckets":                       WithBuckets(),
+		"decreasing buckets":                  WithBuckets(1, 0.5),
+		"a zero max methods":                  WithMaxMethods(0),
+		"no authorization methods":            WithAuthorizationMethodLabel(),
+		"an empty authorization method label": WithAuthorizationMethodLabel(""),
+	}
+	for name, opt := range invalids {
+		m, err := New(opt)
+		assert.Nil(t, m, "New() should not return a Metrics with %s", name)
+		assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with %s", name)
+	}
+	m, err := New()
+	assert.Nil(t, err, "New() should not return an error without options")
+	assert.Equal(t, DefaultBuckets, m.buckets, "New() should use default buckets")
+}
+
+func TestMetrics_Interceptors(t *testing.T) {
+	m, _ := New(WithNamespace("test"), WithBuckets(1, 10), WithAuthorizationMethodLabel("bearer"))
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(m.UnaryInterceptor()),
+		grpc.StreamInterceptor(m.StreamInterceptor()),
+	}
+	ctx, _ := authorization.AppendToOutgoingContext(context.Background(), "bearer", "42")
+	_, _, _, err := utils.TestCallFoo(t, &dummyMetrics{}, nil, opts, ctx)
+	assert.Nil(t, err, "call should not fail")
+	_, _, _, err = utils.TestCallFoo(t, &dummyMetrics{}, nil, opts, metadata.AppendToOutgoingContext(ctx, "fail", "yes"))
+	assert.Equal(t, codes.NotFound, status.Code(err), "call should fail")
+	_, _, _, _ = utils.TestCallFoo(t, &dummyMetrics{}, nil, opts)
+	ctx, _ = authorization.AppendToOutgoingContext(context.Background(), "basic", "42")
+	utils.TestCallFooS(t, &dummyMetrics{}, nil, opts, ctx)
+
+	out := write(m)
+	expected := []string{
+		"# TYPE test_grpc_server_started_total counter",
+		`test_grpc_server_started_total{grpc_type="unary",grpc_service="foobar.DummyService",grpc_method="Foo",auth_method="bearer"} 2`,
+		`test_grpc_server_started_total{grpc_type="unary",grpc_service="foobar.DummyService",grpc_method="Foo",auth_method="none"} 1`,
+		`test_grpc_server_handled_total{grpc_type="unary",grpc_service="foobar.DummyService",grpc_method="Foo",auth_method="bearer",grpc_code="OK"} 1`,
+		`test_grpc_server_handled_total{grpc_type="unary",grpc_service="foobar.DummyService",grpc_method="Foo",auth_method="bearer",grpc_code="NotFound"} 1`,
+		"# TYPE test_grpc_server_handling_seconds histogram",
+		`test_grpc_server_handling_seconds_bucket{grpc_type="unary",grpc_service="foobar.DummyService",grpc_method="Foo",auth_method="bearer",le="1"} 2`,
+		`test_grpc_server_handling_seconds_bucket{grpc_type="unary",grpc_service="foobar.DummyService",grpc_method="Foo",auth_method="bearer",le="+Inf"} 2`,
+		`test_grpc_server_handling_seconds_count{grpc_type="unary",grpc_service="foobar.DummyService",grpc_method="Foo",auth_method="bearer"} 2`,
+		`test_grpc_server_in_flight{grpc_type="unary",grpc_service="foobar.DummyService",grpc_method="Foo",auth_method="bearer"} 0`,
+		`test_grpc_server_handled_total{grpc_type="bidi_stream",grpc_service="foobar.DummyService",grpc_method="FooS",auth_method="other",grpc_code="OK"} 1`,
+		`test_grpc_server_msg_received_total{grpc_type="bidi_stream",grpc_service="foobar.DummyService",grpc_method="FooS",auth_method="other"} 6`,
+		`test_grpc_server_msg_sent_total{grpc_type="bidi_stream",grpc_service="foobar.DummyService",grpc_method="FooS",auth_method="other"} 6`,
+	}
+	for _, line := range expected {
+		assert.Contains(t, out, line+"\n", "metrics should contain %s", line)
+	}
+	assert.NotContains(t, out, `test_grpc_server_msg_received_total{grpc_type="unary"`, "unary calls should not have messages counters")
+}
+
+func TestWithMaxMethods(t *testing.T) {
+	m, _ := New(WithMaxMethods(1))
+	for _, method := range []string{"/foobar.DummyService/Foo", "/foobar.DummyService/Bar", "/foobar.DummyService/Foo"} {
+		_, _ = m.UnaryInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	}
+	out := write(m)
+	assert.Contains(t, out, `grpc_server_started_total{grpc_type="unary",grpc_service="foobar.DummyService",grpc_method="Foo"} 2`+"\n", "methods below the limit should have their own series")
+	assert.Contains(t, out, `grpc_server_started_total{grpc_type="unary",grpc_service="other",grpc_method="other"} 1`+"\n", "methods above the limit should be labeled other")
+	assert.NotContains(t, out, `grpc_method="Bar"`, "methods above the limit should not have their own series")
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m, _ := New()
+	_, _ = m.UnaryInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/foobar.DummyService/Foo"}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"), "Handler() should set the text exposition content type")
+	assert.Equal(t, write(m), rec.Body.String(), "Handler() should write metrics")
+}
+
+func Test_escapeLabelValue(t *testing.T) {
+	assert.Equal(t, `a\\b\"c\nd`, escapeLabelValue("a\\b\"c\nd"), "escapeLabelValue() should escape backslashes, quotes and new lines")
+}
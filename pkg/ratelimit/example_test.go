@@ -0,0 +1,53 @@
+package ratelimit_test
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/ratelimit"
+)
+
+// ExampleNew limits authenticated users to 10 calls per second, anonymous clients by remote IP
+// address, with a stricter limit on an expensive method
+func ExampleNew() {
+	l, err := ratelimit.New(
+		ratelimit.WithLimit(ratelimit.PerSecond(10)),
+		ratelimit.WithMethodLimit("/foobar.DummyService/FooS", ratelimit.PerMinute(5)),
+		ratelimit.WithKeyFunc(
+			// *User being the type returned by the authorization CredentialValidator
+			ratelimit.KeyByPrincipal(func(u *User) string { return u.ID }),
+			ratelimit.KeyByRemoteIP,
+		),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		// authorization interceptors must be before ratelimit's ones to use KeyByPrincipal
+		grpc.UnaryInterceptor(l.UnaryInterceptor()),
+		grpc.StreamInterceptor(l.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
+
+// ExampleGetRetryAfterFromMeta shows how a client can know when to retry a rate limited call
+func ExampleGetRetryAfterFromMeta() {
+	var trailer metadata.MD
+	_, err := client.Foo(ctx, &foobar.Empty{}, grpc.Trailer(&trailer))
+	if err != nil {
+		if retryAfter, ok := ratelimit.GetRetryAfterFromMeta(trailer); ok {
+			// Call has been rate limited and can be retried after retryAfter
+			_ = retryAfter
+		}
+	}
+}
+
+var ctx context.Context
+var client foobar.DummyServiceClient
+
+type User struct {
+	ID string
+}
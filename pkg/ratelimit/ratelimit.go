@@ -0,0 +1,385 @@
+// Package ratelimit limits the rate of gRPC calls per client, clients being identified by their
+// remote IP address, their authenticated principal or any custom key.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/methodpattern"
+	"github.com/jucrouzet/grpcutils/pkg/authorization"
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+	"github.com/jucrouzet/grpcutils/pkg/remoteaddr"
+)
+
+const (
+	// RetryAfterMetadataName is the name of the trailer metadata that holds the number of seconds
+	// to wait before retrying a rate limited call.
+	RetryAfterMetadataName = "retry-after"
+	// MethodAll is the method pattern matching all methods
+	MethodAll = "*"
+	// DefaultIdleTimeout is the default duration after which an unused client key is forgotten
+	DefaultIdleTimeout = 10 * time.Minute
+)
+
+var (
+	// ErrInvalidOptionValue is returned when using an invalid option value
+	ErrInvalidOptionValue = errors.New("invalid option value")
+	// ErrNoKey is returned by KeyFunc functions that cannot identify the client
+	ErrNoKey = errors.New("no rate limiting key")
+)
+
+// Limit is a token bucket limit : Burst calls can be made at once, then Rate calls per second.
+type Limit struct {
+	// Rate is the number of calls allowed per second
+	Rate float64
+	// Burst is the maximum number of calls allowed at once
+	Burst int
+}
+
+// Unlimited is a Limit that never limits calls
+var Unlimited = Limit{Rate: math.Inf(1)}
+
+// PerSecond returns a Limit of n calls per second, with a burst of n calls
+func PerSecond(n int) Limit {
+	return Limit{Rate: float64(n), Burst: n}
+}
+
+// PerMinute returns a Limit of n calls per minute, with a burst of n calls
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// KeyFunc is the function type for functions that identify the client of a call.
+// ErrNoKey, or any other error, should be returned if the client cannot be identified.
+type KeyFunc func(ctx context.Context, fullMethod string) (string, error)
+
+// KeyByRemoteIP is a KeyFunc identifying clients by their remote IP address.
+func KeyByRemoteIP(ctx context.Context, _ string) (string, error) {
+	ip, err := remoteaddr.GetIPFromContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNoKey, err.Error())
+	}
+	return "ip:" + ip.String(), nil
+}
+
+// KeyByPrincipal returns a KeyFunc identifying clients by their authenticated principal, as set by
+// the github.com/jucrouzet/grpcutils/pkg/authorization interceptors.
+// T must be the type returned by the authorization CredentialValidator, keyOf returning the unique
+// key of a principal (like the user identifier).
+func KeyByPrincipal[T any](keyOf func(principal T) string) KeyFunc {
+	fn := func(ctx context.Context, _ string) (string, error) {
+		var principal T
+		if err := authorization.GetFromContext(ctx, &principal); err != nil {
+			return "", fmt.Errorf("%w: %s", ErrNoKey, err.Error())
+		}
+		key := keyOf(principal)
+		if key == "" {
+			return "", ErrNoKey
+		}
+		return "principal:" + key, nil
+	}
+	principalKeyFuncs.Store(reflect.ValueOf(fn).Pointer(), struct{}{})
+	return fn
+}
+
+// principalKeyFuncs holds the code pointers of the KeyFunc functions returned by KeyByPrincipal, so
+// that limiters can tell whether they depend on authorization.
+var principalKeyFuncs sync.Map
+
+// Limiter limits the rate of calls per client.
+//
+// Each client, identified by the first KeyFunc returning a key, has its own token bucket for
+// each method pattern having a specific limit and one shared by all the other methods.
+// Calls from clients that cannot be identified are rejected, unless WithUnidentifiedKey is used.
+type Limiter struct {
+	limit           Limit
+	methodLimits    map[string]Limit
+	keyFuncs        []KeyFunc
+	byPrincipal     bool
+	unidentifiedKey string
+	idleTimeout     time.Duration
+	now             func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+// Option is the Limiter option functions type
+type Option func(*Limiter) error
+
+type bucketKey struct {
+	pattern string
+	key     string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// WithLimit sets the limit of calls to methods that have no specific limit.
+// If not set, calls to methods without specific limit are not limited.
+func WithLimit(limit Limit) Option {
+	return func(l *Limiter) error {
+		if err := validateLimit(limit); err != nil {
+			return err
+		}
+		l.limit = limit
+		return nil
+	}
+}
+
+// WithMethodLimit sets a specific limit for the methods matching `method`, like
+// "/package.Service/Method" or "/package.Service/*".
+// Methods with a specific limit have their own token buckets, calls to them are not counted in
+// the WithLimit limit.
+func WithMethodLimit(method string, limit Limit) Option {
+	return func(l *Limiter) error {
+		if err := methodpattern.Validate(method); err != nil {
+			return err
+		}
+		if err := validateLimit(limit); err != nil {
+			return err
+		}
+		l.methodLimits[method] = limit
+		return nil
+	}
+}
+
+// WithKeyFunc sets the functions used to identify the client of a call, the first one returning
+// a key being used.
+// If not set, clients are identified by KeyByRemoteIP.
+func WithKeyFunc(fns ...KeyFunc) Option {
+	return func(l *Limiter) error {
+		for _, fn := range fns {
+			if fn == nil {
+				return errors.New("cannot use a nil key function")
+			}
+		}
+		for _, fn := range fns {
+			if _, ok := principalKeyFuncs.Load(reflect.ValueOf(fn).Pointer()); ok {
+				l.byPrincipal = true
+			}
+		}
+		l.keyFuncs = append(l.keyFuncs, fns...)
+		return nil
+	}
+}
+
+// WithUnidentifiedKey makes calls from clients that no KeyFunc identifies share the buckets of
+// key, instead of being rejected with a codes.FailedPrecondition error.
+// As these clients share their limits, one of them can exhaust them for all the others.
+func WithUnidentifiedKey(key string) Option {
+	return func(l *Limiter) error {
+		if key == "" {
+			return errors.New("unidentified key cannot be empty")
+		}
+		l.unidentifiedKey = key
+		return nil
+	}
+}
+
+// WithIdleTimeout sets the duration after which a client key that has not been used is forgotten,
+// bounding the memory used by the limiter.
+// If not set, DefaultIdleTimeout is used.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(l *Limiter) error {
+		if timeout <= 0 {
+			return errors.New("idle timeout must be positive")
+		}
+		l.idleTimeout = timeout
+		return nil
+	}
+}
+
+// New creates a new instance of Limiter with specified options
+func New(opts ...Option) (*Limiter, error) {
+	l := &Limiter{
+		limit:        Unlimited,
+		methodLimits: make(map[string]Limit),
+		idleTimeout:  DefaultIdleTimeout,
+		now:          time.Now,
+		buckets:      make(map[bucketKey]*bucket),
+	}
+	for _, opt := range opts {
+		if err := opt(l); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	if len(l.keyFuncs) == 0 {
+		l.keyFuncs = []KeyFunc{KeyByRemoteIP}
+	}
+	l.lastSweep = l.now()
+	return l, nil
+}
+
+// GetRetryAfterFromMeta returns the duration to wait before retrying a rate limited call, from
+// the call's trailer metadata.
+// ok is false if the call was not rate limited.
+func GetRetryAfterFromMeta(md metadata.MD) (time.Duration, bool) {
+	seconds, err := metadatautil.GetInt(md, RetryAfterMetadataName)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// KeysByPrincipal returns whether clients are identified with a KeyByPrincipal function, which
+// requires the authorization interceptors to be placed before the limiter ones.
+func (l *Limiter) KeysByPrincipal() bool {
+	return l.byPrincipal
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that rejects calls exceeding their
+// limit with a codes.ResourceExhausted error and a RetryAfterMetadataName trailer, and calls from
+// unidentified clients with a codes.FailedPrecondition error.
+func (l *Limiter) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		infos *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if retryAfter, err := l.allow(ctx, infos.FullMethod); err != nil {
+			if status.Code(err) == codes.ResourceExhausted {
+				_ = grpc.SetTrailer(ctx, retryAfterMeta(retryAfter))
+			}
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that rejects stream openings
+// exceeding their limit with a codes.ResourceExhausted error and a RetryAfterMetadataName trailer,
+// and stream openings from unidentified clients with a codes.FailedPrecondition error.
+func (l *Limiter) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		infos *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if retryAfter, err := l.allow(stream.Context(), infos.FullMethod); err != nil {
+			if status.Code(err) == codes.ResourceExhausted {
+				stream.SetTrailer(retryAfterMeta(retryAfter))
+			}
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+// allow takes a token from the bucket of a call, returning a codes.ResourceExhausted error with
+// the duration after which a token will be available if there is none, or a
+// codes.FailedPrecondition error if the client cannot be identified, which is a configuration
+// issue, like a missing authorization interceptor, rather than a client one.
+func (l *Limiter) allow(ctx context.Context, fullMethod string) (time.Duration, error) {
+	pattern, limit := l.limitFor(fullMethod)
+	if math.IsInf(limit.Rate, 1) {
+		return 0, nil
+	}
+	key, err := l.keyFor(ctx, fullMethod)
+	if err != nil {
+		return 0, status.Error(codes.FailedPrecondition, "cannot identify client for rate limiting")
+	}
+	bk := bucketKey{pattern: pattern, key: key}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[bk]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[bk] = b
+	}
+	if retryAfter, ok := b.take(now, limit); !ok {
+		return retryAfter, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return 0, nil
+}
+
+func (l *Limiter) limitFor(fullMethod string) (string, Limit) {
+	if pattern, limit, ok := methodpattern.Lookup(l.methodLimits, fullMethod); ok {
+		return pattern, limit
+	}
+	return MethodAll, l.limit
+}
+
+// keyFor returns the key of the client of a call, or ErrNoKey if no KeyFunc identifies it and
+// there is no unidentified key.
+func (l *Limiter) keyFor(ctx context.Context, fullMethod string) (string, error) {
+	for _, fn := range l.keyFuncs {
+		if key, err := fn(ctx, fullMethod); err == nil {
+			return key, nil
+		}
+	}
+	if l.unidentifiedKey != "" {
+		return l.unidentifiedKey, nil
+	}
+	return "", ErrNoKey
+}
+
+// sweep forgets buckets that have not been used since idleTimeout, at most once per idleTimeout.
+// Must be called with l.mu locked.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idleTimeout {
+		return
+	}
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= l.idleTimeout {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+// take refills the bucket and takes a token from it if possible.
+// If not, it returns the duration after which a token will be available.
+func (b *bucket) take(now time.Time, limit Limit) (time.Duration, bool) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), false
+}
+
+func retryAfterMeta(retryAfter time.Duration) metadata.MD {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return metadata.Pairs(RetryAfterMetadataName, strconv.Itoa(seconds))
+}
+
+func validateLimit(limit Limit) error {
+	if math.IsInf(limit.Rate, 1) {
+		return nil
+	}
+	if limit.Rate <= 0 || math.IsNaN(limit.Rate) {
+		return errors.New("limit rate must be positive")
+	}
+	if limit.Burst < 1 {
+		return errors.New("limit burst must be at least 1")
+	}
+	return nil
+}
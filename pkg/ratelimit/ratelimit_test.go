@@ -0,0 +1,294 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/authorization"
+	"github.com/jucrouzet/grpcutils/pkg/grpcutilstest"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newWithClock(t *testing.T, opts ...Option) (*Limiter, *fakeClock) {
+	l, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Unix(1655107200, 0)}
+	l.now = clock.Now
+	l.lastSweep = clock.now
+	return l, clock
+}
+
+func ipContext(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 1234}})
+}
+
+func TestNew(t *testing.T) {
+	invalids := map[string]Option{
+		"a zero rate":           WithLimit(Limit{Rate: 0, Burst: 1}),
+		"a zero burst":          WithLimit(Limit{Rate: 1, Burst: 0}),
+		"an invalid method":     WithMethodLimit("Foo", PerSecond(1)),
+		"an invalid limit":      WithMethodLimit("/foobar.DummyService/Foo", Limit{Rate: -1, Burst: 1}),
+		"a nil key function":    WithKeyFunc(nil),
+		"a zero idle timeout":   WithIdleTimeout(0),
+		"a negative idle limit": WithIdleTimeout(-time.Second),
+		"an empty unidentified": WithUnidentifiedKey(""),
+	}
+	for name, opt := range invalids {
+		l, err := New(opt)
+		assert.Nil(t, l, "New() should not return a Limiter with %s", name)
+		assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with %s", name)
+	}
+
+	l, err := New()
+	assert.Nil(t, err, "New() should not return an error without options")
+	_, err = l.allow(ipContext("192.0.2.1"), "/foobar.DummyService/Foo")
+	assert.Nil(t, err, "Limiter without limit should not limit calls")
+}
+
+func TestLimiter_allow(t *testing.T) {
+	l, clock := newWithClock(t,
+		WithLimit(Limit{Rate: 1, Burst: 2}),
+		WithMethodLimit("/foobar.DummyService/*", PerMinute(1)),
+		WithMethodLimit("/foobar.DummyService/Foo", Unlimited),
+	)
+	ctx := ipContext("192.0.2.1")
+
+	_, err := l.allow(ctx, "/other.Service/A")
+	assert.Nil(t, err, "first call should be allowed")
+	_, err = l.allow(ctx, "/other.Service/B")
+	assert.Nil(t, err, "call within burst should be allowed")
+	retryAfter, err := l.allow(ctx, "/other.Service/A")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "call exceeding burst should not be allowed")
+	assert.Equal(t, time.Second, retryAfter, "retry after should be the time to get a new token")
+
+	_, err = l.allow(ipContext("192.0.2.2"), "/other.Service/A")
+	assert.Nil(t, err, "calls of other clients should not be limited")
+
+	clock.Add(500 * time.Millisecond)
+	retryAfter, err = l.allow(ctx, "/other.Service/A")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "call before refill should not be allowed")
+	assert.Equal(t, 500*time.Millisecond, retryAfter, "retry after should be the time to get a new token")
+	clock.Add(500 * time.Millisecond)
+	_, err = l.allow(ctx, "/other.Service/A")
+	assert.Nil(t, err, "call after refill should be allowed")
+
+	_, err = l.allow(ctx, "/foobar.DummyService/FooS")
+	assert.Nil(t, err, "method limit should have its own bucket")
+	retryAfter, err = l.allow(ctx, "/foobar.DummyService/FooS")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "method limit should be used")
+	assert.Equal(t, time.Minute, retryAfter, "retry after should be the time to get a new token")
+
+	for i := 0; i < 10; i++ {
+		_, err = l.allow(ctx, "/foobar.DummyService/Foo")
+		assert.Nil(t, err, "unlimited method should not be limited")
+	}
+}
+
+func TestLimiter_keys(t *testing.T) {
+	validator := func(_ context.Context, credential string) (any, error) {
+		return credential, nil
+	}
+	a, _ := authorization.New(authorization.WithMethodFunction("user", validator))
+	withPrincipal := func(ctx context.Context, user string) context.Context {
+		var res context.Context
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(authorization.MetadataName, "user "+user))
+		_, _ = a.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			res = ctx
+			return nil, nil
+		})
+		return res
+	}
+
+	l, _ := newWithClock(t,
+		WithLimit(PerMinute(1)),
+		WithKeyFunc(
+			KeyByPrincipal(func(user string) string { return user }),
+			KeyByRemoteIP,
+		),
+	)
+	method := "/foobar.DummyService/Foo"
+
+	_, err := l.allow(withPrincipal(ipContext("192.0.2.1"), "alice"), method)
+	assert.Nil(t, err, "first call of principal should be allowed")
+	_, err = l.allow(withPrincipal(ipContext("192.0.2.2"), "alice"), method)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "principal should be limited whatever its remote address")
+	_, err = l.allow(withPrincipal(ipContext("192.0.2.1"), "bob"), method)
+	assert.Nil(t, err, "other principal should not be limited")
+
+	_, err = l.allow(ipContext("192.0.2.1"), method)
+	assert.Nil(t, err, "anonymous calls should be limited by remote address")
+	_, err = l.allow(ipContext("192.0.2.1"), method)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "anonymous calls should be limited by remote address")
+
+	_, err = l.allow(context.Background(), method)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "unidentified clients should be rejected")
+
+	_, err = KeyByPrincipal(func(user string) string { return user })(context.Background(), method)
+	assert.ErrorIs(t, err, ErrNoKey, "KeyByPrincipal() should return a ErrNoKey error without principal")
+	_, err = KeyByRemoteIP(context.Background(), method)
+	assert.ErrorIs(t, err, ErrNoKey, "KeyByRemoteIP() should return a ErrNoKey error without remote address")
+}
+
+func TestLimiter_KeysByPrincipal(t *testing.T) {
+	l, _ := New()
+	assert.False(t, l.KeysByPrincipal(), "KeysByPrincipal() should be false by default")
+	l, _ = New(WithKeyFunc(KeyByRemoteIP, func(context.Context, string) (string, error) { return "custom", nil }))
+	assert.False(t, l.KeysByPrincipal(), "KeysByPrincipal() should be false without KeyByPrincipal functions")
+	l, _ = New(WithKeyFunc(KeyByPrincipal(func(id int) string { return strconv.Itoa(id) }), KeyByRemoteIP))
+	assert.True(t, l.KeysByPrincipal(), "KeysByPrincipal() should be true with a KeyByPrincipal function")
+	l, _ = New(WithKeyFunc(KeyByPrincipal(func(user *struct{ ID string }) string { return user.ID })))
+	assert.True(t, l.KeysByPrincipal(), "KeysByPrincipal() should be true whatever the principal type")
+}
+
+func TestWithUnidentifiedKey(t *testing.T) {
+	l, _ := newWithClock(t, WithLimit(PerMinute(1)), WithUnidentifiedKey("unidentified"))
+	method := "/foobar.DummyService/Foo"
+	_, err := l.allow(context.Background(), method)
+	assert.Nil(t, err, "unidentified clients should not be rejected")
+	_, err = l.allow(context.Background(), method)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "unidentified clients should share a bucket")
+	_, err = l.allow(ipContext("192.0.2.1"), method)
+	assert.Nil(t, err, "identified clients should not share the unidentified bucket")
+}
+
+func TestLimiter_sweep(t *testing.T) {
+	l, clock := newWithClock(t, WithLimit(PerSecond(1)), WithIdleTimeout(time.Minute))
+
+	l.allow(ipContext("192.0.2.1"), "/foobar.DummyService/Foo")
+	clock.Add(30 * time.Second)
+	l.allow(ipContext("192.0.2.2"), "/foobar.DummyService/Foo")
+	assert.Equal(t, 2, len(l.buckets), "buckets should be kept before idle timeout")
+
+	clock.Add(30 * time.Second)
+	l.allow(ipContext("192.0.2.3"), "/foobar.DummyService/Foo")
+	assert.Equal(t, 2, len(l.buckets), "idle buckets should be forgotten")
+	_, ok := l.buckets[bucketKey{pattern: MethodAll, key: "ip:192.0.2.1"}]
+	assert.False(t, ok, "idle buckets should be forgotten")
+}
+
+func TestGetRetryAfterFromMeta(t *testing.T) {
+	_, ok := GetRetryAfterFromMeta(metadata.MD{})
+	assert.False(t, ok, "GetRetryAfterFromMeta() should return false without metadata")
+	_, ok = GetRetryAfterFromMeta(metadata.Pairs(RetryAfterMetadataName, "soon"))
+	assert.False(t, ok, "GetRetryAfterFromMeta() should return false with an invalid metadata")
+	d, ok := GetRetryAfterFromMeta(metadata.Pairs(RetryAfterMetadataName, "42"))
+	assert.True(t, ok, "GetRetryAfterFromMeta() should return true with a valid metadata")
+	assert.Equal(t, 42*time.Second, d, "GetRetryAfterFromMeta() should return the duration")
+}
+
+type dummyRateLimit struct {
+	foobar.UnimplementedDummyServiceServer
+}
+
+func (d *dummyRateLimit) Foo(_ context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	return &foobar.Empty{}, nil
+}
+
+func TestLimiter_UnaryInterceptor(t *testing.T) {
+	// bufconn addresses are not IP addresses
+	l, _ := newWithClock(t, WithLimit(PerMinute(1)))
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(l.UnaryInterceptor()),
+	}
+	_, _, trailer, err := utils.TestCallFoo(t, &dummyRateLimit{}, nil, opts)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "UnaryInterceptor() should reject calls from unidentified clients")
+	_, ok := GetRetryAfterFromMeta(trailer)
+	assert.False(t, ok, "UnaryInterceptor() should not set retry after trailer on unidentified calls")
+
+	l, _ = newWithClock(t, WithLimit(PerMinute(1)), WithUnidentifiedKey("unidentified"))
+	opts = []grpc.ServerOption{
+		grpc.UnaryInterceptor(l.UnaryInterceptor()),
+	}
+	_, _, trailer, err = utils.TestCallFoo(t, &dummyRateLimit{}, nil, opts)
+	assert.Nil(t, err, "UnaryInterceptor() should allow calls within limit")
+	_, ok = GetRetryAfterFromMeta(trailer)
+	assert.False(t, ok, "UnaryInterceptor() should not set retry after trailer on allowed calls")
+
+	_, _, trailer, err = utils.TestCallFoo(t, &dummyRateLimit{}, nil, opts)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "UnaryInterceptor() should reject calls exceeding limit")
+	d, ok := GetRetryAfterFromMeta(trailer)
+	assert.True(t, ok, "UnaryInterceptor() should set retry after trailer on rejected calls")
+	assert.Equal(t, time.Minute, d, "UnaryInterceptor() should set retry after trailer on rejected calls")
+}
+
+func TestLimiter_UnaryInterceptor_ipv6(t *testing.T) {
+	l, _ := newWithClock(t, WithLimit(PerMinute(1)))
+	call := func(ip string) error {
+		s := grpcutilstest.New(t, func(server *grpc.Server) {
+			foobar.RegisterDummyServiceServer(server, &dummyRateLimit{})
+		},
+			grpcutilstest.WithServerOptions(grpc.UnaryInterceptor(l.UnaryInterceptor())),
+			grpcutilstest.WithPeerAddr(&net.TCPAddr{IP: net.ParseIP(ip), Port: 443}),
+		)
+		_, err := foobar.NewDummyServiceClient(s.Conn).Foo(context.Background(), &foobar.Empty{})
+		return err
+	}
+	assert.Nil(t, call("2001:db8::1"), "UnaryInterceptor() should identify IPv6 clients by their address")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call("2001:db8::1")), "UnaryInterceptor() should limit IPv6 clients")
+	assert.Nil(t, call("2001:db8::2"), "UnaryInterceptor() should limit IPv6 clients separately")
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	trailer metadata.MD
+}
+
+func (f *fakeStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeStream) SetTrailer(md metadata.MD) {
+	f.trailer = metadata.Join(f.trailer, md)
+}
+
+func TestLimiter_StreamInterceptor(t *testing.T) {
+	l, _ := newWithClock(t, WithLimit(Limit{Rate: 0.5, Burst: 1}))
+	infos := &grpc.StreamServerInfo{FullMethod: "/foobar.DummyService/FooS"}
+	calls := 0
+	handler := func(interface{}, grpc.ServerStream) error {
+		calls++
+		return nil
+	}
+
+	stream := &fakeStream{ctx: ipContext("192.0.2.1")}
+	err := l.StreamInterceptor()(nil, stream, infos, handler)
+	assert.Nil(t, err, "StreamInterceptor() should allow streams within limit")
+	assert.Equal(t, 1, calls, "StreamInterceptor() should call handler for allowed streams")
+
+	err = l.StreamInterceptor()(nil, stream, infos, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "StreamInterceptor() should reject streams exceeding limit")
+	assert.Equal(t, 1, calls, "StreamInterceptor() should not call handler for rejected streams")
+	d, ok := GetRetryAfterFromMeta(stream.trailer)
+	assert.True(t, ok, "StreamInterceptor() should set retry after trailer on rejected streams")
+	assert.Equal(t, 2*time.Second, d, "StreamInterceptor() should set retry after trailer on rejected streams")
+
+	err = l.StreamInterceptor()(nil, &fakeStream{ctx: context.Background()}, infos, handler)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "StreamInterceptor() should reject streams from unidentified clients")
+	assert.Equal(t, 1, calls, "StreamInterceptor() should not call handler for unidentified clients")
+}
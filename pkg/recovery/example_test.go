@@ -0,0 +1,59 @@
+package recovery_test
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/recovery"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// ExampleNew recovers from panics, logging them with the request logger
+func ExampleNew() {
+	l, err := zaplogger.New(
+		zaplogger.WithFields(zaplogger.FieldMethod, zaplogger.FieldRequestID),
+	)
+	if err != nil {
+		panic(err)
+	}
+	r, err := recovery.New()
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		// recovery interceptors must be after zaplogger's ones to log with the request logger
+		grpc.ChainUnaryInterceptor(
+			requestid.UnaryServerInterceptor,
+			l.UnaryInterceptor(),
+			r.UnaryInterceptor(),
+		),
+		grpc.ChainStreamInterceptor(
+			requestid.StreamServerInterceptor,
+			l.StreamInterceptor(),
+			r.StreamInterceptor(),
+		),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
+
+// ExampleWithHandler maps some panic values to specific statuses
+func ExampleWithHandler() {
+	_, err := recovery.New(
+		recovery.WithHandler(func(ctx context.Context, fullMethod string, recovered interface{}) error {
+			if err, ok := recovered.(error); ok && errors.Is(err, context.DeadlineExceeded) {
+				return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+			}
+			// Default codes.Internal error
+			return nil
+		}),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,119 @@
+// Package recovery recovers from panics in gRPC method handlers, converting them to errors and
+// logging them instead of crashing the server.
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+var (
+	// ErrInvalidOptionValue is returned when using an invalid option value
+	ErrInvalidOptionValue = errors.New("invalid option value")
+)
+
+// Handler is the function type for functions that convert a recovered panic value to the error
+// returned to the client.
+// If nil is returned, the default codes.Internal error is used.
+type Handler func(ctx context.Context, fullMethod string, recovered interface{}) error
+
+// Recovery recovers from panics in gRPC method handlers.
+type Recovery struct {
+	handler Handler
+	logger  *zaplogger.Logger
+}
+
+// Option is the Recovery option functions type
+type Option func(*Recovery) error
+
+// WithHandler sets the function converting panic values to errors returned to clients.
+// If not set, a codes.Internal error is returned.
+func WithHandler(fn Handler) Option {
+	return func(r *Recovery) error {
+		if fn == nil {
+			return errors.New("cannot use a nil handler")
+		}
+		r.handler = fn
+		return nil
+	}
+}
+
+// WithLogger logs panics with the logger returned by logger.ForCall().
+// If not set, panics are only logged when the call context has a request logger (see
+// zaplogger.GetFromContext).
+func WithLogger(logger *zaplogger.Logger) Option {
+	return func(r *Recovery) error {
+		if logger == nil {
+			return errors.New("cannot use a nil logger")
+		}
+		r.logger = logger
+		return nil
+	}
+}
+
+// New creates a new instance of Recovery with specified options
+func New(opts ...Option) (*Recovery, error) {
+	r := &Recovery{}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	return r, nil
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that recovers from panics in following
+// interceptors and method handler.
+// To log panics with the call's request logger, it must be used after zaplogger's interceptor.
+func (r *Recovery) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		infos *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (res interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				res, err = nil, r.recovered(ctx, infos.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that recovers from panics in following
+// interceptors and method handler.
+// To log panics with the call's request logger, it must be used after zaplogger's interceptor.
+func (r *Recovery) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		infos *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = r.recovered(stream.Context(), infos.FullMethod, p)
+			}
+		}()
+		return handler(srv, stream)
+	}
+}
+
+func (r *Recovery) recovered(ctx context.Context, fullMethod string, p interface{}) error {
+	r.logger.ForCall(ctx, fullMethod).Error("recovered from panic", zap.Any("panic", p), zap.Stack("stack"))
+	if r.handler != nil {
+		if err := r.handler(ctx, fullMethod, p); err != nil {
+			return err
+		}
+	}
+	return status.Error(codes.Internal, "internal error")
+}
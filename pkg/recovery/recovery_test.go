@@ -0,0 +1,113 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+func TestNew(t *testing.T) {
+	r, err := New(WithHandler(nil))
+	assert.Nil(t, r, "New() should not return a Recovery with a nil handler")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with a nil handler")
+
+	r, err = New(WithLogger(nil))
+	assert.Nil(t, r, "New() should not return a Recovery with a nil logger")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with a nil logger")
+}
+
+type dummyPanic struct {
+	foobar.UnimplementedDummyServiceServer
+	value interface{}
+}
+
+func (d *dummyPanic) Foo(_ context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	panic(d.value)
+}
+
+func (d *dummyPanic) FooS(_ foobar.DummyService_FooSServer) error {
+	panic(d.value)
+}
+
+func TestRecovery_UnaryInterceptor(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := zaplogger.New(
+		zaplogger.WithLogger(zap.New(core)),
+		zaplogger.WithFields(zaplogger.FieldMethod, zaplogger.FieldRequestID),
+	)
+	r, _ := New()
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor, l.UnaryInterceptor(), r.UnaryInterceptor()),
+	}
+	ctx := requestid.AppendToOutgoingContext(context.Background(), "I'm a unique ID")
+	_, _, _, err := utils.TestCallFoo(t, &dummyPanic{value: "oops"}, nil, opts, ctx)
+	assert.Equal(t, codes.Internal, status.Code(err), "UnaryInterceptor() should convert panics to codes.Internal errors")
+
+	if assert.Equal(t, 1, len(recordedLogs.All()), "panic should be logged") {
+		entry := recordedLogs.All()[0]
+		fields := entry.ContextMap()
+		assert.Equal(t, zapcore.ErrorLevel, entry.Level, "panic should be logged as an error")
+		assert.Equal(t, "oops", fields["panic"], "panic value should be logged")
+		assert.Contains(t, fields["stack"], "recovery.(*dummyPanic).Foo", "panic stack should be logged")
+		assert.Equal(t, "I'm a unique ID", fields[zaplogger.FieldRequestID], "panic should be logged with request ID")
+		assert.Equal(t, "/foobar.DummyService/Foo", fields[zaplogger.FieldMethod], "panic should be logged with method")
+	}
+}
+
+func TestRecovery_WithHandler(t *testing.T) {
+	errBusy := errors.New("busy")
+	r, _ := New(WithHandler(func(_ context.Context, fullMethod string, recovered interface{}) error {
+		if err, ok := recovered.(error); ok && errors.Is(err, errBusy) {
+			return status.Errorf(codes.Unavailable, "%s is busy", fullMethod)
+		}
+		return nil
+	}))
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(r.UnaryInterceptor()),
+	}
+	_, _, _, err := utils.TestCallFoo(t, &dummyPanic{value: errBusy}, nil, opts)
+	assert.Equal(t, codes.Unavailable, status.Code(err), "UnaryInterceptor() should use handler error")
+	assert.Equal(t, "/foobar.DummyService/Foo is busy", status.Convert(err).Message(), "UnaryInterceptor() should use handler error")
+
+	_, _, _, err = utils.TestCallFoo(t, &dummyPanic{value: 42}, nil, opts)
+	assert.Equal(t, codes.Internal, status.Code(err), "UnaryInterceptor() should use codes.Internal when handler returns nil")
+}
+
+func TestRecovery_StreamInterceptor(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := zaplogger.New(zaplogger.WithLogger(zap.New(core)))
+	r, _ := New(WithLogger(l))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestid.MetadataName, "I'm a unique ID"))
+	stream := &streamutil.ServerStream{Ctx: ctx}
+	infos := &grpc.StreamServerInfo{FullMethod: "/foobar.DummyService/FooS"}
+	err := r.StreamInterceptor()(&dummyPanic{value: "oops"}, stream, infos, func(srv interface{}, _ grpc.ServerStream) error {
+		return srv.(*dummyPanic).FooS(nil)
+	})
+	assert.Equal(t, codes.Internal, status.Code(err), "StreamInterceptor() should convert panics to codes.Internal errors")
+	if assert.Equal(t, 1, len(recordedLogs.All()), "panic should be logged with fallback logger") {
+		fields := recordedLogs.All()[0].ContextMap()
+		assert.Equal(t, "I'm a unique ID", fields[zaplogger.FieldRequestID], "panic should be logged with request ID")
+		assert.Equal(t, "/foobar.DummyService/FooS", fields[zaplogger.FieldMethod], "panic should be logged with method")
+	}
+
+	err = r.StreamInterceptor()(nil, stream, infos, func(interface{}, grpc.ServerStream) error {
+		return nil
+	})
+	assert.Nil(t, err, "StreamInterceptor() should not change result of streams without panic")
+}
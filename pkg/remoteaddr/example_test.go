@@ -3,7 +3,12 @@ package remoteaddr_test
 import (
 	"context"
 	"fmt"
+	"log"
+	"time"
 
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
 	"github.com/jucrouzet/grpcutils/pkg/remoteaddr"
 )
 
@@ -18,4 +23,26 @@ func ExampleGetFromContext() {
 	fmt.Println(addr)
 }
 
+// ExampleNewFilter restricts an admin service to office and VPN networks, with rules reloaded
+// from a file when it changes
+func ExampleNewFilter() {
+	f, err := remoteaddr.NewFilter(
+		remoteaddr.WithAllow("/admin.Service/*", "192.168.0.0/16", "10.8.0.0/24"),
+		remoteaddr.WithDeny(remoteaddr.MethodAll, "203.0.113.7"),
+		remoteaddr.WithRulesFile("/etc/myservice/ip-rules"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	go f.Watch(ctx, 10*time.Second, func(err error) {
+		log.Printf("failed reloading IP rules: %s", err)
+	})
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(f.UnaryInterceptor()),
+		grpc.StreamInterceptor(f.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
+
 var ctx context.Context
@@ -0,0 +1,388 @@
+package remoteaddr
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/methodpattern"
+)
+
+// Action is the decision taken for a remote address matching a filtering rule
+type Action int
+
+const (
+	// ActionAllow allows calls from matching remote addresses
+	ActionAllow Action = iota + 1
+	// ActionDeny denies calls from matching remote addresses
+	ActionDeny
+)
+
+// String returns the action name, as used in rules files
+func (a Action) String() string {
+	switch a {
+	case ActionAllow:
+		return "allow"
+	case ActionDeny:
+		return "deny"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// MethodAll is the method pattern matching all methods
+const MethodAll = "*"
+
+var (
+	// ErrInvalidOptionValue is returned when using an invalid option value
+	ErrInvalidOptionValue = errors.New("invalid option value")
+	// ErrInvalidRule is returned when a filtering rule is invalid
+	ErrInvalidRule = errors.New("invalid filtering rule")
+)
+
+// Rule is a remote address filtering rule.
+type Rule struct {
+	// Action is the action to take when the remote address is in Network
+	Action Action
+	// Method is the pattern of methods the rule applies to : MethodAll for all methods,
+	// "/package.Service/*" for all the methods of a service or "/package.Service/Method"
+	// for a single method.
+	Method string
+	// Network is the network, in CIDR notation ("10.0.0.0/8") or as a single IP address ("10.1.2.3")
+	Network string
+}
+
+// Filter allows or denies calls depending on the client's remote IP address.
+//
+// Rules are grouped by method pattern and, for a given call, only the most specific pattern
+// having rules is used (method, then service, then MethodAll).
+// Within a pattern, the rule with the longest network containing the remote address decides.
+// If no rule matches, the call is denied if the pattern has at least one ActionAllow rule
+// (allowlist), and allowed otherwise (denylist).
+// Calls to methods having no rules are always allowed.
+type Filter struct {
+	rules     []Rule
+	rulesFile string
+
+	mu      sync.RWMutex
+	sets    map[string]*ruleSet
+	modTime time.Time
+}
+
+// FilterOption is the Filter option functions type
+type FilterOption func(*Filter) error
+
+type ruleSet struct {
+	trie     *cidrTrie
+	hasAllow bool
+}
+
+// WithRules adds rules to the filter
+func WithRules(rules ...Rule) FilterOption {
+	return func(f *Filter) error {
+		for _, r := range rules {
+			if _, err := parseRule(r); err != nil {
+				return err
+			}
+		}
+		f.rules = append(f.rules, rules...)
+		return nil
+	}
+}
+
+// WithAllow adds ActionAllow rules for networks on the methods matching `method`, like
+// "/package.Service/Method" or "/package.Service/*", or MethodAll.
+func WithAllow(method string, networks ...string) FilterOption {
+	return WithRules(rulesFor(ActionAllow, method, networks)...)
+}
+
+// WithDeny adds ActionDeny rules for networks on the methods matching `method`, like
+// "/package.Service/Method" or "/package.Service/*", or MethodAll.
+func WithDeny(method string, networks ...string) FilterOption {
+	return WithRules(rulesFor(ActionDeny, method, networks)...)
+}
+
+// WithRulesFile reads rules from a file, in addition to the rules set by other options.
+// File rules take precedence over other options rules for the same method pattern and network.
+//
+// Each non empty line of the file is a rule formatted as `<action> <method> <network>...`,
+// `#` starting a comment :
+//
+//	# Only allow office and VPN on admin service
+//	allow /admin.Service/* 192.168.0.0/16 10.8.0.0/24
+//	deny  *                203.0.113.7
+//
+// The file is read when creating the Filter and when calling Reload or Watch.
+func WithRulesFile(path string) FilterOption {
+	return func(f *Filter) error {
+		if path == "" {
+			return errors.New("rules file path cannot be empty")
+		}
+		f.rulesFile = path
+		return nil
+	}
+}
+
+// NewFilter creates a new instance of Filter with specified options
+func NewFilter(opts ...FilterOption) (*Filter, error) {
+	f := &Filter{}
+	for _, opt := range opts {
+		if err := opt(f); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload reads the rules file again, if any, and replaces the filter's rules.
+// If the file cannot be read or contains an invalid rule, an error is returned and current rules
+// are kept.
+func (f *Filter) Reload() error {
+	rules := f.rules
+	var modTime time.Time
+	if f.rulesFile != "" {
+		info, err := os.Stat(f.rulesFile)
+		if err != nil {
+			return fmt.Errorf("failed reading rules file: %w", err)
+		}
+		modTime = info.ModTime()
+		fileRules, err := readRulesFile(f.rulesFile)
+		if err != nil {
+			return err
+		}
+		rules = append(append([]Rule{}, rules...), fileRules...)
+	}
+	sets, err := buildRuleSets(rules)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sets = sets
+	f.modTime = modTime
+	return nil
+}
+
+// Watch checks the rules file every `interval` and reloads rules when it has been modified, until
+// ctx is done.
+// Reloading errors are passed to onError, if not nil, and current rules are kept.
+// Watch is blocking and should usually be called in its own goroutine.
+func (f *Filter) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	if f.rulesFile == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(f.rulesFile)
+		if err == nil {
+			f.mu.RLock()
+			unchanged := info.ModTime().Equal(f.modTime)
+			f.mu.RUnlock()
+			if unchanged {
+				continue
+			}
+			err = f.Reload()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// Allowed returns whether a call from `ip` to `fullMethod` is allowed.
+func (f *Filter) Allowed(ip net.IP, fullMethod string) bool {
+	set := f.ruleSetFor(fullMethod)
+	if set == nil {
+		return true
+	}
+	return set.allows(ip)
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that rejects calls from filtered remote
+// addresses with a codes.PermissionDenied error.
+func (f *Filter) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		infos *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if err := f.check(ctx, infos.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that rejects calls from filtered
+// remote addresses with a codes.PermissionDenied error.
+func (f *Filter) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		infos *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := f.check(stream.Context(), infos.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+func (f *Filter) check(ctx context.Context, fullMethod string) error {
+	set := f.ruleSetFor(fullMethod)
+	if set == nil {
+		return nil
+	}
+	ip, err := GetIPFromContext(ctx)
+	if err != nil {
+		return status.Error(codes.PermissionDenied, "remote address is not available")
+	}
+	if !set.allows(ip) {
+		return status.Error(codes.PermissionDenied, "remote address is not allowed")
+	}
+	return nil
+}
+
+func (f *Filter) ruleSetFor(fullMethod string) *ruleSet {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if _, set, ok := methodpattern.Lookup(f.sets, fullMethod); ok {
+		return set
+	}
+	return f.sets[MethodAll]
+}
+
+func (s *ruleSet) allows(ip net.IP) bool {
+	action, ok := s.trie.lookup(ip)
+	if !ok {
+		return !s.hasAllow
+	}
+	return action == ActionAllow
+}
+
+func rulesFor(action Action, method string, networks []string) []Rule {
+	rules := make([]Rule, 0, len(networks))
+	for _, n := range networks {
+		rules = append(rules, Rule{Action: action, Method: method, Network: n})
+	}
+	return rules
+}
+
+func buildRuleSets(rules []Rule) (map[string]*ruleSet, error) {
+	sets := make(map[string]*ruleSet)
+	for _, r := range rules {
+		network, err := parseRule(r)
+		if err != nil {
+			return nil, err
+		}
+		set, ok := sets[r.Method]
+		if !ok {
+			set = &ruleSet{trie: newCIDRTrie()}
+			sets[r.Method] = set
+		}
+		set.trie.insert(network, r.Action)
+		if r.Action == ActionAllow {
+			set.hasAllow = true
+		}
+	}
+	return sets, nil
+}
+
+func parseRule(r Rule) (*net.IPNet, error) {
+	if r.Action != ActionAllow && r.Action != ActionDeny {
+		return nil, fmt.Errorf("%w: unknown action %s", ErrInvalidRule, r.Action)
+	}
+	if r.Method != MethodAll {
+		if err := methodpattern.Validate(r.Method); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRule, err.Error())
+		}
+	}
+	if !strings.Contains(r.Network, "/") {
+		ip := net.ParseIP(r.Network)
+		if ip == nil {
+			return nil, fmt.Errorf(`%w: invalid IP address "%s"`, ErrInvalidRule, r.Network)
+		}
+		bits := 8 * net.IPv6len
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+			bits = 8 * net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(r.Network)
+	if err != nil {
+		return nil, fmt.Errorf(`%w: invalid network "%s"`, ErrInvalidRule, r.Network)
+	}
+	// IPv4-mapped IPv6 networks are stored as IPv4 ones, like the addresses they contain
+	if v4 := network.IP.To4(); v4 != nil && len(network.IP) == net.IPv6len {
+		ones, _ := network.Mask.Size()
+		if ones < 96 {
+			return nil, fmt.Errorf(`%w: invalid network "%s"`, ErrInvalidRule, r.Network)
+		}
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(ones-96, 8*net.IPv4len)}, nil
+	}
+	return network, nil
+}
+
+func readRulesFile(path string) ([]Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading rules file: %w", err)
+	}
+	defer file.Close()
+
+	var rules []Rule
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%w: %s:%d: expecting `<action> <method> <network>...`", ErrInvalidRule, path, line)
+		}
+		var action Action
+		switch strings.ToLower(fields[0]) {
+		case ActionAllow.String():
+			action = ActionAllow
+		case ActionDeny.String():
+			action = ActionDeny
+		default:
+			return nil, fmt.Errorf(`%w: %s:%d: unknown action "%s"`, ErrInvalidRule, path, line, fields[0])
+		}
+		for _, r := range rulesFor(action, fields[1], fields[2:]) {
+			if _, err := parseRule(r); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			rules = append(rules, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading rules file: %w", err)
+	}
+	return rules, nil
+}
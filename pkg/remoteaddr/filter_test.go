@@ -0,0 +1,224 @@
+package remoteaddr
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/grpcutilstest"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+)
+
+func TestNewFilter(t *testing.T) {
+	f, err := NewFilter(WithAllow("foo", "10.0.0.0/8"))
+	assert.Nil(t, f, "NewFilter() should not return a Filter with an invalid method pattern")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "NewFilter() should return a ErrInvalidOptionValue error with an invalid method pattern")
+
+	f, err = NewFilter(WithDeny(MethodAll, "10.0.0.0/33"))
+	assert.Nil(t, f, "NewFilter() should not return a Filter with an invalid network")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "NewFilter() should return a ErrInvalidOptionValue error with an invalid network")
+
+	f, err = NewFilter(WithRules(Rule{Action: Action(42), Method: MethodAll, Network: "10.0.0.1"}))
+	assert.Nil(t, f, "NewFilter() should not return a Filter with an invalid action")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "NewFilter() should return a ErrInvalidOptionValue error with an invalid action")
+
+	f, err = NewFilter(WithRulesFile(""))
+	assert.Nil(t, f, "NewFilter() should not return a Filter with an empty rules file path")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "NewFilter() should return a ErrInvalidOptionValue error with an empty rules file path")
+
+	f, err = NewFilter(WithRulesFile(filepath.Join(t.TempDir(), "missing")))
+	assert.Nil(t, f, "NewFilter() should not return a Filter with a missing rules file")
+	assert.NotNil(t, err, "NewFilter() should return an error with a missing rules file")
+
+	f, err = NewFilter()
+	assert.Nil(t, err, "NewFilter() should not return an error without options")
+	assert.True(t, f.Allowed(net.ParseIP("1.2.3.4"), "/foobar.DummyService/Foo"), "Filter without rules should allow all calls")
+}
+
+func TestFilter_Allowed(t *testing.T) {
+	f, err := NewFilter(
+		WithDeny(MethodAll, "203.0.113.7", "2001:db8::/32"),
+		WithAllow("/admin.Service/*", "192.168.0.0/16", "10.8.0.0/24"),
+		WithDeny("/admin.Service/*", "192.168.66.0/24"),
+		WithAllow("/admin.Service/*", "192.168.66.6"),
+		WithAllow("/admin.Service/Status", "0.0.0.0/0"),
+	)
+	assert.Nil(t, err, "NewFilter() should not return an error with valid rules")
+
+	check := func(expected bool, ip, method string) {
+		assert.Equal(t, expected, f.Allowed(net.ParseIP(ip), method), "Allowed(%s, %s) should return %v", ip, method, expected)
+	}
+	check(true, "1.2.3.4", "/foobar.DummyService/Foo")
+	check(false, "203.0.113.7", "/foobar.DummyService/Foo")
+	check(false, "::ffff:203.0.113.7", "/foobar.DummyService/Foo")
+	check(false, "2001:db8::1", "/foobar.DummyService/Foo")
+	check(true, "2001:db9::1", "/foobar.DummyService/Foo")
+
+	check(false, "1.2.3.4", "/admin.Service/Reset")
+	check(true, "192.168.1.1", "/admin.Service/Reset")
+	check(true, "10.8.0.42", "/admin.Service/Reset")
+	check(false, "10.8.1.42", "/admin.Service/Reset")
+	check(false, "192.168.66.1", "/admin.Service/Reset")
+	check(true, "192.168.66.6", "/admin.Service/Reset")
+	check(false, "2001:db9::1", "/admin.Service/Reset")
+
+	check(true, "1.2.3.4", "/admin.Service/Status")
+	check(false, "2001:db9::1", "/admin.Service/Status")
+}
+
+func TestFilter_Allowed_mappedNetwork(t *testing.T) {
+	f, err := NewFilter(WithAllow(MethodAll, "::ffff:10.0.0.0/104", "::ffff:192.0.2.1"))
+	assert.Nil(t, err, "NewFilter() should not return an error with IPv4-mapped IPv6 networks")
+	assert.True(t, f.Allowed(net.ParseIP("10.1.2.3"), "/foobar.DummyService/Foo"), "IPv4 addresses should match mapped networks")
+	assert.True(t, f.Allowed(net.ParseIP("::ffff:10.1.2.3"), "/foobar.DummyService/Foo"), "mapped addresses should match mapped networks")
+	assert.True(t, f.Allowed(net.ParseIP("192.0.2.1"), "/foobar.DummyService/Foo"), "IPv4 addresses should match mapped addresses")
+	assert.False(t, f.Allowed(net.ParseIP("11.1.2.3"), "/foobar.DummyService/Foo"), "other addresses should not match mapped networks")
+}
+
+func TestFilter_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+# Admin service
+allow /admin.Service/* 192.168.0.0/16 10.8.0.0/24 # office and VPN
+
+DENY  *                203.0.113.7
+`)
+	f, err := NewFilter(
+		WithRulesFile(path),
+		WithDeny(MethodAll, "198.51.100.0/24"),
+	)
+	assert.Nil(t, err, "NewFilter() should not return an error with a valid rules file")
+	assert.True(t, f.Allowed(net.ParseIP("10.8.0.1"), "/admin.Service/Reset"), "rules from file should be used")
+	assert.False(t, f.Allowed(net.ParseIP("10.9.0.1"), "/admin.Service/Reset"), "rules from file should be used")
+	assert.False(t, f.Allowed(net.ParseIP("203.0.113.7"), "/foobar.DummyService/Foo"), "rules from file should be used")
+	assert.False(t, f.Allowed(net.ParseIP("198.51.100.1"), "/foobar.DummyService/Foo"), "rules from options should be used")
+
+	write("allow /admin.Service/* 10.9.0.0/16")
+	assert.Nil(t, f.Reload(), "Reload() should not return an error with a valid rules file")
+	assert.True(t, f.Allowed(net.ParseIP("10.9.0.1"), "/admin.Service/Reset"), "Reload() should use the new rules")
+	assert.False(t, f.Allowed(net.ParseIP("10.8.0.1"), "/admin.Service/Reset"), "Reload() should use the new rules")
+	assert.True(t, f.Allowed(net.ParseIP("203.0.113.7"), "/foobar.DummyService/Foo"), "Reload() should use the new rules")
+	assert.False(t, f.Allowed(net.ParseIP("198.51.100.1"), "/foobar.DummyService/Foo"), "Reload() should keep rules from options")
+
+	for _, invalid := range []string{
+		"allow /admin.Service/*",
+		"permit * 10.0.0.0/8",
+		"allow admin 10.0.0.0/8",
+		"allow * 10.0.0.0/8 not-an-ip",
+	} {
+		write(invalid)
+		assert.ErrorIs(t, f.Reload(), ErrInvalidRule, "Reload() should return a ErrInvalidRule error with an invalid rules file")
+		assert.True(t, f.Allowed(net.ParseIP("10.9.0.1"), "/admin.Service/Reset"), "Reload() should keep current rules on error")
+	}
+}
+
+func TestFilter_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules")
+	if err := os.WriteFile(path, []byte("deny * 10.0.0.1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFilter(WithRulesFile(path))
+	assert.Nil(t, err, "NewFilter() should not return an error with a valid rules file")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.Watch(ctx, 10*time.Millisecond, nil)
+		close(done)
+	}()
+
+	if err := os.WriteFile(path, []byte("deny * 10.0.0.2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Ensure modification time changes even on filesystems with a coarse resolution
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		return !f.Allowed(net.ParseIP("10.0.0.2"), "/foobar.DummyService/Foo")
+	}, time.Second, 10*time.Millisecond, "Watch() should reload modified rules file")
+	assert.True(t, f.Allowed(net.ParseIP("10.0.0.1"), "/foobar.DummyService/Foo"), "Watch() should reload modified rules file")
+
+	cancel()
+	<-done
+}
+
+type dummyFilter struct {
+	foobar.UnimplementedDummyServiceServer
+}
+
+func (d *dummyFilter) Foo(_ context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	return &foobar.Empty{}, nil
+}
+
+// callFoo calls Foo on a DummyService server with the filter, from ip if it is not empty
+func callFoo(t *testing.T, f *Filter, ip string) error {
+	opts := []grpcutilstest.Option{
+		grpcutilstest.WithServerOptions(grpc.UnaryInterceptor(f.UnaryInterceptor())),
+	}
+	if ip != "" {
+		opts = append(opts, grpcutilstest.WithPeerAddr(&net.TCPAddr{IP: net.ParseIP(ip), Port: 1234}))
+	}
+	s := grpcutilstest.New(t, func(server *grpc.Server) {
+		foobar.RegisterDummyServiceServer(server, &dummyFilter{})
+	}, opts...)
+	_, err := foobar.NewDummyServiceClient(s.Conn).Foo(context.Background(), &foobar.Empty{})
+	return err
+}
+
+func TestFilter_UnaryInterceptor(t *testing.T) {
+	f, _ := NewFilter(WithAllow("/foobar.DummyService/*", "10.0.0.0/8"))
+	assert.Nil(t, callFoo(t, f, "10.1.2.3"), "UnaryInterceptor() should allow calls from allowed addresses")
+	assert.Equal(t, codes.PermissionDenied, status.Code(callFoo(t, f, "11.1.2.3")), "UnaryInterceptor() should deny calls from other addresses")
+	// bufconn addresses are not IP addresses
+	assert.Equal(t, codes.PermissionDenied, status.Code(callFoo(t, f, "")), "UnaryInterceptor() should deny calls without remote IP")
+
+	f, _ = NewFilter(WithAllow("/admin.Service/*", "10.0.0.0/8"))
+	assert.Nil(t, callFoo(t, f, ""), "UnaryInterceptor() should allow calls to methods without rules")
+
+	f, _ = NewFilter(WithAllow(MethodAll, "2001:db8::/32"))
+	assert.Nil(t, callFoo(t, f, "2001:db8::1"), "UnaryInterceptor() should allow calls from allowed IPv6 addresses")
+	assert.Equal(t, codes.PermissionDenied, status.Code(callFoo(t, f, "2001:db9::1")), "UnaryInterceptor() should deny calls from other IPv6 addresses")
+}
+
+func TestFilter_StreamInterceptor(t *testing.T) {
+	f, _ := NewFilter(WithDeny("/foobar.DummyService/FooS", "10.0.0.0/8"))
+	stream := &streamutil.ServerStream{
+		Ctx: peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3")}}),
+	}
+	called := false
+	handler := func(interface{}, grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+	err := f.StreamInterceptor()(nil, stream, &grpc.StreamServerInfo{FullMethod: "/foobar.DummyService/FooS"}, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "StreamInterceptor() should deny calls from denied addresses")
+	assert.False(t, called, "StreamInterceptor() should not call handler for denied addresses")
+
+	stream.Ctx = peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("11.1.2.3")}})
+	err = f.StreamInterceptor()(nil, stream, &grpc.StreamServerInfo{FullMethod: "/foobar.DummyService/FooS"}, handler)
+	assert.Nil(t, err, "StreamInterceptor() should allow calls from other addresses")
+	assert.True(t, called, "StreamInterceptor() should call handler for allowed addresses")
+
+	f, _ = NewFilter(WithDeny("/foobar.DummyService/Foo", "10.0.0.0/8"))
+	utils.TestCallFooS(t, &dummyRemote{t: t}, nil, []grpc.ServerOption{
+		grpc.StreamInterceptor(f.StreamInterceptor()),
+	})
+}
@@ -1,4 +1,4 @@
-// Package remoteaddr gets the gRPC client's remote address.
+// Package remoteaddr gets the gRPC client's remote address and filters calls based on it.
 package remoteaddr
 
 import (
@@ -38,11 +38,15 @@ func GetIPFromContext(ctx context.Context) (net.IP, error) {
 	if err != nil {
 		return nil, err
 	}
-	parts := strings.Split(addr.String(), ":")
-	if len(parts) < 2 {
+	// IPv6 hosts are bracketed and may have a zone ("[fe80::1%eth0]:443")
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
 		return nil, fmt.Errorf("%w: could not retreive remote address (invalid address format)", ErrNotAvailable)
 	}
-	ip := net.ParseIP(strings.Join(parts[:len(parts)-1], ":"))
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	ip := net.ParseIP(host)
 	if ip == nil {
 		return nil, fmt.Errorf("%w: could not retreive remote address (invalid IP)", ErrNotAvailable)
 	}
@@ -3,11 +3,13 @@ package remoteaddr
 import (
 	"context"
 	"io"
+	"net"
 	"testing"
 
 	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
 	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/peer"
 )
 
 type dummyRemote struct {
@@ -48,3 +50,24 @@ func TestGetFromContext(t *testing.T) {
 	utils.TestCallFoo(t, &dummyRemote{t: t}, nil, nil)
 	utils.TestCallFooS(t, &dummyRemote{t: t}, nil, nil)
 }
+
+func TestGetIPFromContext(t *testing.T) {
+	ip, err := GetIPFromContext(context.TODO())
+	assert.Nil(t, ip, "GetIPFromContext on a non-gRPC context should return nil as ip")
+	assert.ErrorIs(t, err, ErrNotAvailable, "GetIPFromContext on a non-gRPC context should return a ErrNotAvailable error")
+
+	addrs := map[string]net.Addr{
+		"10.1.2.3":    &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 1234},
+		"2001:db8::1": &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443},
+		"fe80::1":     &net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 443, Zone: "eth0"},
+	}
+	for expected, addr := range addrs {
+		ip, err = GetIPFromContext(peer.NewContext(context.Background(), &peer.Peer{Addr: addr}))
+		assert.Nil(t, err, "GetIPFromContext should not return an error for %s", addr)
+		assert.Equal(t, expected, ip.String(), "GetIPFromContext should return the IP of %s", addr)
+	}
+
+	ip, err = GetIPFromContext(peer.NewContext(context.Background(), &peer.Peer{Addr: &net.UnixAddr{Name: "bufconn"}}))
+	assert.Nil(t, ip, "GetIPFromContext should return nil as ip for non IP addresses")
+	assert.ErrorIs(t, err, ErrNotAvailable, "GetIPFromContext should return a ErrNotAvailable error for non IP addresses")
+}
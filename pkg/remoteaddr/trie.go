@@ -0,0 +1,72 @@
+package remoteaddr
+
+import (
+	"net"
+)
+
+// cidrTrie is a binary prefix trie of IP networks, each network being associated to an action.
+// IPv4 and IPv6 networks are stored in separate trees so that IPv4 addresses, whatever their
+// representation, are matched against IPv4 networks only.
+type cidrTrie struct {
+	v4 *trieNode
+	v6 *trieNode
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	set      bool
+	action   Action
+}
+
+func newCIDRTrie() *cidrTrie {
+	return &cidrTrie{
+		v4: &trieNode{},
+		v6: &trieNode{},
+	}
+}
+
+// insert adds a network to the trie, replacing the action of the same network if already present.
+func (t *cidrTrie) insert(network *net.IPNet, action Action) {
+	ip, root := t.rootFor(network.IP)
+	ones, _ := network.Mask.Size()
+	node := root
+	for i := 0; i < ones; i++ {
+		b := bitAt(ip, i)
+		if node.children[b] == nil {
+			node.children[b] = &trieNode{}
+		}
+		node = node.children[b]
+	}
+	node.set = true
+	node.action = action
+}
+
+// lookup returns the action of the longest network containing ip, if any.
+func (t *cidrTrie) lookup(ip net.IP) (Action, bool) {
+	ip, node := t.rootFor(ip)
+	var (
+		action Action
+		found  bool
+	)
+	for i := 0; node != nil; i++ {
+		if node.set {
+			action, found = node.action, true
+		}
+		if i == len(ip)*8 {
+			break
+		}
+		node = node.children[bitAt(ip, i)]
+	}
+	return action, found
+}
+
+func (t *cidrTrie) rootFor(ip net.IP) (net.IP, *trieNode) {
+	if v4 := ip.To4(); v4 != nil {
+		return v4, t.v4
+	}
+	return ip.To16(), t.v6
+}
+
+func bitAt(ip net.IP, i int) int {
+	return int(ip[i/8]>>(7-uint(i%8))) & 1
+}
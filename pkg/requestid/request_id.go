@@ -11,7 +11,8 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
-	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
 )
 
 const (
@@ -32,10 +33,7 @@ func UnaryServerInterceptor(
 	if id == "" {
 		id = uuid.New().String()
 	}
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		md = metadata.MD{}
-	}
+	md := metadatautil.CopyIncoming(ctx)
 	md.Set(MetadataName, id)
 	ctx = metadata.NewIncomingContext(ctx, md)
 	if err := grpc.SetHeader(ctx, metadata.Pairs(MetadataName, id)); err != nil {
@@ -57,14 +55,11 @@ func StreamServerInterceptor(
 	if id == "" {
 		id = uuid.New().String()
 	}
-	md, ok := metadata.FromIncomingContext(stream.Context())
-	if !ok {
-		md = metadata.MD{}
-	}
+	md := metadatautil.CopyIncoming(stream.Context())
 	md.Set(MetadataName, id)
 	ctx := metadata.NewIncomingContext(stream.Context(), md)
 
-	ns := &utils.ServerStream{
+	ns := &streamutil.ServerStream{
 		ServerStream: stream,
 		Ctx:          ctx,
 	}
@@ -76,19 +71,12 @@ func StreamServerInterceptor(
 
 // GetFromContext returns the request correlation identifier from a gRPC incoming context
 func GetFromContext(ctx context.Context) string {
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return ""
-	}
-	return GetFromMeta(md)
+	return GetFromMeta(metadatautil.FromIncoming(ctx))
 }
 
 // GetFromMeta returns the request correlation identifier from a gRPC metadata map
 func GetFromMeta(md metadata.MD) string {
-	if len(md.Get(MetadataName)) < 1 {
-		return ""
-	}
-	return md.Get(MetadataName)[0]
+	return metadatautil.GetString(md, MetadataName)
 }
 
 // AppendToOutgoingContext generates an outgoing gRPC context with a correlation identifier.
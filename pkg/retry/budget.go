@@ -0,0 +1,67 @@
+package retry
+
+import (
+	"errors"
+	"sync"
+)
+
+// Budget limits retries and hedged attempts to avoid retry storms when servers are failing, like
+// gRPC retry throttling : each failed attempt consumes a token, each successful one gives back
+// TokenRatio tokens, and retries are allowed while more than half of MaxTokens remain.
+type Budget struct {
+	// MaxTokens is the number of tokens of the budget, which starts full
+	MaxTokens float64
+	// TokenRatio is the number of tokens given back by successful attempts
+	TokenRatio float64
+}
+
+func (b Budget) validate() error {
+	if b.MaxTokens <= 0 {
+		return errors.New("budget maximum tokens must be positive")
+	}
+	if b.TokenRatio <= 0 {
+		return errors.New("budget token ratio must be positive")
+	}
+	return nil
+}
+
+// budget holds the tokens of a Budget
+type budget struct {
+	mu     sync.Mutex
+	config Budget
+	tokens float64
+}
+
+func newBudget(config Budget) *budget {
+	return &budget{config: config, tokens: config.MaxTokens}
+}
+
+// record records the result of an attempt.
+func (b *budget) record(success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		b.tokens += b.config.TokenRatio
+		if b.tokens > b.config.MaxTokens {
+			b.tokens = b.config.MaxTokens
+		}
+		return
+	}
+	b.tokens--
+	if b.tokens < 0 {
+		b.tokens = 0
+	}
+}
+
+// allow returns whether a retry or hedged attempt is allowed.
+func (b *budget) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens > b.config.MaxTokens/2
+}
@@ -0,0 +1,45 @@
+package retry_test
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/jucrouzet/grpcutils/pkg/retry"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// ExampleNew retries unavailable calls and hedges a slow idempotent method, logging each attempt
+func ExampleNew() {
+	l, err := zaplogger.New(zaplogger.WithFields(zaplogger.FieldMethod, zaplogger.FieldRequestID, zaplogger.FieldAttempt))
+	if err != nil {
+		panic(err)
+	}
+	r, err := retry.New(
+		retry.WithPolicy(retry.DefaultPolicy),
+		retry.WithMethodPolicy("/foobar.DummyService/Foo", retry.Policy{
+			MaxAttempts:       3,
+			RetryableCodes:    []codes.Code{codes.Unavailable},
+			InitialBackoff:    50 * time.Millisecond,
+			MaxBackoff:        time.Second,
+			BackoffMultiplier: 2,
+			HedgingDelay:      100 * time.Millisecond,
+		}),
+		retry.WithBudget(retry.Budget{MaxTokens: 10, TokenRatio: 0.1}),
+	)
+	if err != nil {
+		panic(err)
+	}
+	_, err = grpc.Dial(
+		"localhost:50051",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		// retry interceptors must be before zaplogger's ones to log each attempt
+		grpc.WithChainUnaryInterceptor(r.UnaryClientInterceptor(), l.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(r.StreamClientInterceptor(), l.StreamClientInterceptor()),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,118 @@
+package retry
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// hedgedAttempt is a hedged attempt of a call, with its own reply, header, trailer and peer so
+// that concurrent attempts don't write the call ones
+type hedgedAttempt struct {
+	reply   proto.Message
+	header  metadata.MD
+	trailer metadata.MD
+	peer    peer.Peer
+	err     error
+}
+
+// options returns the call options opts, with header, trailer and peer options replaced by ones
+// of the attempt.
+func (a *hedgedAttempt) options(opts []grpc.CallOption) []grpc.CallOption {
+	attemptOpts := make([]grpc.CallOption, 0, len(opts)+3)
+	for _, opt := range opts {
+		switch opt.(type) {
+		case grpc.HeaderCallOption, grpc.TrailerCallOption, grpc.PeerCallOption:
+		default:
+			attemptOpts = append(attemptOpts, opt)
+		}
+	}
+	return append(attemptOpts, grpc.Header(&a.header), grpc.Trailer(&a.trailer), grpc.Peer(&a.peer))
+}
+
+// use copies the reply, header, trailer and peer of the attempt into the call ones.
+func (a *hedgedAttempt) use(reply proto.Message, opts []grpc.CallOption) {
+	proto.Reset(reply)
+	proto.Merge(reply, a.reply)
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			*o.HeaderAddr = a.header
+		case grpc.TrailerCallOption:
+			*o.TrailerAddr = a.trailer
+		case grpc.PeerCallOption:
+			*o.PeerAddr = a.peer
+		}
+	}
+}
+
+// hedge sends concurrent attempts of a call, a new one each time the policy hedging delay passes
+// without response or an attempt fails with a retryable code, using the first successful one.
+// Pending attempts are cancelled once a result is used.
+func (r *Retrier) hedge(
+	ctx context.Context,
+	policy *Policy,
+	invoke func(ctx context.Context, reply proto.Message, opts ...grpc.CallOption) error,
+	reply proto.Message,
+	opts []grpc.CallOption,
+) error {
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan *hedgedAttempt, policy.MaxAttempts)
+	started, pending := 0, 0
+	start := func() {
+		started++
+		pending++
+		attemptCtx, attemptCancel := attemptContext(hedgeCtx, policy, started, true)
+		a := &hedgedAttempt{reply: reply.ProtoReflect().New().Interface()}
+		go func() {
+			defer attemptCancel()
+			a.err = invoke(attemptCtx, a.reply, a.options(opts)...)
+			results <- a
+		}()
+	}
+	canHedge := func() bool {
+		return started < policy.MaxAttempts && r.budget.allow()
+	}
+
+	start()
+	timer := time.NewTimer(policy.HedgingDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if canHedge() {
+				start()
+				timer.Reset(policy.HedgingDelay)
+			}
+		case a := <-results:
+			pending--
+			if a.err == nil {
+				r.budget.record(true)
+				a.use(reply, opts)
+				return nil
+			}
+			if !r.recordAttempt(ctx, policy, a.err) {
+				a.use(reply, opts)
+				return a.err
+			}
+			if canHedge() {
+				start()
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(policy.HedgingDelay)
+			} else if pending == 0 {
+				a.use(reply, opts)
+				return a.err
+			}
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Package retry retries failed gRPC client calls according to per-method policies, with
+// exponential backoff, a retry budget and optional request hedging, without relying on gRPC
+// service config.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/logfields"
+	"github.com/jucrouzet/grpcutils/internal/pkg/methodpattern"
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+	"github.com/jucrouzet/grpcutils/pkg/ratelimit"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+)
+
+// AttemptMetadataName is the name of the outgoing metadata holding the attempt number, starting at
+// 1, of calls, which zaplogger logs with the zaplogger.FieldAttempt field.
+const AttemptMetadataName = logfields.AttemptMetadataName
+
+// ErrInvalidOptionValue is returned when trying to use an invalid option value
+var ErrInvalidOptionValue = errors.New("invalid option value")
+
+// Policy is the retry policy of a method.
+// Only idempotent methods should be retried.
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts of a call, including the first one
+	MaxAttempts int
+	// RetryableCodes are the status codes of failed attempts that are retried
+	RetryableCodes []codes.Code
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between retries
+	MaxBackoff time.Duration
+	// BackoffMultiplier multiplies the delay after each retry
+	BackoffMultiplier float64
+	// Jitter randomizes delays by up to this fraction of them, between 0 and 1
+	Jitter float64
+	// PerAttemptTimeout is the timeout of each attempt of unary calls, attempts failing because of
+	// it being retried.
+	// If zero, attempts are only bounded by the call deadline.
+	PerAttemptTimeout time.Duration
+	// HedgingDelay enables hedging of unary calls if not zero : a new attempt is sent each time
+	// HedgingDelay passes without response, up to MaxAttempts concurrent attempts, the first
+	// response being used.
+	// Failed attempts with a retryable code are hedged immediately, backoff is not used.
+	HedgingDelay time.Duration
+}
+
+// DefaultPolicy is a policy retrying unavailable calls twice
+var DefaultPolicy = Policy{
+	MaxAttempts:       3,
+	RetryableCodes:    []codes.Code{codes.Unavailable},
+	InitialBackoff:    100 * time.Millisecond,
+	MaxBackoff:        2 * time.Second,
+	BackoffMultiplier: 2,
+	Jitter:            0.2,
+}
+
+func (p Policy) validate() error {
+	if p.MaxAttempts < 1 {
+		return errors.New("maximum attempts must be at least 1")
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < p.InitialBackoff {
+		return errors.New("backoffs must be positive and maximum backoff above initial backoff")
+	}
+	if p.BackoffMultiplier < 1 {
+		return errors.New("backoff multiplier must be at least 1")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return errors.New("jitter must be between 0 and 1")
+	}
+	if p.PerAttemptTimeout < 0 || p.HedgingDelay < 0 {
+		return errors.New("per attempt timeout and hedging delay cannot be negative")
+	}
+	return nil
+}
+
+// retryableCode returns whether failed attempts with code are retried.
+func (p *Policy) retryableCode(code codes.Code) bool {
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before retrying after attempt.
+func (p *Policy) backoff(attempt int) time.Duration {
+	delay := float64(p.InitialBackoff) * math.Pow(p.BackoffMultiplier, float64(attempt-1))
+	if delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(delay)
+}
+
+// Retrier retries failed client calls
+type Retrier struct {
+	policy  *Policy
+	methods map[string]*Policy
+	budget  *budget
+}
+
+// Option is the Retrier option functions type
+type Option func(*Retrier) error
+
+// WithPolicy sets the retry policy of all methods without a method policy.
+// If not set, only methods with a policy set with WithMethodPolicy are retried.
+func WithPolicy(policy Policy) Option {
+	return func(r *Retrier) error {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		r.policy = &policy
+		return nil
+	}
+}
+
+// WithMethodPolicy sets the retry policy of the methods matching `method`, like
+// "/package.Service/Method" or "/package.Service/*".
+func WithMethodPolicy(method string, policy Policy) Option {
+	return func(r *Retrier) error {
+		if err := methodpattern.Validate(method); err != nil {
+			return err
+		}
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		r.methods[method] = &policy
+		return nil
+	}
+}
+
+// WithBudget limits retries and hedged attempts of all methods with budget.
+// If not set, retries are only limited by policies maximum attempts.
+func WithBudget(budget Budget) Option {
+	return func(r *Retrier) error {
+		if err := budget.validate(); err != nil {
+			return err
+		}
+		r.budget = newBudget(budget)
+		return nil
+	}
+}
+
+// New creates a new instance of Retrier with specified options
+func New(opts ...Option) (*Retrier, error) {
+	r := &Retrier{
+		methods: make(map[string]*Policy),
+	}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	return r, nil
+}
+
+// UnaryClientInterceptor returns a gRPC client unary interceptor that retries or hedges failed
+// calls according to their method policy.
+// All attempts are sent with the same request correlation identifier, generated if the call has
+// none, and their number in AttemptMetadataName metadata.
+// It must be placed before interceptors that should see each attempt, like zaplogger's.
+func (r *Retrier) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		policy := r.policyFor(method)
+		if policy == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ctx = withRequestID(ctx)
+		if msg, ok := reply.(proto.Message); ok && policy.HedgingDelay > 0 {
+			return r.hedge(ctx, policy, func(ctx context.Context, reply proto.Message, opts ...grpc.CallOption) error {
+				return invoker(ctx, method, req, reply, cc, opts...)
+			}, msg, opts)
+		}
+		return r.retry(ctx, policy, func(ctx context.Context, attempt int, trailer *metadata.MD) error {
+			return invoker(ctx, method, req, reply, cc, append(opts, grpc.Trailer(trailer))...)
+		}, true)
+	}
+}
+
+// StreamClientInterceptor returns a gRPC client stream interceptor that retries failed stream
+// creations according to their method policy, streams failing after their creation are not
+// retried.
+// Policies per attempt timeout and hedging delay do not apply to streams.
+// All attempts are sent with the same request correlation identifier, generated if the call has
+// none, and their number in AttemptMetadataName metadata.
+func (r *Retrier) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		policy := r.policyFor(method)
+		if policy == nil {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		var stream grpc.ClientStream
+		err := r.retry(withRequestID(ctx), policy, func(ctx context.Context, _ int, _ *metadata.MD) error {
+			var err error
+			stream, err = streamer(ctx, desc, cc, method, opts...)
+			return err
+		}, false)
+		return stream, err
+	}
+}
+
+// policyFor returns the policy of a method, or nil if it is not retried.
+func (r *Retrier) policyFor(method string) *Policy {
+	if _, p, ok := methodpattern.Lookup(r.methods, method); ok {
+		return p
+	}
+	return r.policy
+}
+
+// retry calls attempt until it succeeds, fails with a non retryable error, or the maximum number
+// of attempts or the budget is reached.
+func (r *Retrier) retry(
+	ctx context.Context,
+	policy *Policy,
+	attempt func(ctx context.Context, attempt int, trailer *metadata.MD) error,
+	perAttemptTimeout bool,
+) error {
+	for n := 1; ; n++ {
+		var trailer metadata.MD
+		attemptCtx, cancel := attemptContext(ctx, policy, n, perAttemptTimeout)
+		err := attempt(attemptCtx, n, &trailer)
+		cancel()
+		if !r.recordAttempt(ctx, policy, err) || n >= policy.MaxAttempts || !r.budget.allow() {
+			return err
+		}
+		delay := policy.backoff(n)
+		if retryAfter, ok := ratelimit.GetRetryAfterFromMeta(trailer); ok && retryAfter > delay {
+			delay = retryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// recordAttempt records the result of an attempt in the budget, returning whether it should be
+// retried.
+func (r *Retrier) recordAttempt(ctx context.Context, policy *Policy, err error) bool {
+	if err == nil {
+		r.budget.record(true)
+		return false
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	code := status.Code(err)
+	retryable := policy.retryableCode(code) || (code == codes.DeadlineExceeded && policy.PerAttemptTimeout > 0)
+	if retryable {
+		r.budget.record(false)
+	}
+	return retryable
+}
+
+// attemptContext returns the context of an attempt, with its number in outgoing metadata and its
+// timeout.
+func attemptContext(ctx context.Context, policy *Policy, attempt int, perAttemptTimeout bool) (context.Context, context.CancelFunc) {
+	md := metadatautil.CopyOutgoing(ctx)
+	metadatautil.SetInt(md, AttemptMetadataName, int64(attempt))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	if perAttemptTimeout && policy.PerAttemptTimeout > 0 {
+		return context.WithTimeout(ctx, policy.PerAttemptTimeout)
+	}
+	return ctx, func() {}
+}
+
+// withRequestID returns ctx with a request correlation identifier in outgoing metadata, so that
+// all attempts share it.
+func withRequestID(ctx context.Context) context.Context {
+	if requestid.GetFromMeta(metadatautil.FromOutgoing(ctx)) != "" {
+		return ctx
+	}
+	return requestid.AppendToOutgoingContext(ctx)
+}
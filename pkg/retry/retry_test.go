@@ -0,0 +1,288 @@
+package retry
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/ratelimit"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// dummyRetry fails its first `failures` calls with `code`, after waiting `delay`
+type dummyRetry struct {
+	foobar.UnimplementedDummyServiceServer
+	mu         sync.Mutex
+	failures   int
+	code       codes.Code
+	delay      time.Duration
+	retryAfter string
+	attempts   []string
+	requestIDs []string
+}
+
+func (d *dummyRetry) call(ctx context.Context) error {
+	if logger, err := zaplogger.GetFromContext(ctx); err == nil {
+		logger.Info("attempt")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	d.mu.Lock()
+	d.attempts = append(d.attempts, md.Get(AttemptMetadataName)...)
+	d.requestIDs = append(d.requestIDs, requestid.GetFromMeta(md))
+	fail := len(d.requestIDs) <= d.failures
+	d.mu.Unlock()
+	if !fail {
+		return nil
+	}
+	if d.delay > 0 {
+		select {
+		case <-time.After(d.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if d.retryAfter != "" {
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(ratelimit.RetryAfterMetadataName, d.retryAfter))
+	}
+	return status.Error(d.code, "failure")
+}
+
+func (d *dummyRetry) Foo(ctx context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	if err := d.call(ctx); err != nil {
+		return nil, err
+	}
+	return &foobar.Empty{}, nil
+}
+
+func (d *dummyRetry) FooS(s foobar.DummyService_FooSServer) error {
+	if err := d.call(s.Context()); err != nil {
+		return err
+	}
+	for {
+		if _, err := s.Recv(); err != nil {
+			return nil
+		}
+		if err := s.Send(&foobar.Empty{}); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *dummyRetry) calls() ([]string, []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.attempts...), append([]string(nil), d.requestIDs...)
+}
+
+var fastPolicy = Policy{
+	MaxAttempts:       4,
+	RetryableCodes:    []codes.Code{codes.Unavailable},
+	InitialBackoff:    time.Millisecond,
+	MaxBackoff:        5 * time.Millisecond,
+	BackoffMultiplier: 2,
+}
+
+func TestNew(t *testing.T) {
+	invalids := map[string]Option{
+		"no attempts":             WithPolicy(Policy{BackoffMultiplier: 1}),
+		"a max below the initial": WithPolicy(Policy{MaxAttempts: 2, InitialBackoff: time.Second, MaxBackoff: time.Millisecond, BackoffMultiplier: 1}),
+		"a multiplier below 1":    WithPolicy(Policy{MaxAttempts: 2, BackoffMultiplier: 0.5}),
+		"a jitter above 1":        WithPolicy(Policy{MaxAttempts: 2, BackoffMultiplier: 1, Jitter: 2}),
+		"a negative hedging":      WithPolicy(Policy{MaxAttempts: 2, BackoffMultiplier: 1, HedgingDelay: -time.Second}),
+		"an invalid method":       WithMethodPolicy("Foo", DefaultPolicy),
+		"an empty budget":         WithBudget(Budget{TokenRatio: 0.1}),
+		"a zero token ratio":      WithBudget(Budget{MaxTokens: 10}),
+	}
+	for name, opt := range invalids {
+		r, err := New(opt)
+		assert.Nil(t, r, "New() should not return a Retrier with %s", name)
+		assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with %s", name)
+	}
+	_, err := New(WithPolicy(DefaultPolicy))
+	assert.Nil(t, err, "New() should accept the default policy")
+}
+
+func TestRetrier_UnaryClientInterceptor(t *testing.T) {
+	r, _ := New(WithPolicy(fastPolicy))
+	clientOpts := []grpc.DialOption{grpc.WithUnaryInterceptor(r.UnaryClientInterceptor())}
+
+	impl := &dummyRetry{failures: 2, code: codes.Unavailable}
+	_, _, _, err := utils.TestCallFoo(t, impl, clientOpts, nil)
+	assert.Nil(t, err, "call should succeed after retries")
+	attempts, ids := impl.calls()
+	assert.Equal(t, []string{"1", "2", "3"}, attempts, "attempts should be sent with their number")
+	if assert.Equal(t, 3, len(ids), "call should be attempted 3 times") {
+		assert.NotEmpty(t, ids[0], "a request ID should be generated")
+		assert.Equal(t, ids[0], ids[1], "attempts should share the request ID")
+		assert.Equal(t, ids[0], ids[2], "attempts should share the request ID")
+	}
+
+	impl = &dummyRetry{failures: 2, code: codes.Unavailable}
+	_, _, _, _ = utils.TestCallFoo(t, impl, clientOpts, nil, requestid.AppendToOutgoingContext(context.Background(), "I'm a unique ID"))
+	_, ids = impl.calls()
+	assert.Equal(t, []string{"I'm a unique ID", "I'm a unique ID", "I'm a unique ID"}, ids, "attempts should keep the call request ID")
+
+	impl = &dummyRetry{failures: 10, code: codes.Unavailable}
+	_, _, _, err = utils.TestCallFoo(t, impl, clientOpts, nil)
+	assert.Equal(t, codes.Unavailable, status.Code(err), "call should fail after max attempts")
+	_, ids = impl.calls()
+	assert.Equal(t, 4, len(ids), "call should be attempted up to max attempts")
+
+	impl = &dummyRetry{failures: 10, code: codes.InvalidArgument}
+	_, _, _, err = utils.TestCallFoo(t, impl, clientOpts, nil)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "call should fail with the non retryable error")
+	_, ids = impl.calls()
+	assert.Equal(t, 1, len(ids), "call with a non retryable error should not be retried")
+
+	r, _ = New(WithMethodPolicy("/foobar.DummyService/Bar", fastPolicy))
+	impl = &dummyRetry{failures: 10, code: codes.Unavailable}
+	_, _, _, _ = utils.TestCallFoo(t, impl, []grpc.DialOption{grpc.WithUnaryInterceptor(r.UnaryClientInterceptor())}, nil)
+	attempts, ids = impl.calls()
+	assert.Equal(t, 1, len(ids), "method without policy should not be retried")
+	assert.Empty(t, attempts, "method without policy should not be sent an attempt number")
+
+	r, _ = New(WithMethodPolicy("/foobar.DummyService/*", fastPolicy))
+	impl = &dummyRetry{failures: 1, code: codes.Unavailable}
+	_, _, _, err = utils.TestCallFoo(t, impl, []grpc.DialOption{grpc.WithUnaryInterceptor(r.UnaryClientInterceptor())}, nil)
+	assert.Nil(t, err, "service policy should apply to its methods")
+}
+
+func TestPolicy_PerAttemptTimeout(t *testing.T) {
+	policy := fastPolicy
+	policy.PerAttemptTimeout = 20 * time.Millisecond
+	r, _ := New(WithPolicy(policy))
+	impl := &dummyRetry{failures: 1, code: codes.Unavailable, delay: time.Second}
+
+	start := time.Now()
+	_, _, _, err := utils.TestCallFoo(t, impl, []grpc.DialOption{grpc.WithUnaryInterceptor(r.UnaryClientInterceptor())}, nil)
+	assert.Nil(t, err, "call should succeed after the timed out attempt")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "attempt should time out")
+	_, ids := impl.calls()
+	assert.Equal(t, 2, len(ids), "timed out attempt should be retried")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	impl = &dummyRetry{failures: 10, code: codes.Unavailable, delay: time.Second}
+	policy.PerAttemptTimeout = time.Second
+	r, _ = New(WithPolicy(policy))
+	_, _, _, err = utils.TestCallFoo(t, impl, []grpc.DialOption{grpc.WithUnaryInterceptor(r.UnaryClientInterceptor())}, nil, ctx)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err), "call should fail at its deadline")
+	_, ids = impl.calls()
+	assert.Equal(t, 1, len(ids), "call which deadline is exceeded should not be retried")
+}
+
+func TestPolicy_RetryAfter(t *testing.T) {
+	r, _ := New(WithPolicy(fastPolicy))
+	impl := &dummyRetry{failures: 1, code: codes.Unavailable, retryAfter: "1"}
+
+	start := time.Now()
+	_, _, _, err := utils.TestCallFoo(t, impl, []grpc.DialOption{grpc.WithUnaryInterceptor(r.UnaryClientInterceptor())}, nil)
+	assert.Nil(t, err, "call should succeed after a retry")
+	assert.GreaterOrEqual(t, time.Since(start), time.Second, "retry should wait for the server retry delay")
+}
+
+func TestPolicy_backoff(t *testing.T) {
+	p := Policy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, BackoffMultiplier: 2}
+	assert.Equal(t, 100*time.Millisecond, p.backoff(1), "first backoff should be the initial backoff")
+	assert.Equal(t, 400*time.Millisecond, p.backoff(3), "backoff should be multiplied after each retry")
+	assert.Equal(t, time.Second, p.backoff(10), "backoff should be capped")
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.backoff(1)
+		assert.True(t, d >= 50*time.Millisecond && d <= 150*time.Millisecond, "backoff should be jittered within the jitter fraction, got %s", d)
+	}
+}
+
+func TestWithBudget(t *testing.T) {
+	r, _ := New(WithPolicy(fastPolicy), WithBudget(Budget{MaxTokens: 4, TokenRatio: 1}))
+	clientOpts := []grpc.DialOption{grpc.WithUnaryInterceptor(r.UnaryClientInterceptor())}
+
+	impl := &dummyRetry{failures: 10, code: codes.Unavailable}
+	_, _, _, _ = utils.TestCallFoo(t, impl, clientOpts, nil)
+	_, ids := impl.calls()
+	assert.Equal(t, 2, len(ids), "retries should stop when half of the budget is consumed")
+
+	impl = &dummyRetry{failures: 10, code: codes.Unavailable}
+	_, _, _, _ = utils.TestCallFoo(t, impl, clientOpts, nil)
+	_, ids = impl.calls()
+	assert.Equal(t, 1, len(ids), "call should not be retried with an exhausted budget")
+
+	for i := 0; i < 3; i++ {
+		_, _, _, err := utils.TestCallFoo(t, &dummyRetry{}, clientOpts, nil)
+		assert.Nil(t, err, "call should succeed")
+	}
+	impl = &dummyRetry{failures: 10, code: codes.Unavailable}
+	_, _, _, _ = utils.TestCallFoo(t, impl, clientOpts, nil)
+	_, ids = impl.calls()
+	assert.Equal(t, 2, len(ids), "successful calls should refill the budget")
+}
+
+func TestPolicy_HedgingDelay(t *testing.T) {
+	policy := fastPolicy
+	policy.MaxAttempts = 3
+	policy.HedgingDelay = 20 * time.Millisecond
+	r, _ := New(WithPolicy(policy))
+	clientOpts := []grpc.DialOption{grpc.WithUnaryInterceptor(r.UnaryClientInterceptor())}
+
+	impl := &dummyRetry{failures: 1, code: codes.Unavailable, delay: time.Second}
+	start := time.Now()
+	v, _, _, err := utils.TestCallFoo(t, impl, clientOpts, nil)
+	assert.Nil(t, err, "hedged call should succeed")
+	assert.NotNil(t, v, "hedged call should return the reply")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "hedged call should not wait for the slow attempt")
+	attempts, ids := impl.calls()
+	assert.Equal(t, []string{"1", "2"}, attempts, "a second attempt should be sent after the hedging delay")
+	assert.Equal(t, ids[0], ids[1], "hedged attempts should share the request ID")
+
+	impl = &dummyRetry{failures: 10, code: codes.Unavailable}
+	_, _, _, err = utils.TestCallFoo(t, impl, clientOpts, nil)
+	assert.Equal(t, codes.Unavailable, status.Code(err), "hedged call should fail when all attempts fail")
+	_, ids = impl.calls()
+	assert.Equal(t, 3, len(ids), "failed attempts should be hedged up to max attempts")
+
+	impl = &dummyRetry{failures: 10, code: codes.PermissionDenied}
+	_, _, _, err = utils.TestCallFoo(t, impl, clientOpts, nil)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "hedged call should fail with a non retryable error")
+	_, ids = impl.calls()
+	assert.Equal(t, 1, len(ids), "non retryable error should stop hedging")
+}
+
+func TestRetrier_StreamClientInterceptor(t *testing.T) {
+	r, _ := New(WithPolicy(fastPolicy))
+	impl := &dummyRetry{}
+	utils.TestCallFooS(t, impl, []grpc.DialOption{grpc.WithStreamInterceptor(r.StreamClientInterceptor())}, nil)
+	attempts, ids := impl.calls()
+	assert.Equal(t, []string{"1"}, attempts, "stream should be sent its attempt number")
+	assert.NotEmpty(t, ids[0], "stream should be sent a request ID")
+}
+
+func TestAttemptLogging(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := zaplogger.New(zaplogger.WithLogger(zap.New(core)), zaplogger.WithFields(zaplogger.FieldRequestID, zaplogger.FieldAttempt))
+	r, _ := New(WithPolicy(fastPolicy))
+	clientOpts := []grpc.DialOption{grpc.WithUnaryInterceptor(r.UnaryClientInterceptor())}
+	serverOpts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor, l.UnaryInterceptor())}
+
+	_, _, _, err := utils.TestCallFoo(t, &dummyRetry{failures: 1, code: codes.Unavailable}, clientOpts, serverOpts)
+	assert.Nil(t, err, "call should succeed after a retry")
+	logs := recordedLogs.FilterMessage("attempt").All()
+	if assert.Equal(t, 2, len(logs), "each attempt should be logged by the server") {
+		first, second := logs[0].ContextMap(), logs[1].ContextMap()
+		assert.Equal(t, uint64(1), first[zaplogger.FieldAttempt], "first attempt should be logged with its number")
+		assert.Equal(t, uint64(2), second[zaplogger.FieldAttempt], "second attempt should be logged with its number")
+		assert.Equal(t, first[zaplogger.FieldRequestID], second[zaplogger.FieldRequestID], "attempts should be logged with the same request ID")
+	}
+}
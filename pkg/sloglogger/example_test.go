@@ -0,0 +1,59 @@
+package sloglogger_test
+
+import (
+	"context"
+	"log/slog"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/sloglogger"
+)
+
+// ExampleNew creates a new sloglogger and uses its interceptors
+func ExampleNew() {
+	l, err := sloglogger.New(
+		sloglogger.WithServerName("foobar service"),
+		sloglogger.WithFields(
+			sloglogger.FieldMethod,
+			sloglogger.FieldRemoteAddr,
+			sloglogger.FieldServerName,
+		),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(l.UnaryInterceptor()),
+		grpc.StreamInterceptor(l.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
+
+// ExampleNewZapHandler writes slog records with a zap logger
+func ExampleNewZapHandler() {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		panic(err)
+	}
+	l, err := sloglogger.New(
+		sloglogger.WithLogger(slog.New(sloglogger.NewZapHandler(logger))),
+	)
+	if err != nil {
+		panic(err)
+	}
+	l.GetLogger().Info("hello world")
+}
+
+func ExampleGetFromContext() {
+	// in the body of a service method like :
+	// func MyServiceUnaryMethod(ctx context.Context, param service.Type) (service.Type, error) {
+	logger, err := sloglogger.GetFromContext(ctx)
+	if err != nil {
+		panic(err)
+	}
+	logger.Info("important message", slog.String("foo", "bar"))
+}
+
+var ctx context.Context
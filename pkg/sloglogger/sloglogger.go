@@ -0,0 +1,282 @@
+// Package sloglogger allows to set and use a log/slog logger in gRPC service handlers, with the
+// same API and fields as github.com/jucrouzet/grpcutils/pkg/zaplogger
+package sloglogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/logfields"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+)
+
+// Logger is a log/slog logger for a grpc server methods
+type Logger struct {
+	fields     []Field
+	logger     *slog.Logger
+	serverName string
+	policies   map[string]logfields.Policy
+	plan       *logfields.Plan
+}
+
+// Option is the Logger option functions type
+type Option func(*Logger) error
+
+// Field is the type of logger fields
+type Field string
+
+const (
+	// FieldServerName adds the server name in log messages
+	FieldServerName = logfields.ServerName
+	// FieldServerType adds the server type in log messages
+	FieldServerType = logfields.ServerType
+	// FieldRemoteAddr adds the remote address of the caller in log messages
+	FieldRemoteAddr = logfields.RemoteAddr
+	// FieldMethod adds the called method name in log messages
+	FieldMethod = logfields.Method
+	// FieldRequestID adds the request unique correlation ID in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/requestid
+	FieldRequestID = logfields.RequestID
+	// FieldGeoCountry adds the country of the caller's remote address in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/geoip
+	FieldGeoCountry = logfields.GeoCountry
+	// FieldASN adds the autonomous system number of the caller's remote address in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/geoip
+	FieldASN = logfields.ASN
+	// FieldTraceID adds the OpenTelemetry trace ID of the call in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/tracing
+	FieldTraceID = logfields.TraceID
+	// FieldSpanID adds the OpenTelemetry span ID of the call in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/tracing
+	FieldSpanID = logfields.SpanID
+	// FieldAttempt adds the attempt number of calls, from the AttemptMetadataName incoming metadata,
+	// in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/retry
+	FieldAttempt = logfields.Attempt
+)
+
+// AttemptMetadataName is the name of the metadata holding the attempt number, starting at 1, of a
+// retried call.
+const AttemptMetadataName = logfields.AttemptMetadataName
+
+// FailurePolicy is the behaviour of the interceptors when the value of a field is unavailable, like
+// the remote address of calls over some in-process transports.
+type FailurePolicy = logfields.FailurePolicy
+
+const (
+	// FailureSkip omits unavailable fields from log messages, it is the default policy
+	FailureSkip = logfields.FailureSkip
+	// FailurePlaceholder adds unavailable fields to log messages with a placeholder value
+	FailurePlaceholder = logfields.FailurePlaceholder
+	// FailureFail fails calls with a codes.Internal error when the field is unavailable
+	FailureFail = logfields.FailureFail
+	// DefaultPlaceholder is the value of unavailable fields with FailurePlaceholder, unless set with
+	// WithFieldPlaceholder
+	DefaultPlaceholder = logfields.DefaultPlaceholder
+)
+
+var (
+	// ErrInvalidOptionValue is returned when trying to use an invalid option value
+	ErrInvalidOptionValue = errors.New("invalid option value")
+	// ErrNoLoggerInContext is returned when trying get a logger from a context that doesn't have one
+	ErrNoLoggerInContext = errors.New("no logger in context")
+)
+
+type contextValueKeyType string
+
+var contextValueKey = contextValueKeyType("github.com/jucrouzet/grpcutils/sloglogger value")
+
+// GetFromContext returns the logger from a context that has been set in UnaryInterceptor or
+// StreamInterceptor.
+// If logger is not set in context and noopLoggerIfNotPresent is not specified or false
+// ErrNoLoggerInContext is returned.
+// If logger is not set in context and noopLoggerIfNotPresent is true, a noop logger is returned
+// and err can be ignored.
+func GetFromContext(ctx context.Context, noopLoggerIfNotPresent ...bool) (*slog.Logger, error) {
+	logger, ok := ctx.Value(contextValueKey).(*slog.Logger)
+	if !ok || logger == nil {
+		if len(noopLoggerIfNotPresent) > 0 && noopLoggerIfNotPresent[0] {
+			return slog.New(discardHandler{}), nil
+		}
+		return nil, ErrNoLoggerInContext
+	}
+	return logger, nil
+}
+
+// WithLogger specifies which slog logger to use for logging.
+// If not set, slog.Default() is used.
+func WithLogger(logger *slog.Logger) Option {
+	return func(l *Logger) error {
+		if logger == nil {
+			return errors.New("cannot use a nil logger")
+		}
+		l.logger = logger
+		return nil
+	}
+}
+
+// WithFields adds fields to log messages
+func WithFields(fields ...Field) Option {
+	return func(l *Logger) error {
+		for _, f := range fields {
+			if !l.HasField(f) {
+				l.fields = append(l.fields, f)
+			}
+		}
+		return nil
+	}
+}
+
+// WithFieldFailurePolicy sets the behaviour of the interceptors when the value of field is
+// unavailable.
+// If not set, FailureSkip is used.
+func WithFieldFailurePolicy(field Field, policy FailurePolicy) Option {
+	return func(l *Logger) error {
+		if !policy.Valid() {
+			return fmt.Errorf("invalid failure policy %d", policy)
+		}
+		p := l.policies[string(field)]
+		p.Failure = policy
+		l.policies[string(field)] = p
+		return nil
+	}
+}
+
+// WithFieldPlaceholder makes the interceptors use placeholder as value of field when it is
+// unavailable, setting its failure policy to FailurePlaceholder.
+func WithFieldPlaceholder(field Field, placeholder string) Option {
+	return func(l *Logger) error {
+		if placeholder == "" {
+			return errors.New("placeholder cannot be empty")
+		}
+		l.policies[string(field)] = logfields.Policy{Failure: FailurePlaceholder, Placeholder: placeholder}
+		return nil
+	}
+}
+
+// WithServerName sets the FieldServerName field value to log message.
+func WithServerName(serverName string) Option {
+	return func(l *Logger) error {
+		if serverName == "" {
+			return errors.New("server name cannot be empty")
+		}
+		l.serverName = serverName
+		return nil
+	}
+}
+
+// New creates a new instance of Logger with specified options
+func New(opts ...Option) (*Logger, error) {
+	l := &Logger{
+		policies: make(map[string]logfields.Policy),
+	}
+	for _, opt := range opts {
+		if err := opt(l); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	if l.logger == nil {
+		l.logger = slog.Default()
+	}
+	keys := make([]string, 0, len(l.fields))
+	for _, f := range l.fields {
+		keys = append(keys, string(f))
+	}
+	l.plan = logfields.NewPlan(keys, l.policies)
+	if l.HasField(FieldServerName) && l.serverName != "" {
+		l.logger = l.logger.With(slog.String(FieldServerName, l.serverName))
+	}
+	return l, nil
+}
+
+// GetLogger returns the slog logger
+func (l *Logger) GetLogger() *slog.Logger {
+	return l.logger
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that sets logger in call context
+func (l *Logger) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		infos *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		logger, err := l.getForCall(ctx, infos.FullMethod, infos.Server)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed setting request logger")
+		}
+		return handler(context.WithValue(ctx, contextValueKey, logger), req)
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that sets logger in stream context
+func (l *Logger) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		infos *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		logger, err := l.getForCall(stream.Context(), infos.FullMethod, srv)
+		if err != nil {
+			return status.Error(codes.Internal, "failed setting request logger")
+		}
+		return handler(srv, &streamutil.ServerStream{
+			ServerStream: stream,
+			Ctx:          context.WithValue(stream.Context(), contextValueKey, logger),
+		})
+	}
+}
+
+// HasField returns whether field is added to log messages
+func (l *Logger) HasField(field Field) bool {
+	for _, f := range l.fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// getForCall returns the request logger of a call, server being the service implementation.
+func (l *Logger) getForCall(ctx context.Context, fullMethod string, server interface{}) (*slog.Logger, error) {
+	var buf [logfields.MaxFields]logfields.Field
+	extracted, err := l.plan.Extract(ctx, fullMethod, server, buf[:0])
+	if err != nil {
+		return nil, err
+	}
+	if len(extracted) == 0 {
+		return l.logger, nil
+	}
+	args := make([]any, 0, len(extracted))
+	for _, f := range extracted {
+		if f.IsUint {
+			args = append(args, slog.Uint64(f.Key, uint64(f.Uint)))
+		} else {
+			args = append(args, slog.String(f.Key, f.String))
+		}
+	}
+	return l.logger.With(args...), nil
+}
+
+// discardHandler is a slog.Handler discarding all records
+type discardHandler struct{}
+
+// Enabled returns false
+func (discardHandler) Enabled(context.Context, slog.Level) bool { return false }
+
+// Handle discards the record
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+
+// WithAttrs returns the handler
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+// WithGroup returns the handler
+func (h discardHandler) WithGroup(string) slog.Handler { return h }
@@ -0,0 +1,152 @@
+package sloglogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+)
+
+// recordedLogs returns a slog logger writing JSON records in buf, and a function decoding them
+func recordedLogs() (*slog.Logger, func() []map[string]interface{}) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	return logger, func() []map[string]interface{} {
+		var records []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			record := map[string]interface{}{}
+			_ = json.Unmarshal([]byte(line), &record)
+			records = append(records, record)
+		}
+		buf.Reset()
+		return records
+	}
+}
+
+func TestGetFromContext(t *testing.T) {
+	l, err := GetFromContext(context.Background())
+	assert.ErrorIs(t, err, ErrNoLoggerInContext, "GetFromContext with a non-sloglogger context should return a ErrNoLoggerInContext error")
+	assert.Nil(t, l, "GetFromContext with a non-sloglogger context should not return a logger")
+
+	l, err = GetFromContext(context.Background(), true)
+	assert.Nil(t, err, "GetFromContext with a non-sloglogger context and noopLoggerIfNotPresent to true should not return an error")
+	if assert.NotNil(t, l, "GetFromContext with a non-sloglogger context should return a logger") {
+		assert.False(t, l.Enabled(context.Background(), slog.LevelError), "noop logger should not be enabled")
+	}
+}
+
+func TestNew(t *testing.T) {
+	l, err := New(WithLogger(nil))
+	assert.Nil(t, l, "New() should not return a logger with a nil logger")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with a nil logger")
+
+	l, err = New(WithServerName(""))
+	assert.Nil(t, l, "New() should not return a logger with an empty server name")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "New() should return a ErrInvalidOptionValue error with an empty server name")
+
+	l, err = New()
+	assert.Nil(t, err, "New() should not return an error without options")
+	assert.Equal(t, slog.Default(), l.GetLogger(), "New() should use default logger without WithLogger")
+
+	logger, records := recordedLogs()
+	l, _ = New(WithLogger(logger), WithServerName("foobar"), WithFields(FieldServerName))
+	l.GetLogger().Info("test")
+	if logs := records(); assert.Equal(t, 1, len(logs), "there should be a log message") {
+		assert.Equal(t, "foobar", logs[0][FieldServerName], "server name should be logged")
+	}
+}
+
+type dummyLoggerWithFields struct {
+	foobar.UnimplementedDummyServiceServer
+}
+
+func (d *dummyLoggerWithFields) Foo(ctx context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	logger, _ := GetFromContext(ctx)
+	logger.Debug("test")
+	return &foobar.Empty{}, nil
+}
+
+func (d *dummyLoggerWithFields) FooS(s foobar.DummyService_FooSServer) error {
+	logger, _ := GetFromContext(s.Context())
+	logger.Debug("test")
+	for {
+		_, err := s.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	logger, records := recordedLogs()
+	l, _ := New(WithLogger(logger), WithFields(FieldServerType, FieldRemoteAddr, FieldRemoteAddr, FieldRequestID))
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor, l.UnaryInterceptor()),
+	}
+	ctx := requestid.AppendToOutgoingContext(context.Background(), "I'm a unique ID")
+	utils.TestCallFoo(t, &dummyLoggerWithFields{}, nil, opts, ctx)
+	if logs := records(); assert.Equal(t, 1, len(logs), "there should be a log message") {
+		assert.Equal(t, "bufconn", logs[0][FieldRemoteAddr], "remote addr should be logged")
+		assert.Equal(t, "*sloglogger.dummyLoggerWithFields", logs[0][FieldServerType], "server type should be logged")
+		assert.Equal(t, "I'm a unique ID", logs[0][FieldRequestID], "request ID should be logged")
+		assert.NotContains(t, logs[0], FieldMethod, "method should not be logged if not set")
+	}
+
+	l, _ = New(WithLogger(logger), WithFields(FieldMethod, FieldRequestID))
+	opts = []grpc.ServerOption{
+		grpc.StreamInterceptor(l.StreamInterceptor()),
+	}
+	utils.TestCallFooS(t, &dummyLoggerWithFields{}, nil, opts)
+	if logs := records(); assert.Equal(t, 1, len(logs), "there should be a log message") {
+		assert.Equal(t, "/foobar.DummyService/FooS", logs[0][FieldMethod], "method should be logged")
+		assert.NotContains(t, logs[0], FieldRequestID, "request ID should not be logged if not sent")
+	}
+}
+
+func TestWithFieldFailurePolicy(t *testing.T) {
+	l, err := New(WithFieldFailurePolicy(FieldRemoteAddr, FailurePolicy(42)))
+	assert.Nil(t, l, "WithFieldFailurePolicy() should not return a logger with an invalid policy")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithFieldFailurePolicy() should return a ErrInvalidOptionValue error with an invalid policy")
+
+	logger, records := recordedLogs()
+	infos := &grpc.UnaryServerInfo{FullMethod: "/foobar.DummyService/Foo"}
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		logger, _ := GetFromContext(ctx)
+		logger.Info("test")
+		return nil, nil
+	}
+	l, _ = New(WithLogger(logger), WithFields(FieldRemoteAddr))
+	_, err = l.UnaryInterceptor()(context.Background(), nil, infos, handler)
+	assert.Nil(t, err, "unavailable fields should not fail calls by default")
+	if logs := records(); assert.Equal(t, 1, len(logs), "there should be a log message") {
+		assert.NotContains(t, logs[0], FieldRemoteAddr, "unavailable fields should be skipped by default")
+	}
+
+	l, _ = New(WithLogger(logger), WithFields(FieldRemoteAddr), WithFieldPlaceholder(FieldRemoteAddr, "in-process"))
+	_, _ = l.UnaryInterceptor()(context.Background(), nil, infos, handler)
+	if logs := records(); assert.Equal(t, 1, len(logs), "there should be a log message") {
+		assert.Equal(t, "in-process", logs[0][FieldRemoteAddr], "unavailable fields should be logged with placeholder")
+	}
+
+	l, _ = New(WithLogger(logger), WithFields(FieldRemoteAddr), WithFieldFailurePolicy(FieldRemoteAddr, FailureFail))
+	_, err = l.UnaryInterceptor()(context.Background(), nil, infos, handler)
+	assert.Equal(t, codes.Internal, status.Code(err), "unavailable fields with fail policy should fail calls")
+}
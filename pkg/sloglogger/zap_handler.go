@@ -0,0 +1,155 @@
+package sloglogger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ZapHandler is a slog.Handler writing records to a zap logger, allowing slog and zap loggers to
+// share the same output.
+// slog groups are written as zap namespaces or objects.
+// zap always writes the entry time, so records with a zero time, which should have no time, are
+// written with the time of the zap logger clock.
+type ZapHandler struct {
+	logger *zap.Logger
+	// groups are the names of the groups opened by WithGroup that hold no attribute yet, written as
+	// namespaces with their first attributes so that empty groups are not written.
+	groups []string
+}
+
+// NewZapHandler creates a new ZapHandler writing to logger.
+func NewZapHandler(logger *zap.Logger) *ZapHandler {
+	return &ZapHandler{logger: logger}
+}
+
+// Enabled returns whether the zap logger is enabled for level
+func (h *ZapHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Core().Enabled(zapLevel(level))
+}
+
+// Handle writes the record to the zap logger
+func (h *ZapHandler) Handle(_ context.Context, r slog.Record) error {
+	ce := h.logger.Check(zapLevel(r.Level), r.Message)
+	if ce == nil {
+		return nil
+	}
+	if !r.Time.IsZero() {
+		ce.Entry.Time = r.Time
+	}
+	if ce.Entry.Caller.Defined && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ce.Entry.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+	}
+	fields := h.namespaces(r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, a)
+		return true
+	})
+	if len(fields) == len(h.groups) {
+		fields = nil
+	}
+	ce.Write(fields...)
+	return nil
+}
+
+// WithAttrs returns a new ZapHandler which zap logger has the attributes, or the handler itself if
+// there are none
+func (h *ZapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := h.namespaces(len(attrs))
+	for _, a := range attrs {
+		fields = appendAttr(fields, a)
+	}
+	if len(fields) == len(h.groups) {
+		return h
+	}
+	return &ZapHandler{logger: h.logger.With(fields...)}
+}
+
+// WithGroup returns a new ZapHandler nesting the following attributes in a namespace, or the
+// handler itself if name is empty
+func (h *ZapHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &ZapHandler{logger: h.logger, groups: append(h.groups[:len(h.groups):len(h.groups)], name)}
+}
+
+// namespaces returns the zap namespaces of the pending groups, with room for n more fields.
+func (h *ZapHandler) namespaces(n int) []zap.Field {
+	fields := make([]zap.Field, 0, len(h.groups)+n)
+	for _, group := range h.groups {
+		fields = append(fields, zap.Namespace(group))
+	}
+	return fields
+}
+
+// zapLevel converts a slog level to the closest zap level below it.
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case level < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case level < slog.LevelError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// appendAttr appends the zap fields of a slog attribute.
+func appendAttr(fields []zap.Field, a slog.Attr) []zap.Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		if a.Key == "" {
+			for _, ga := range attrs {
+				fields = appendAttr(fields, ga)
+			}
+			return fields
+		}
+		return append(fields, zap.Object(a.Key, groupMarshaler(attrs)))
+	case slog.KindString:
+		return append(fields, zap.String(a.Key, a.Value.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(a.Key, a.Value.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(a.Key, a.Value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(a.Key, a.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(a.Key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(a.Key, a.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(a.Key, a.Value.Time()))
+	}
+	if err, ok := a.Value.Any().(error); ok {
+		return append(fields, zap.NamedError(a.Key, err))
+	}
+	return append(fields, zap.Any(a.Key, a.Value.Any()))
+}
+
+// groupMarshaler marshals the attributes of a slog group as a zap object.
+type groupMarshaler []slog.Attr
+
+// MarshalLogObject adds the group attributes to the object encoder
+func (g groupMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, a := range g {
+		for _, f := range appendAttr(nil, a) {
+			f.AddTo(enc)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package sloglogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapHandler(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.InfoLevel)
+	logger := slog.New(NewZapHandler(zap.New(core, zap.AddCaller())))
+
+	logger.Debug("debug")
+	assert.Equal(t, 0, len(recordedLogs.All()), "disabled levels should not be logged")
+
+	logger.With("service", "foobar").WithGroup("request").Warn("test",
+		slog.String("string", "value"),
+		slog.Int("int", 42),
+		slog.Duration("duration", time.Second),
+		slog.Any("error", errors.New("boom")),
+		slog.Group("user", slog.String("name", "John")),
+	)
+	if assert.Equal(t, 1, len(recordedLogs.All()), "records should be logged") {
+		entry := recordedLogs.TakeAll()[0]
+		assert.Equal(t, zapcore.WarnLevel, entry.Level, "slog levels should be converted to zap levels")
+		assert.Equal(t, "test", entry.Message, "record message should be logged")
+		assert.Equal(t, "zap_handler_test.go", filepath.Base(entry.Caller.File), "record caller should be logged")
+		assert.Equal(t, map[string]interface{}{
+			"service": "foobar",
+			"request": map[string]interface{}{
+				"string":   "value",
+				"int":      int64(42),
+				"duration": time.Second,
+				"error":    "boom",
+				"user":     map[string]interface{}{"name": "John"},
+			},
+		}, entry.ContextMap(), "attributes and groups should be logged")
+	}
+
+	logger.Log(context.Background(), slog.LevelError+2, "custom level")
+	if assert.Equal(t, 1, len(recordedLogs.All()), "records should be logged") {
+		assert.Equal(t, zapcore.ErrorLevel, recordedLogs.TakeAll()[0].Level, "custom levels should be converted to the closest zap level below")
+	}
+}
+
+func TestZapHandler_emptyGroups(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.InfoLevel)
+	h := NewZapHandler(zap.New(core))
+	assert.Same(t, h, h.WithGroup(""), "WithGroup() should return the handler itself with an empty name")
+	assert.Same(t, h, h.WithAttrs(nil), "WithAttrs() should return the handler itself without attributes")
+
+	logger := slog.New(h).With("service", "foobar").WithGroup("request")
+	logger.Info("empty")
+	logger.Info("not empty", "id", 42)
+	if assert.Equal(t, 2, len(recordedLogs.All()), "records should be logged") {
+		entries := recordedLogs.TakeAll()
+		assert.Equal(t, map[string]interface{}{"service": "foobar"}, entries[0].ContextMap(), "groups without attributes should not be logged")
+		assert.Equal(t, map[string]interface{}{
+			"service": "foobar",
+			"request": map[string]interface{}{"id": int64(42)},
+		}, entries[1].ContextMap(), "groups with attributes should be logged")
+	}
+}
+
+// fixedClock is a zap clock always returning the same time
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func (c fixedClock) NewTicker(d time.Duration) *time.Ticker {
+	return time.NewTicker(d)
+}
+
+func TestZapHandler_zeroTime(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.InfoLevel)
+	clock := fixedClock(time.Date(2022, 6, 13, 8, 0, 0, 0, time.UTC))
+	h := NewZapHandler(zap.New(core, zap.WithClock(clock)))
+
+	assert.Nil(t, h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "no time", 0)), "Handle() should not fail")
+	recordTime := time.Date(2022, 6, 13, 9, 0, 0, 0, time.UTC)
+	assert.Nil(t, h.Handle(context.Background(), slog.NewRecord(recordTime, slog.LevelInfo, "time", 0)), "Handle() should not fail")
+	if assert.Equal(t, 2, len(recordedLogs.All()), "records should be logged") {
+		entries := recordedLogs.TakeAll()
+		assert.Equal(t, time.Time(clock), entries[0].Time, "records without time should be logged with the logger clock time")
+		assert.Equal(t, recordTime, entries[1].Time, "records should be logged with their time")
+	}
+}
+
+func TestZapHandler_slogtest(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = slog.TimeKey
+	encoderConfig.LevelKey = slog.LevelKey
+	encoderConfig.MessageKey = slog.MessageKey
+	encoderConfig.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(buf), zapcore.DebugLevel)
+
+	clock := fixedClock(time.Date(2022, 6, 13, 8, 0, 0, 0, time.UTC))
+	err := slogtest.TestHandler(NewZapHandler(zap.New(core, zap.WithClock(clock))), func() []map[string]any {
+		var records []map[string]any
+		for _, line := range bytes.Split(buf.Bytes(), []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			var record map[string]any
+			if err := json.Unmarshal(line, &record); err != nil {
+				t.Fatal(err)
+			}
+			// zap always writes the entry time, the clock one for records without time
+			if record[slog.TimeKey] == time.Time(clock).Format(time.RFC3339Nano) {
+				delete(record, slog.TimeKey)
+			}
+			records = append(records, record)
+		}
+		return records
+	})
+	assert.NoError(t, err, "ZapHandler should conform to the slog.Handler contract")
+}
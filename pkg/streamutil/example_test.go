@@ -0,0 +1,37 @@
+package streamutil_test
+
+import (
+	"log"
+
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+)
+
+// ExampleServerStream logs the number of messages received and sent on each stream, and each
+// failure to send a message
+func ExampleServerStream() {
+	interceptor := func(
+		srv interface{},
+		stream grpc.ServerStream,
+		infos *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ns := &streamutil.ServerStream{
+			ServerStream: stream,
+			SendMsgHook: func(m interface{}, next streamutil.MsgHandler) error {
+				err := next(m)
+				if err != nil {
+					log.Printf("%s : failed sending message : %s", infos.FullMethod, err)
+				}
+				return err
+			},
+		}
+		err := handler(srv, ns)
+		log.Printf("%s : %d messages received, %d sent", infos.FullMethod, ns.MessagesReceived(), ns.MessagesSent())
+		return err
+	}
+	server := grpc.NewServer(grpc.ChainStreamInterceptor(interceptor))
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
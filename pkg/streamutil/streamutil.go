@@ -0,0 +1,150 @@
+// Package streamutil provides a wrapped gRPC server stream, for stream interceptors replacing the
+// stream context, observing or transforming messages and metadata, and counting messages.
+package streamutil
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// MsgHandler receives or sends a message
+type MsgHandler func(m interface{}) error
+
+// MsgHook intercepts receiving or sending a message, calling next to actually receive or send it.
+// Hooks can observe the message, transform it, or return an error instead of calling next.
+type MsgHook func(m interface{}, next MsgHandler) error
+
+// HeaderHandler sets or sends header metadata
+type HeaderHandler func(md metadata.MD) error
+
+// HeaderHook intercepts setting or sending header metadata, calling next to actually set or send
+// it.
+type HeaderHook func(md metadata.MD, next HeaderHandler) error
+
+// TrailerHook intercepts setting trailer metadata, calling next to actually set it.
+type TrailerHook func(md metadata.MD, next func(metadata.MD))
+
+// ServerStream wraps a gRPC server stream, replacing its context with Ctx if set, and calling the
+// hooks which are set around its methods.
+// Messages actually received and sent by the wrapped stream are counted, with their size if they
+// are protobuf messages.
+type ServerStream struct {
+	grpc.ServerStream
+	// Ctx replaces the context of the wrapped stream, if set
+	Ctx context.Context
+
+	// RecvMsgHook intercepts RecvMsg, if set
+	RecvMsgHook MsgHook
+	// SendMsgHook intercepts SendMsg, if set
+	SendMsgHook MsgHook
+	// SetHeaderHook intercepts SetHeader, if set
+	SetHeaderHook HeaderHook
+	// SendHeaderHook intercepts SendHeader, if set
+	SendHeaderHook HeaderHook
+	// SetTrailerHook intercepts SetTrailer, if set
+	SetTrailerHook TrailerHook
+
+	received      int64
+	sent          int64
+	receivedBytes int64
+	sentBytes     int64
+}
+
+// Context returns Ctx if set, else the wrapped stream context
+func (s *ServerStream) Context() context.Context {
+	if s.Ctx != nil {
+		return s.Ctx
+	}
+	return s.ServerStream.Context()
+}
+
+// RecvMsg receives a message, through RecvMsgHook if set
+func (s *ServerStream) RecvMsg(m interface{}) error {
+	if s.RecvMsgHook != nil {
+		return s.RecvMsgHook(m, s.recvMsg)
+	}
+	return s.recvMsg(m)
+}
+
+// SendMsg sends a message, through SendMsgHook if set
+func (s *ServerStream) SendMsg(m interface{}) error {
+	if s.SendMsgHook != nil {
+		return s.SendMsgHook(m, s.sendMsg)
+	}
+	return s.sendMsg(m)
+}
+
+// SetHeader sets header metadata, through SetHeaderHook if set
+func (s *ServerStream) SetHeader(md metadata.MD) error {
+	if s.SetHeaderHook != nil {
+		return s.SetHeaderHook(md, s.ServerStream.SetHeader)
+	}
+	return s.ServerStream.SetHeader(md)
+}
+
+// SendHeader sends header metadata, through SendHeaderHook if set
+func (s *ServerStream) SendHeader(md metadata.MD) error {
+	if s.SendHeaderHook != nil {
+		return s.SendHeaderHook(md, s.ServerStream.SendHeader)
+	}
+	return s.ServerStream.SendHeader(md)
+}
+
+// SetTrailer sets trailer metadata, through SetTrailerHook if set
+func (s *ServerStream) SetTrailer(md metadata.MD) {
+	if s.SetTrailerHook != nil {
+		s.SetTrailerHook(md, s.ServerStream.SetTrailer)
+		return
+	}
+	s.ServerStream.SetTrailer(md)
+}
+
+// MessagesReceived returns the number of messages received
+func (s *ServerStream) MessagesReceived() int64 {
+	return atomic.LoadInt64(&s.received)
+}
+
+// MessagesSent returns the number of messages sent
+func (s *ServerStream) MessagesSent() int64 {
+	return atomic.LoadInt64(&s.sent)
+}
+
+// BytesReceived returns the size of the protobuf messages received
+func (s *ServerStream) BytesReceived() int64 {
+	return atomic.LoadInt64(&s.receivedBytes)
+}
+
+// BytesSent returns the size of the protobuf messages sent
+func (s *ServerStream) BytesSent() int64 {
+	return atomic.LoadInt64(&s.sentBytes)
+}
+
+func (s *ServerStream) recvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.received, 1)
+	atomic.AddInt64(&s.receivedBytes, int64(messageSize(m)))
+	return nil
+}
+
+func (s *ServerStream) sendMsg(m interface{}) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.sent, 1)
+	atomic.AddInt64(&s.sentBytes, int64(messageSize(m)))
+	return nil
+}
+
+func messageSize(m interface{}) int {
+	msg, ok := m.(proto.Message)
+	if !ok || msg == nil {
+		return 0
+	}
+	return proto.Size(msg)
+}
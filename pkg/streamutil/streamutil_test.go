@@ -0,0 +1,145 @@
+package streamutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type contextKey struct{}
+
+// fakeServerStream records sent messages and metadata, and receives messages until failing
+type fakeServerStream struct {
+	grpc.ServerStream
+	toReceive []proto.Message
+	sent      []interface{}
+	header    metadata.MD
+	trailer   metadata.MD
+	err       error
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return context.WithValue(context.Background(), contextKey{}, "wrapped")
+}
+
+func (s *fakeServerStream) RecvMsg(m interface{}) error {
+	if len(s.toReceive) == 0 {
+		return errors.New("no more messages")
+	}
+	proto.Merge(m.(proto.Message), s.toReceive[0])
+	s.toReceive = s.toReceive[1:]
+	return nil
+}
+
+func (s *fakeServerStream) SendMsg(m interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *fakeServerStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *fakeServerStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func TestServerStream_Context(t *testing.T) {
+	s := &ServerStream{ServerStream: &fakeServerStream{}}
+	assert.Equal(t, "wrapped", s.Context().Value(contextKey{}), "wrapped stream context should be used without Ctx")
+	s.Ctx = context.WithValue(context.Background(), contextKey{}, "replaced")
+	assert.Equal(t, "replaced", s.Context().Value(contextKey{}), "Ctx should replace wrapped stream context")
+}
+
+func TestServerStream_counters(t *testing.T) {
+	fake := &fakeServerStream{toReceive: []proto.Message{wrapperspb.String("foo"), wrapperspb.String("barbaz")}}
+	s := &ServerStream{ServerStream: fake}
+	for i := 0; i < 3; i++ {
+		_ = s.RecvMsg(&wrapperspb.StringValue{})
+	}
+	assert.Nil(t, s.SendMsg(wrapperspb.String("foo")), "sending should not fail")
+	assert.Nil(t, s.SendMsg("not a protobuf message"), "sending should not fail")
+	fake.err = errors.New("failed")
+	assert.NotNil(t, s.SendMsg(wrapperspb.String("foo")), "sending error should be returned")
+
+	assert.Equal(t, int64(2), s.MessagesReceived(), "only received messages should be counted")
+	assert.Equal(t, int64(proto.Size(wrapperspb.String("foo"))+proto.Size(wrapperspb.String("barbaz"))), s.BytesReceived(), "received messages size should be counted")
+	assert.Equal(t, int64(2), s.MessagesSent(), "only sent messages should be counted")
+	assert.Equal(t, int64(proto.Size(wrapperspb.String("foo"))), s.BytesSent(), "only protobuf messages size should be counted")
+}
+
+func TestServerStream_msgHooks(t *testing.T) {
+	fake := &fakeServerStream{toReceive: []proto.Message{wrapperspb.String("foo")}}
+	s := &ServerStream{
+		ServerStream: fake,
+		RecvMsgHook: func(m interface{}, next MsgHandler) error {
+			if err := next(m); err != nil {
+				return err
+			}
+			m.(*wrapperspb.StringValue).Value += "-received"
+			return nil
+		},
+		SendMsgHook: func(m interface{}, next MsgHandler) error {
+			if m.(*wrapperspb.StringValue).GetValue() == "" {
+				return errors.New("empty message")
+			}
+			return next(wrapperspb.String(m.(*wrapperspb.StringValue).GetValue() + "-sent"))
+		},
+	}
+	received := &wrapperspb.StringValue{}
+	assert.Nil(t, s.RecvMsg(received), "receiving should not fail")
+	assert.Equal(t, "foo-received", received.GetValue(), "RecvMsgHook should transform received messages")
+
+	assert.Nil(t, s.SendMsg(wrapperspb.String("bar")), "sending should not fail")
+	assert.NotNil(t, s.SendMsg(wrapperspb.String("")), "SendMsgHook error should be returned")
+	assert.Len(t, fake.sent, 1, "messages rejected by SendMsgHook should not be sent")
+	assert.Equal(t, "bar-sent", fake.sent[0].(*wrapperspb.StringValue).GetValue(), "SendMsgHook should transform sent messages")
+	assert.Equal(t, int64(1), s.MessagesSent(), "messages rejected by SendMsgHook should not be counted")
+}
+
+func TestServerStream_metadataHooks(t *testing.T) {
+	fake := &fakeServerStream{}
+	s := &ServerStream{ServerStream: fake}
+	assert.Nil(t, s.SetHeader(metadata.Pairs("foo", "bar")), "setting header should not fail")
+	s.SetTrailer(metadata.Pairs("foo", "bar"))
+	assert.Equal(t, []string{"bar"}, fake.header.Get("foo"), "header should be set without hook")
+	assert.Equal(t, []string{"bar"}, fake.trailer.Get("foo"), "trailer should be set without hook")
+
+	fake = &fakeServerStream{}
+	addHook := func(md metadata.MD, next HeaderHandler) error {
+		return next(metadata.Join(md, metadata.Pairs("hook", "true")))
+	}
+	s = &ServerStream{
+		ServerStream:   fake,
+		SetHeaderHook:  addHook,
+		SendHeaderHook: addHook,
+		SetTrailerHook: func(md metadata.MD, next func(metadata.MD)) {
+			next(metadata.Join(md, metadata.Pairs("hook", "true")))
+		},
+	}
+	assert.Nil(t, s.SetHeader(metadata.Pairs("foo", "bar")), "setting header should not fail")
+	assert.Nil(t, s.SendHeader(metadata.Pairs("baz", "qux")), "sending header should not fail")
+	s.SetTrailer(metadata.Pairs("foo", "bar"))
+	assert.Equal(t, []string{"true", "true"}, fake.header.Get("hook"), "SetHeaderHook and SendHeaderHook should transform header")
+	assert.Equal(t, []string{"qux"}, fake.header.Get("baz"), "header should be sent")
+	assert.Equal(t, []string{"true"}, fake.trailer.Get("hook"), "SetTrailerHook should transform trailer")
+
+	s.SetHeaderHook = func(metadata.MD, HeaderHandler) error {
+		return errors.New("rejected")
+	}
+	assert.NotNil(t, s.SetHeader(metadata.Pairs("foo", "bar")), "SetHeaderHook error should be returned")
+}
@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+)
+
+// UnaryClientInterceptor returns a gRPC client unary interceptor that creates a span per call,
+// propagating it to the server in outgoing metadata.
+func (t *Tracing) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ctx, span := t.startClient(ctx, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		end(span, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a gRPC client stream interceptor that creates a span per stream,
+// propagating it to the server in outgoing metadata, with an event per message.
+// The span ends when receiving a message fails, or when the response of a client streaming call
+// is received.
+func (t *Tracing) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		ctx, span := t.startClient(ctx, method)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			end(span, err)
+			return nil, err
+		}
+		return &tracingClientStream{
+			ClientStream:  stream,
+			serverStreams: desc.ServerStreams,
+			events:        messageEvents{span: span},
+		}, nil
+	}
+}
+
+// startClient starts the span of a client call, returning the call context with the span
+// propagated in outgoing metadata.
+func (t *Tracing) startClient(ctx context.Context, method string) (context.Context, trace.Span) {
+	ctx, span := t.tracer.Start(ctx, spanName(method),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(methodAttributes(method)...),
+	)
+	md := metadatautil.CopyOutgoing(ctx)
+	t.propagator.Inject(ctx, metadataCarrier(md))
+	id := requestid.GetFromMeta(md)
+	if id == "" && t.requestIDFromTraceID && span.SpanContext().HasTraceID() {
+		id = span.SpanContext().TraceID().String()
+		md.Set(requestid.MetadataName, id)
+	}
+	if id != "" {
+		span.SetAttributes(AttributeRequestID.String(id))
+	}
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+// tracingClientStream records the messages of a client stream, ending its span once when it ends.
+type tracingClientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	events        messageEvents
+	once          sync.Once
+}
+
+// SendMsg sends a message, recording an event
+func (s *tracingClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.events.recordSent()
+	}
+	return err
+}
+
+// RecvMsg receives a message, recording an event and ending the span if the stream ended
+func (s *tracingClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.events.recordReceived()
+		if !s.serverStreams {
+			s.once.Do(func() { end(s.events.span, nil) })
+		}
+	case err == io.EOF:
+		s.once.Do(func() { end(s.events.span, nil) })
+	default:
+		s.once.Do(func() { end(s.events.span, err) })
+	}
+	return err
+}
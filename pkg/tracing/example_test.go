@@ -0,0 +1,62 @@
+package tracing_test
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/tracing"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+)
+
+// ExampleNew traces calls, logging their trace and span IDs
+func ExampleNew() {
+	tr, err := tracing.New(tracing.WithRequestIDFromTraceID())
+	if err != nil {
+		panic(err)
+	}
+	l, err := zaplogger.New(
+		zaplogger.WithFields(zaplogger.FieldRequestID, zaplogger.FieldTraceID, zaplogger.FieldSpanID),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		// tracing interceptors must be before requestid's ones to use trace IDs as request IDs, and
+		// before zaplogger's ones to log trace and span IDs
+		grpc.ChainUnaryInterceptor(
+			tr.UnaryInterceptor(),
+			requestid.UnaryServerInterceptor,
+			l.UnaryInterceptor(),
+		),
+		grpc.ChainStreamInterceptor(
+			tr.StreamInterceptor(),
+			requestid.StreamServerInterceptor,
+			l.StreamInterceptor(),
+		),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
+
+// ExampleTracing_UnaryClientInterceptor propagates traces to called servers
+func ExampleTracing_UnaryClientInterceptor() {
+	tr, err := tracing.New()
+	if err != nil {
+		panic(err)
+	}
+	conn, err := grpc.DialContext(
+		context.Background(),
+		"localhost:4242",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(tr.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(tr.StreamClientInterceptor()),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	_, _ = foobar.NewDummyServiceClient(conn).Foo(context.Background(), &foobar.Empty{})
+}
@@ -0,0 +1,247 @@
+// Package tracing creates OpenTelemetry spans for gRPC calls, propagating the trace context in
+// metadata and joining traces with request correlation identifiers.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+)
+
+const (
+	// InstrumentationName is the name of the tracer creating spans
+	InstrumentationName = "github.com/jucrouzet/grpcutils/pkg/tracing"
+	// AttributeRequestID is the span attribute holding the request correlation identifier
+	// See github.com/jucrouzet/grpcutils/pkg/requestid
+	AttributeRequestID = attribute.Key("rpc.request_id")
+	// EventMessage is the name of the span events recorded for stream messages
+	EventMessage = "message"
+)
+
+const (
+	attributeMessageType = attribute.Key("message.type")
+	attributeMessageID   = attribute.Key("message.id")
+)
+
+// ErrInvalidOptionValue is returned when trying to use an invalid option value
+var ErrInvalidOptionValue = errors.New("invalid option value")
+
+// Tracing creates spans for gRPC calls
+type Tracing struct {
+	provider             trace.TracerProvider
+	tracer               trace.Tracer
+	propagator           propagation.TextMapPropagator
+	requestIDFromTraceID bool
+}
+
+// Option is the Tracing option functions type
+type Option func(*Tracing) error
+
+// WithTracerProvider sets the tracer provider creating spans.
+// If not set, the global tracer provider (see go.opentelemetry.io/otel.GetTracerProvider) is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(t *Tracing) error {
+		if provider == nil {
+			return errors.New("cannot use a nil tracer provider")
+		}
+		t.provider = provider
+		return nil
+	}
+}
+
+// WithPropagator sets the propagator of the trace context in calls metadata.
+// If not set, the W3C trace context propagator is used.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(t *Tracing) error {
+		if propagator == nil {
+			return errors.New("cannot use a nil propagator")
+		}
+		t.propagator = propagator
+		return nil
+	}
+}
+
+// WithRequestIDFromTraceID makes the interceptors use the trace ID as request correlation
+// identifier of calls that don't have one.
+// Server interceptors must then be called before requestid's ones, which would generate one.
+func WithRequestIDFromTraceID() Option {
+	return func(t *Tracing) error {
+		t.requestIDFromTraceID = true
+		return nil
+	}
+}
+
+// New creates a new instance of Tracing with specified options
+func New(opts ...Option) (*Tracing, error) {
+	t := &Tracing{
+		propagator: propagation.TraceContext{},
+	}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	if t.provider == nil {
+		t.provider = otel.GetTracerProvider()
+	}
+	t.tracer = t.provider.Tracer(InstrumentationName)
+	return t, nil
+}
+
+// RequestIDFromTraceID returns whether the interceptors use the trace ID as request correlation
+// identifier, see WithRequestIDFromTraceID.
+func (t *Tracing) RequestIDFromTraceID() bool {
+	return t.requestIDFromTraceID
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that creates a span per call, child of
+// the span propagated by the caller if any.
+func (t *Tracing) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		infos *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, span := t.startServer(ctx, infos.FullMethod)
+		res, err := handler(ctx, req)
+		end(span, err)
+		return res, err
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that creates a span per stream, child
+// of the span propagated by the caller if any, with an event per message.
+func (t *Tracing) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		infos *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, span := t.startServer(stream.Context(), infos.FullMethod)
+		events := &messageEvents{span: span}
+		err := handler(srv, &streamutil.ServerStream{
+			ServerStream: stream,
+			Ctx:          ctx,
+			RecvMsgHook: func(m interface{}, next streamutil.MsgHandler) error {
+				err := next(m)
+				if err == nil {
+					events.recordReceived()
+				}
+				return err
+			},
+			SendMsgHook: func(m interface{}, next streamutil.MsgHandler) error {
+				err := next(m)
+				if err == nil {
+					events.recordSent()
+				}
+				return err
+			},
+		})
+		end(span, err)
+		return err
+	}
+}
+
+// startServer starts the span of a server call, returning the call context with the span and its
+// request correlation identifier.
+func (t *Tracing) startServer(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md := metadatautil.FromIncoming(ctx)
+	ctx = t.propagator.Extract(ctx, metadataCarrier(md))
+	ctx, span := t.tracer.Start(ctx, spanName(fullMethod),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(methodAttributes(fullMethod)...),
+	)
+	id := requestid.GetFromMeta(md)
+	if id == "" && t.requestIDFromTraceID && span.SpanContext().HasTraceID() {
+		id = span.SpanContext().TraceID().String()
+		md = md.Copy()
+		md.Set(requestid.MetadataName, id)
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+	if id != "" {
+		span.SetAttributes(AttributeRequestID.String(id))
+	}
+	return ctx, span
+}
+
+// spanName returns the name of the span of a call, the full method name without its leading slash.
+func spanName(fullMethod string) string {
+	return strings.TrimPrefix(fullMethod, "/")
+}
+
+// methodAttributes returns the RPC attributes of a call.
+func methodAttributes(fullMethod string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+	name := spanName(fullMethod)
+	if i := strings.LastIndex(name, "/"); i > 0 {
+		attrs = append(attrs, semconv.RPCServiceKey.String(name[:i]), semconv.RPCMethodKey.String(name[i+1:]))
+	}
+	return attrs
+}
+
+// end records the status of a call and ends its span.
+func end(span trace.Span, err error) {
+	s, _ := status.FromError(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int64(int64(s.Code())))
+	if err != nil {
+		span.SetStatus(otelcodes.Error, s.Message())
+	}
+	span.End()
+}
+
+// metadataCarrier is a propagation.TextMapCarrier reading and writing gRPC metadata
+type metadataCarrier metadata.MD
+
+// Get returns the first value of key
+func (c metadataCarrier) Get(key string) string {
+	return metadatautil.GetString(metadata.MD(c), key)
+}
+
+// Set sets the value of key
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys returns the metadata keys
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// messageEvents records an event per message sent and received on a stream
+type messageEvents struct {
+	span     trace.Span
+	received int64
+	sent     int64
+}
+
+func (e *messageEvents) recordReceived() {
+	id := atomic.AddInt64(&e.received, 1)
+	e.span.AddEvent(EventMessage, trace.WithAttributes(attributeMessageType.String("RECEIVED"), attributeMessageID.Int64(id)))
+}
+
+func (e *messageEvents) recordSent() {
+	id := atomic.AddInt64(&e.sent, 1)
+	e.span.AddEvent(EventMessage, trace.WithAttributes(attributeMessageType.String("SENT"), attributeMessageID.Int64(id)))
+}
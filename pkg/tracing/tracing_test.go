@@ -0,0 +1,194 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+)
+
+type dummyTracing struct {
+	foobar.UnimplementedDummyServiceServer
+	err       error
+	requestID string
+}
+
+func (d *dummyTracing) Foo(ctx context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	d.requestID = requestid.GetFromContext(ctx)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &foobar.Empty{}, nil
+}
+
+func (d *dummyTracing) FooS(s foobar.DummyService_FooSServer) error {
+	for {
+		if _, err := s.Recv(); err != nil {
+			return nil
+		}
+		if err := s.Send(&foobar.Empty{}); err != nil {
+			return err
+		}
+	}
+}
+
+func newRecorded(t *testing.T, opts ...Option) (*Tracing, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	tr, err := New(append([]Option{WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr, recorder
+}
+
+// spanOfKind returns the first ended span of a kind
+func spanOfKind(spans []sdktrace.ReadOnlySpan, kind trace.SpanKind) sdktrace.ReadOnlySpan {
+	for _, s := range spans {
+		if s.SpanKind() == kind {
+			return s
+		}
+	}
+	return nil
+}
+
+func attributes(s sdktrace.ReadOnlySpan) map[string]interface{} {
+	attrs := make(map[string]interface{})
+	for _, a := range s.Attributes() {
+		attrs[string(a.Key)] = a.Value.AsInterface()
+	}
+	return attrs
+}
+
+func TestNew(t *testing.T) {
+	invalids := map[string]Option{
+		"WithTracerProvider": WithTracerProvider(nil),
+		"WithPropagator":     WithPropagator(nil),
+	}
+	for name, opt := range invalids {
+		tr, err := New(opt)
+		assert.Nil(t, tr, "%s() should not return a Tracing with a nil value", name)
+		assert.ErrorIs(t, err, ErrInvalidOptionValue, "%s() should return a ErrInvalidOptionValue error with a nil value", name)
+	}
+	tr, err := New()
+	assert.Nil(t, err, "New() should not return an error without options")
+	assert.False(t, tr.RequestIDFromTraceID(), "New() should not use trace IDs as request IDs by default")
+}
+
+func TestTracing_UnaryInterceptors(t *testing.T) {
+	tr, recorder := newRecorded(t)
+	clientOpts := []grpc.DialOption{grpc.WithUnaryInterceptor(tr.UnaryClientInterceptor())}
+	serverOpts := []grpc.ServerOption{grpc.UnaryInterceptor(tr.UnaryInterceptor())}
+	ctx := requestid.AppendToOutgoingContext(context.Background(), "I'm a unique ID")
+
+	_, _, _, err := utils.TestCallFoo(t, &dummyTracing{}, clientOpts, serverOpts, ctx)
+	assert.Nil(t, err, "call should not fail")
+	spans := recorder.Ended()
+	server, client := spanOfKind(spans, trace.SpanKindServer), spanOfKind(spans, trace.SpanKindClient)
+	if assert.NotNil(t, server, "a server span should be created") && assert.NotNil(t, client, "a client span should be created") {
+		assert.Equal(t, "foobar.DummyService/Foo", server.Name(), "span should be named after the method")
+		assert.Equal(t, client.SpanContext().TraceID(), server.SpanContext().TraceID(), "server span should be in the client trace")
+		assert.Equal(t, client.SpanContext().SpanID(), server.Parent().SpanID(), "server span should be a child of the client span")
+		attrs := attributes(server)
+		assert.Equal(t, "grpc", attrs[string(semconv.RPCSystemKey)], "span should have the rpc system")
+		assert.Equal(t, "foobar.DummyService", attrs[string(semconv.RPCServiceKey)], "span should have the rpc service")
+		assert.Equal(t, "Foo", attrs[string(semconv.RPCMethodKey)], "span should have the rpc method")
+		assert.Equal(t, int64(0), attrs[string(semconv.RPCGRPCStatusCodeKey)], "span should have the status code")
+		assert.Equal(t, "I'm a unique ID", attrs[string(AttributeRequestID)], "server span should have the request ID")
+		assert.Equal(t, "I'm a unique ID", attributes(client)[string(AttributeRequestID)], "client span should have the request ID")
+		assert.Equal(t, otelcodes.Unset, server.Status().Code, "successful call span should not have an error status")
+	}
+
+	recorder = tracetest.NewSpanRecorder()
+	tr, _ = New(WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+	_, _, _, err = utils.TestCallFoo(t, &dummyTracing{err: status.Error(codes.NotFound, "not found")}, nil, []grpc.ServerOption{grpc.UnaryInterceptor(tr.UnaryInterceptor())})
+	assert.Equal(t, codes.NotFound, status.Code(err), "call should fail")
+	if assert.Equal(t, 1, len(recorder.Ended()), "a server span should be created") {
+		server := recorder.Ended()[0]
+		assert.False(t, server.Parent().IsValid(), "server span without propagated context should be a root span")
+		assert.Equal(t, int64(codes.NotFound), attributes(server)[string(semconv.RPCGRPCStatusCodeKey)], "span should have the status code")
+		assert.Equal(t, otelcodes.Error, server.Status().Code, "failed call span should have an error status")
+		assert.Equal(t, "not found", server.Status().Description, "failed call span should have the status message")
+		assert.NotContains(t, attributes(server), string(AttributeRequestID), "span without request ID should not have one")
+	}
+}
+
+func TestTracing_StreamInterceptors(t *testing.T) {
+	tr, recorder := newRecorded(t)
+	clientOpts := []grpc.DialOption{grpc.WithStreamInterceptor(tr.StreamClientInterceptor())}
+	serverOpts := []grpc.ServerOption{grpc.StreamInterceptor(tr.StreamInterceptor())}
+
+	utils.TestCallFooS(t, &dummyTracing{}, clientOpts, serverOpts)
+	spans := recorder.Ended()
+	server, client := spanOfKind(spans, trace.SpanKindServer), spanOfKind(spans, trace.SpanKindClient)
+	if assert.NotNil(t, server, "a server span should be created") && assert.NotNil(t, client, "a client span should be created") {
+		assert.Equal(t, client.SpanContext().SpanID(), server.Parent().SpanID(), "server span should be a child of the client span")
+		for _, s := range []sdktrace.ReadOnlySpan{server, client} {
+			received, sent := 0, 0
+			for _, e := range s.Events() {
+				if e.Name != EventMessage {
+					continue
+				}
+				for _, a := range e.Attributes {
+					if a.Key == attributeMessageType && a.Value.AsString() == "RECEIVED" {
+						received++
+					} else if a.Key == attributeMessageType && a.Value.AsString() == "SENT" {
+						sent++
+					}
+				}
+			}
+			assert.Equal(t, 6, received, "%s span should have an event per received message", s.SpanKind())
+			assert.Equal(t, 6, sent, "%s span should have an event per sent message", s.SpanKind())
+		}
+	}
+}
+
+func TestWithRequestIDFromTraceID(t *testing.T) {
+	tr, recorder := newRecorded(t, WithRequestIDFromTraceID())
+	assert.True(t, tr.RequestIDFromTraceID(), "WithRequestIDFromTraceID() should use trace IDs as request IDs")
+	serverOpts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(tr.UnaryInterceptor(), requestid.UnaryServerInterceptor)}
+
+	impl := &dummyTracing{}
+	_, header, _, _ := utils.TestCallFoo(t, impl, nil, serverOpts)
+	if assert.Equal(t, 1, len(recorder.Ended()), "a server span should be created") {
+		traceID := recorder.Ended()[0].SpanContext().TraceID().String()
+		assert.Equal(t, traceID, impl.requestID, "request ID should be the trace ID")
+		assert.Equal(t, traceID, requestid.GetFromMeta(header), "request ID header should be the trace ID")
+		assert.Equal(t, traceID, attributes(recorder.Ended()[0])[string(AttributeRequestID)], "span should have the request ID")
+	}
+
+	impl = &dummyTracing{}
+	utils.TestCallFoo(t, impl, nil, serverOpts, requestid.AppendToOutgoingContext(context.Background(), "I'm a unique ID"))
+	assert.Equal(t, "I'm a unique ID", impl.requestID, "request ID sent by the client should be kept")
+
+	// Client
+	impl = &dummyTracing{}
+	clientOpts := []grpc.DialOption{grpc.WithUnaryInterceptor(tr.UnaryClientInterceptor())}
+	utils.TestCallFoo(t, impl, clientOpts, []grpc.ServerOption{grpc.UnaryInterceptor(requestid.UnaryServerInterceptor)})
+	client := spanOfKind(recorder.Ended(), trace.SpanKindClient)
+	if assert.NotNil(t, client, "a client span should be created") {
+		assert.Equal(t, client.SpanContext().TraceID().String(), impl.requestID, "client should send the trace ID as request ID")
+	}
+}
+
+func Test_metadataCarrier(t *testing.T) {
+	md := metadata.Pairs("a", "1", "a", "2")
+	c := metadataCarrier(md)
+	assert.Equal(t, "1", c.Get("a"), "Get() should return the first value")
+	assert.Equal(t, "", c.Get("b"), "Get() should return an empty string for missing keys")
+	c.Set("B", "3")
+	assert.Equal(t, []string{"3"}, md.Get("b"), "Set() should set the value")
+	assert.ElementsMatch(t, []string{"a", "b"}, c.Keys(), "Keys() should return the keys")
+}
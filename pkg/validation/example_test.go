@@ -0,0 +1,22 @@
+package validation_test
+
+import (
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/validation"
+)
+
+// ExampleNew rejects calls which request is invalid, according to its protoc-gen-validate methods
+// or (grpcutils.rules) field options
+func ExampleNew() {
+	v, err := validation.New()
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(v.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(v.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
@@ -0,0 +1,257 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/jucrouzet/grpcutils/pkg/annotations"
+)
+
+// fieldRules are the compiled (grpcutils.rules) of a field
+type fieldRules struct {
+	fd       protoreflect.FieldDescriptor
+	required bool
+	minLen   uint64
+	maxLen   uint64
+	pattern  *regexp.Regexp
+	in       map[string]struct{}
+	gte      *float64
+	lte      *float64
+}
+
+// messageRules are the compiled rules of a message
+type messageRules struct {
+	fields []*fieldRules
+	// nested are the message, repeated message and map of message fields, which values are
+	// validated recursively
+	nested []protoreflect.FieldDescriptor
+}
+
+// rulesCache holds the compiled rules of messages, by full name
+type rulesCache struct {
+	// mu is only write locked to compile rules, which are then read concurrently by calls
+	mu       sync.RWMutex
+	messages map[protoreflect.FullName]*messageRules
+}
+
+// get returns the compiled rules of a message, compiling them on first use.
+func (c *rulesCache) get(md protoreflect.MessageDescriptor) (*messageRules, error) {
+	c.mu.RLock()
+	rules, ok := c.messages[md.FullName()]
+	c.mu.RUnlock()
+	if ok {
+		return rules, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.compile(md)
+}
+
+func (c *rulesCache) compile(md protoreflect.MessageDescriptor) (*messageRules, error) {
+	if rules, ok := c.messages[md.FullName()]; ok {
+		return rules, nil
+	}
+	rules := &messageRules{}
+	// registered before compiling fields so that recursive messages are compiled once
+	c.messages[md.FullName()] = rules
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if r := annotations.GetRules(fd); r != nil {
+			compiled, err := compileField(fd, r)
+			if err != nil {
+				delete(c.messages, md.FullName())
+				return nil, err
+			}
+			rules.fields = append(rules.fields, compiled)
+		}
+		nested := fd.Message()
+		if fd.IsMap() {
+			nested = fd.MapValue().Message()
+		}
+		if nested != nil {
+			if _, err := c.compile(nested); err != nil {
+				delete(c.messages, md.FullName())
+				return nil, err
+			}
+			rules.nested = append(rules.nested, fd)
+		}
+	}
+	return rules, nil
+}
+
+// compileField compiles the rules of a field, returning an error if they don't apply to its type.
+func compileField(fd protoreflect.FieldDescriptor, r *annotations.FieldRules) (*fieldRules, error) {
+	rules := &fieldRules{fd: fd, required: r.GetRequired(), minLen: r.GetMinLen(), maxLen: r.GetMaxLen()}
+	isString := fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap()
+	if (rules.minLen > 0 || rules.maxLen > 0) && !isString && !fd.IsList() && !fd.IsMap() && fd.Kind() != protoreflect.BytesKind {
+		return nil, fmt.Errorf("min_len and max_len rules of %s only apply to string, bytes, repeated and map fields", fd.FullName())
+	}
+	if r.GetPattern() != "" {
+		if !isString {
+			return nil, fmt.Errorf("pattern rule of %s only applies to string fields", fd.FullName())
+		}
+		pattern, err := regexp.Compile(r.GetPattern())
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern rule of %s : %w", fd.FullName(), err)
+		}
+		rules.pattern = pattern
+	}
+	if len(r.GetIn()) > 0 {
+		if !isString {
+			return nil, fmt.Errorf("in rule of %s only applies to string fields", fd.FullName())
+		}
+		rules.in = make(map[string]struct{}, len(r.GetIn()))
+		for _, v := range r.GetIn() {
+			rules.in[v] = struct{}{}
+		}
+	}
+	if r.Gte != nil || r.Lte != nil {
+		if fd.IsList() || fd.IsMap() || !isNumeric(fd.Kind()) {
+			return nil, fmt.Errorf("gte and lte rules of %s only apply to numeric and enum fields", fd.FullName())
+		}
+		rules.gte, rules.lte = r.Gte, r.Lte
+	}
+	return rules, nil
+}
+
+func isNumeric(kind protoreflect.Kind) bool {
+	switch kind {
+	case protoreflect.BoolKind, protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
+	default:
+		return true
+	}
+}
+
+// violations accumulates the field violations of a message
+type violations struct {
+	list     []*errdetails.BadRequest_FieldViolation
+	failFast bool
+}
+
+func (v *violations) add(field, description string) {
+	v.list = append(v.list, &errdetails.BadRequest_FieldViolation{Field: field, Description: description})
+}
+
+func (v *violations) done() bool {
+	return v.failFast && len(v.list) > 0
+}
+
+// validateMessage checks the rules of m and its nested messages, field paths being prefixed with
+// path.
+func (c *rulesCache) validateMessage(m protoreflect.Message, path string, v *violations) error {
+	rules, err := c.get(m.Descriptor())
+	if err != nil {
+		return err
+	}
+	for _, r := range rules.fields {
+		r.validate(m, fieldPath(path, r.fd), v)
+		if v.done() {
+			return nil
+		}
+	}
+	for _, fd := range rules.nested {
+		if !m.Has(fd) {
+			continue
+		}
+		p := fieldPath(path, fd)
+		switch {
+		case fd.IsMap():
+			m.Get(fd).Map().Range(func(k protoreflect.MapKey, value protoreflect.Value) bool {
+				err = c.validateMessage(value.Message(), p+"["+strconv.Quote(k.String())+"]", v)
+				return err == nil && !v.done()
+			})
+		case fd.IsList():
+			list := m.Get(fd).List()
+			for i := 0; i < list.Len() && err == nil && !v.done(); i++ {
+				err = c.validateMessage(list.Get(i).Message(), p+"["+strconv.Itoa(i)+"]", v)
+			}
+		default:
+			err = c.validateMessage(m.Get(fd).Message(), p, v)
+		}
+		if err != nil || v.done() {
+			return err
+		}
+	}
+	return nil
+}
+
+func fieldPath(path string, fd protoreflect.FieldDescriptor) string {
+	if path == "" {
+		return string(fd.Name())
+	}
+	return path + "." + string(fd.Name())
+}
+
+// validate checks the rules of a field of m.
+// Rules other than required apply to unset scalar fields with their zero value, except for fields
+// with explicit presence, like proto3 optional fields.
+func (r *fieldRules) validate(m protoreflect.Message, path string, v *violations) {
+	if !m.Has(r.fd) {
+		if r.required {
+			v.add(path, "is required")
+			return
+		}
+		if r.fd.HasPresence() {
+			return
+		}
+	}
+	value := m.Get(r.fd)
+	switch {
+	case r.fd.IsList():
+		r.validateLen(uint64(value.List().Len()), path, "elements", v)
+	case r.fd.IsMap():
+		r.validateLen(uint64(value.Map().Len()), path, "elements", v)
+	case r.fd.Kind() == protoreflect.StringKind:
+		s := value.String()
+		r.validateLen(uint64(utf8.RuneCountInString(s)), path, "characters", v)
+		if r.pattern != nil && !r.pattern.MatchString(s) {
+			v.add(path, fmt.Sprintf("must match the pattern %q", r.pattern.String()))
+		}
+		if r.in != nil {
+			if _, ok := r.in[s]; !ok {
+				v.add(path, "is not an allowed value")
+			}
+		}
+	case r.fd.Kind() == protoreflect.BytesKind:
+		r.validateLen(uint64(len(value.Bytes())), path, "bytes", v)
+	case r.gte != nil || r.lte != nil:
+		n := numericValue(r.fd.Kind(), value)
+		if r.gte != nil && n < *r.gte {
+			v.add(path, "must be greater than or equal to "+strconv.FormatFloat(*r.gte, 'g', -1, 64))
+		}
+		if r.lte != nil && n > *r.lte {
+			v.add(path, "must be less than or equal to "+strconv.FormatFloat(*r.lte, 'g', -1, 64))
+		}
+	}
+}
+
+func (r *fieldRules) validateLen(n uint64, path, unit string, v *violations) {
+	if n < r.minLen {
+		v.add(path, fmt.Sprintf("must have at least %d %s", r.minLen, unit))
+	}
+	if r.maxLen > 0 && n > r.maxLen {
+		v.add(path, fmt.Sprintf("must have at most %d %s", r.maxLen, unit))
+	}
+}
+
+func numericValue(kind protoreflect.Kind, value protoreflect.Value) float64 {
+	switch kind {
+	case protoreflect.EnumKind:
+		return float64(value.Enum())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return float64(value.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return float64(value.Uint())
+	default:
+		return value.Float()
+	}
+}
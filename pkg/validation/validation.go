@@ -0,0 +1,189 @@
+// Package validation validates the requests of gRPC server calls, with their protoc-gen-validate
+// generated methods or the (grpcutils.rules) field options, rejecting invalid ones with a
+// codes.InvalidArgument error and errdetails.BadRequest details.
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+)
+
+// ErrInvalidOptionValue is returned when trying to use an invalid option value
+var ErrInvalidOptionValue = errors.New("invalid option value")
+
+// Validator is implemented by messages with protoc-gen-validate generated methods, returning the
+// first violation.
+type Validator interface {
+	Validate() error
+}
+
+// AllValidator is implemented by messages with protoc-gen-validate generated methods, returning all
+// violations.
+type AllValidator interface {
+	ValidateAll() error
+}
+
+// fieldError is implemented by protoc-gen-validate violation errors
+type fieldError interface {
+	Field() string
+	Reason() string
+}
+
+// multiError is implemented by protoc-gen-validate errors holding all violations
+type multiError interface {
+	AllErrors() []error
+}
+
+// causer is implemented by protoc-gen-validate violation errors of nested messages
+type causer interface {
+	Cause() error
+}
+
+// Validation validates requests
+type Validation struct {
+	failFast bool
+	rules    *rulesCache
+}
+
+// Option is the Validation option functions type
+type Option func(*Validation) error
+
+// WithFailFast stops validating requests at their first violation, using Validate() rather than
+// ValidateAll() for messages implementing both.
+func WithFailFast() Option {
+	return func(v *Validation) error {
+		v.failFast = true
+		return nil
+	}
+}
+
+// New creates a new instance of Validation with specified options
+func New(opts ...Option) (*Validation, error) {
+	v := &Validation{
+		rules: &rulesCache{messages: make(map[protoreflect.FullName]*messageRules)},
+	}
+	for _, opt := range opts {
+		if err := opt(v); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	return v, nil
+}
+
+// Validate validates a message, returning nil if it is valid, a codes.InvalidArgument error with
+// errdetails.BadRequest details if it is not, or a codes.Internal error if its rules are invalid.
+// Messages implementing AllValidator or Validator are validated with them, other protobuf messages
+// with their (grpcutils.rules) field options. Other values are considered valid.
+func (v *Validation) Validate(m interface{}) error {
+	allValidator, isAllValidator := m.(AllValidator)
+	validator, isValidator := m.(Validator)
+	switch {
+	case isAllValidator && (!v.failFast || !isValidator):
+		return fromValidateError(allValidator.ValidateAll())
+	case isValidator:
+		return fromValidateError(validator.Validate())
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil
+	}
+	violations := &violations{failFast: v.failFast}
+	if err := v.rules.validateMessage(msg.ProtoReflect(), "", violations); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return invalidArgument(violations.list)
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that rejects calls with an invalid
+// request.
+func (v *Validation) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if err := v.Validate(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns a gRPC server stream interceptor that validates each received message,
+// RecvMsg returning the validation error of invalid ones.
+func (v *Validation) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		_ *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, &streamutil.ServerStream{
+			ServerStream: stream,
+			RecvMsgHook: func(m interface{}, next streamutil.MsgHandler) error {
+				if err := next(m); err != nil {
+					return err
+				}
+				return v.Validate(m)
+			},
+		})
+	}
+}
+
+// fromValidateError converts the error returned by protoc-gen-validate methods to a
+// codes.InvalidArgument error with errdetails.BadRequest details.
+func fromValidateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	if multi, ok := err.(multiError); ok {
+		errs = multi.AllErrors()
+	}
+	var list []*errdetails.BadRequest_FieldViolation
+	for _, e := range errs {
+		list = append(list, toFieldViolation(e))
+	}
+	return invalidArgument(list)
+}
+
+// toFieldViolation converts a protoc-gen-validate violation error to a field violation, with the
+// path of the field in nested messages.
+func toFieldViolation(err error) *errdetails.BadRequest_FieldViolation {
+	fe, ok := err.(fieldError)
+	if !ok {
+		return &errdetails.BadRequest_FieldViolation{Description: err.Error()}
+	}
+	violation := &errdetails.BadRequest_FieldViolation{Field: fe.Field(), Description: fe.Reason()}
+	if c, ok := err.(causer); ok && c.Cause() != nil {
+		if nested := toFieldViolation(c.Cause()); nested.Field != "" {
+			violation.Field += "." + nested.Field
+			violation.Description = nested.Description
+		}
+	}
+	return violation
+}
+
+// invalidArgument returns a codes.InvalidArgument error with the violations in
+// errdetails.BadRequest details, or nil if there are none.
+func invalidArgument(list []*errdetails.BadRequest_FieldViolation) error {
+	if len(list) == 0 {
+		return nil
+	}
+	st, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(&errdetails.BadRequest{FieldViolations: list})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid request")
+	}
+	return st.Err()
+}
@@ -0,0 +1,242 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/annotations"
+)
+
+func validOrder() *foobar.Order {
+	return &foobar.Order{
+		Id:       "42",
+		Email:    "john@example.com",
+		Quantity: 2,
+		Items:    []*foobar.Item{{Sku: "ABC-123"}},
+		Currency: "EUR",
+		Address:  &foobar.Address{City: "Paris"},
+	}
+}
+
+// violationsOf returns the field violations of a validation error, by field
+func violationsOf(t *testing.T, err error) map[string]string {
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code(), "validation error should be an invalid argument error")
+	violations := make(map[string]string)
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				violations[v.GetField()] = v.GetDescription()
+			}
+		}
+	}
+	return violations
+}
+
+func TestValidation_Validate(t *testing.T) {
+	v, _ := New()
+	assert.Nil(t, v.Validate(validOrder()), "valid message should be valid")
+	assert.Nil(t, v.Validate(&foobar.Empty{}), "message without rules should be valid")
+	assert.Nil(t, v.Validate("not a message"), "non protobuf values should be valid")
+
+	order := &foobar.Order{
+		Email:    "john",
+		Quantity: 101,
+		Items:    []*foobar.Item{{Sku: "ABC-123"}, {Sku: "A"}, {Sku: "ABC"}, {Sku: "ABC"}},
+		Currency: "GBP",
+		ItemsByLabel: map[string]*foobar.Item{
+			"gift": {Sku: "TOO-LONG-SKU"},
+		},
+	}
+	violations := violationsOf(t, v.Validate(order))
+	assert.Equal(t, map[string]string{
+		"id":                         "is required",
+		"email":                      `must match the pattern "^[^@]+@[^@]+$"`,
+		"quantity":                   "must be less than or equal to 100",
+		"items":                      "must have at most 3 elements",
+		"currency":                   "is not an allowed value",
+		"address":                    "is required",
+		"items[1].sku":               "must have at least 3 characters",
+		`items_by_label["gift"].sku`: "must have at most 8 characters",
+	}, violations, "all violations should be returned with their field path")
+
+	order = validOrder()
+	order.Quantity = 0
+	order.Items = nil
+	violations = violationsOf(t, v.Validate(order))
+	assert.Equal(t, map[string]string{
+		"quantity": "must be greater than or equal to 1",
+		"items":    "must have at least 1 elements",
+	}, violations, "rules should apply to unset fields")
+
+	v, _ = New(WithFailFast())
+	violations = violationsOf(t, v.Validate(&foobar.Order{}))
+	assert.Equal(t, map[string]string{"id": "is required"}, violations, "WithFailFast() should return the first violation")
+}
+
+func TestValidation_Validate_concurrent(t *testing.T) {
+	v, _ := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.Nil(t, v.Validate(validOrder()), "valid message should be valid when validated concurrently")
+				assert.NotNil(t, v.Validate(&foobar.Order{}), "invalid message should be invalid when validated concurrently")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestValidation_Validate_invalidRules(t *testing.T) {
+	opts := &descriptorpb.FieldOptions{}
+	proto.SetExtension(opts, annotations.E_Rules, &annotations.FieldRules{Pattern: "^[a-z]+$"})
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("invalid_rules.proto"),
+		Package: proto.String("validation"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Invalid"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("count"),
+				Number:   proto.Int32(1),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				JsonName: proto.String("count"),
+				Options:  opts,
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := New()
+	err = v.Validate(dynamicpb.NewMessage(fd.Messages().Get(0)))
+	assert.Equal(t, codes.Internal, status.Code(err), "message with invalid rules should return an internal error")
+	assert.True(t, strings.Contains(status.Convert(err).Message(), "pattern rule of validation.Invalid.count"), "error should describe the invalid rule")
+}
+
+// pgvError mimics protoc-gen-validate violation errors
+type pgvError struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e pgvError) Field() string  { return e.field }
+func (e pgvError) Reason() string { return e.reason }
+func (e pgvError) Cause() error   { return e.cause }
+func (e pgvError) Error() string  { return "invalid " + e.field + " : " + e.reason }
+
+// pgvMultiError mimics protoc-gen-validate errors holding all violations
+type pgvMultiError []error
+
+func (m pgvMultiError) Error() string      { return "multiple errors" }
+func (m pgvMultiError) AllErrors() []error { return m }
+
+// pgvMessage mimics messages with protoc-gen-validate generated methods
+type pgvMessage struct {
+	*foobar.Empty
+	errs []error
+}
+
+func (m *pgvMessage) Validate() error {
+	if len(m.errs) == 0 {
+		return nil
+	}
+	return m.errs[0]
+}
+
+func (m *pgvMessage) ValidateAll() error {
+	if len(m.errs) == 0 {
+		return nil
+	}
+	return pgvMultiError(m.errs)
+}
+
+func TestValidation_Validate_pgv(t *testing.T) {
+	msg := &pgvMessage{Empty: &foobar.Empty{}, errs: []error{
+		pgvError{field: "Name", reason: "value length must be at least 1 runes"},
+		pgvError{field: "Address", reason: "embedded message failed validation", cause: pgvError{field: "City", reason: "value is required"}},
+		errors.New("custom error"),
+	}}
+	v, _ := New()
+	violations := violationsOf(t, v.Validate(msg))
+	assert.Equal(t, map[string]string{
+		"Name":         "value length must be at least 1 runes",
+		"Address.City": "value is required",
+		"":             "custom error",
+	}, violations, "ValidateAll() violations should be returned")
+
+	v, _ = New(WithFailFast())
+	violations = violationsOf(t, v.Validate(msg))
+	assert.Equal(t, map[string]string{"Name": "value length must be at least 1 runes"}, violations, "WithFailFast() should use Validate()")
+
+	assert.Nil(t, v.Validate(&pgvMessage{Empty: &foobar.Empty{}}), "valid message should be valid")
+}
+
+func TestValidation_UnaryInterceptor(t *testing.T) {
+	v, _ := New()
+	called := false
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		called = true
+		return &foobar.Empty{}, nil
+	}
+	_, err := v.UnaryInterceptor()(context.Background(), &foobar.Order{}, &grpc.UnaryServerInfo{}, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "invalid request should be rejected")
+	assert.False(t, called, "handler should not be called with an invalid request")
+
+	_, err = v.UnaryInterceptor()(context.Background(), validOrder(), &grpc.UnaryServerInfo{}, handler)
+	assert.Nil(t, err, "valid request should not be rejected")
+	assert.True(t, called, "handler should be called with a valid request")
+}
+
+// fakeServerStream receives messages
+type fakeServerStream struct {
+	grpc.ServerStream
+	messages []proto.Message
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *fakeServerStream) RecvMsg(m interface{}) error {
+	proto.Merge(m.(proto.Message), s.messages[0])
+	s.messages = s.messages[1:]
+	return nil
+}
+
+func TestValidation_StreamInterceptor(t *testing.T) {
+	v, _ := New()
+	stream := &fakeServerStream{messages: []proto.Message{validOrder(), &foobar.Order{}}}
+	var errs []error
+	err := v.StreamInterceptor()(nil, stream, &grpc.StreamServerInfo{}, func(_ interface{}, s grpc.ServerStream) error {
+		assert.NotNil(t, s.Context(), "stream should have a context")
+		for i := 0; i < 2; i++ {
+			errs = append(errs, s.RecvMsg(&foobar.Order{}))
+		}
+		return nil
+	})
+	assert.Nil(t, err, "stream should not fail")
+	assert.Nil(t, errs[0], "valid message should be received")
+	assert.Equal(t, codes.InvalidArgument, status.Code(errs[1]), "invalid message should return a validation error")
+}
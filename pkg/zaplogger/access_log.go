@@ -0,0 +1,220 @@
+package zaplogger
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/methodpattern"
+	"github.com/jucrouzet/grpcutils/pkg/remoteaddr"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+)
+
+const (
+	// AccessLogFieldCode is the access log field holding the call status code
+	AccessLogFieldCode = "code"
+	// AccessLogFieldDuration is the access log field holding the call duration
+	AccessLogFieldDuration = "duration"
+	// AccessLogFieldRequestSize is the access log field holding the size, in bytes, of the request
+	// (or of all the messages received for streams)
+	AccessLogFieldRequestSize = "request_size"
+	// AccessLogFieldResponseSize is the access log field holding the size, in bytes, of the response
+	// (or of all the messages sent for streams)
+	AccessLogFieldResponseSize = "response_size"
+	// AccessLogFieldMessagesReceived is the access log field holding the number of messages received
+	// on a stream
+	AccessLogFieldMessagesReceived = "messages_received"
+	// AccessLogFieldMessagesSent is the access log field holding the number of messages sent on a
+	// stream
+	AccessLogFieldMessagesSent = "messages_sent"
+)
+
+// DefaultAccessLogLevels is the default mapping of calls status codes to access log levels.
+var DefaultAccessLogLevels = map[codes.Code]zapcore.Level{
+	codes.OK:                 zapcore.InfoLevel,
+	codes.Canceled:           zapcore.InfoLevel,
+	codes.InvalidArgument:    zapcore.InfoLevel,
+	codes.NotFound:           zapcore.InfoLevel,
+	codes.AlreadyExists:      zapcore.InfoLevel,
+	codes.Unauthenticated:    zapcore.InfoLevel,
+	codes.DeadlineExceeded:   zapcore.WarnLevel,
+	codes.PermissionDenied:   zapcore.WarnLevel,
+	codes.ResourceExhausted:  zapcore.WarnLevel,
+	codes.FailedPrecondition: zapcore.WarnLevel,
+	codes.Aborted:            zapcore.WarnLevel,
+	codes.OutOfRange:         zapcore.WarnLevel,
+	codes.Unavailable:        zapcore.WarnLevel,
+	codes.Unknown:            zapcore.ErrorLevel,
+	codes.Unimplemented:      zapcore.ErrorLevel,
+	codes.Internal:           zapcore.ErrorLevel,
+	codes.DataLoss:           zapcore.ErrorLevel,
+}
+
+type accessLog struct {
+	levels   map[codes.Code]zapcore.Level
+	sampling map[string]*sampler
+}
+
+type sampler struct {
+	every uint64
+	count uint64
+}
+
+// WithAccessLog makes the interceptors log one message per finished unary call or stream, with
+// its method, status code, duration, sizes, message counts for streams, remote address and
+// request correlation identifier.
+// Message level depends on the call status code, see DefaultAccessLogLevels and
+// WithAccessLogLevels.
+func WithAccessLog() Option {
+	return func(l *Logger) error {
+		l.enableAccessLog()
+		return nil
+	}
+}
+
+// WithAccessLogLevels overrides the access log levels of some status codes, the others using
+// DefaultAccessLogLevels.
+// It enables access log.
+func WithAccessLogLevels(levels map[codes.Code]zapcore.Level) Option {
+	return func(l *Logger) error {
+		l.enableAccessLog()
+		for code, level := range levels {
+			l.accessLog.levels[code] = level
+		}
+		return nil
+	}
+}
+
+// WithAccessLogSampling only logs one of every `every` successful calls of the methods matching
+// `method`, like "/package.Service/Method" or "/package.Service/*".
+// Failed calls are always logged.
+// It enables access log.
+func WithAccessLogSampling(method string, every int) Option {
+	return func(l *Logger) error {
+		if err := methodpattern.Validate(method); err != nil {
+			return err
+		}
+		if every < 1 {
+			return errors.New("sampling must be at least 1")
+		}
+		l.enableAccessLog()
+		l.accessLog.sampling[method] = &sampler{every: uint64(every)}
+		return nil
+	}
+}
+
+func (l *Logger) enableAccessLog() {
+	if l.accessLog != nil {
+		return
+	}
+	l.accessLog = &accessLog{
+		levels:   make(map[codes.Code]zapcore.Level, len(DefaultAccessLogLevels)),
+		sampling: make(map[string]*sampler),
+	}
+	for code, level := range DefaultAccessLogLevels {
+		l.accessLog.levels[code] = level
+	}
+}
+
+func (l *Logger) logUnaryAccess(
+	ctx context.Context,
+	logger *zap.Logger,
+	fullMethod string,
+	start time.Time,
+	req, res interface{},
+	err error,
+) {
+	l.logAccess(ctx, logger, fullMethod, "finished unary call", err,
+		zap.Duration(AccessLogFieldDuration, time.Since(start)),
+		zap.Int(AccessLogFieldRequestSize, messageSize(req)),
+		zap.Int(AccessLogFieldResponseSize, messageSize(res)),
+	)
+}
+
+func (l *Logger) logStreamAccess(
+	ctx context.Context,
+	logger *zap.Logger,
+	fullMethod string,
+	start time.Time,
+	stream *streamutil.ServerStream,
+	err error,
+) {
+	l.logAccess(ctx, logger, fullMethod, "finished stream", err,
+		zap.Duration(AccessLogFieldDuration, time.Since(start)),
+		zap.Int64(AccessLogFieldRequestSize, stream.BytesReceived()),
+		zap.Int64(AccessLogFieldResponseSize, stream.BytesSent()),
+		zap.Int64(AccessLogFieldMessagesReceived, stream.MessagesReceived()),
+		zap.Int64(AccessLogFieldMessagesSent, stream.MessagesSent()),
+	)
+}
+
+func (l *Logger) logAccess(
+	ctx context.Context,
+	logger *zap.Logger,
+	fullMethod string,
+	msg string,
+	err error,
+	fields ...zap.Field,
+) {
+	code := status.Code(err)
+	if code == codes.OK && !l.accessLog.sample(fullMethod) {
+		return
+	}
+	level, ok := l.accessLog.levels[code]
+	if !ok {
+		level = zapcore.ErrorLevel
+	}
+	ce := logger.Check(level, msg)
+	if ce == nil {
+		return
+	}
+	fields = append(fields, zap.String(AccessLogFieldCode, code.String()))
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	ce.Write(append(fields, l.callFields(ctx, fullMethod)...)...)
+}
+
+// callFields returns the method, remote address and request correlation identifier fields of a
+// call which are not already in the request logger.
+func (l *Logger) callFields(ctx context.Context, fullMethod string) []zap.Field {
+	var fields []zap.Field
+	if !l.plan.method {
+		fields = append(fields, zap.String(FieldMethod, fullMethod))
+	}
+	if !l.plan.remoteAddr {
+		if addr, err := remoteaddr.GetFromContext(ctx); err == nil {
+			fields = append(fields, zap.String(FieldRemoteAddr, addr.String()))
+		}
+	}
+	if id := requestid.GetFromContext(ctx); id != "" && !l.plan.requestID {
+		fields = append(fields, zap.String(FieldRequestID, id))
+	}
+	// Capacity is limited so that appending to the returned fields, possibly concurrently, copies them
+	return fields[:len(fields):len(fields)]
+}
+
+// sample returns whether a successful call to fullMethod should be logged.
+func (a *accessLog) sample(fullMethod string) bool {
+	_, s, ok := methodpattern.Lookup(a.sampling, fullMethod)
+	if !ok {
+		return true
+	}
+	return (atomic.AddUint64(&s.count, 1)-1)%s.every == 0
+}
+
+func messageSize(m interface{}) int {
+	msg, ok := m.(proto.Message)
+	if !ok || msg == nil {
+		return 0
+	}
+	return proto.Size(msg)
+}
@@ -0,0 +1,158 @@
+package zaplogger
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+)
+
+type dummyAccessLog struct {
+	foobar.UnimplementedDummyServiceServer
+	err error
+}
+
+func (d *dummyAccessLog) Foo(_ context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &foobar.Empty{}, nil
+}
+
+func (d *dummyAccessLog) FooS(s foobar.DummyService_FooSServer) error {
+	for {
+		_, err := s.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Send(&foobar.Empty{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestWithAccessLog(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, err := New(WithLogger(zap.New(core)), WithAccessLog())
+	assert.Nil(t, err, "WithAccessLog() should not return an error")
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor, l.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor, l.StreamInterceptor()),
+	}
+	ctx := requestid.AppendToOutgoingContext(context.Background(), "I'm a unique ID")
+
+	utils.TestCallFoo(t, &dummyAccessLog{}, nil, opts, ctx)
+	if assert.Equal(t, 1, len(recordedLogs.All()), "access log should log finished unary calls") {
+		entry := recordedLogs.TakeAll()[0]
+		fields := entry.ContextMap()
+		assert.Equal(t, zapcore.InfoLevel, entry.Level, "successful calls should be logged as info")
+		assert.Equal(t, "finished unary call", entry.Message, "unary calls should be logged as unary")
+		assert.Equal(t, "OK", fields[AccessLogFieldCode], "access log should log status code")
+		assert.Equal(t, "/foobar.DummyService/Foo", fields[FieldMethod], "access log should log method")
+		assert.Equal(t, "bufconn", fields[FieldRemoteAddr], "access log should log remote address")
+		assert.Equal(t, "I'm a unique ID", fields[FieldRequestID], "access log should log request ID")
+		assert.Contains(t, fields, AccessLogFieldDuration, "access log should log duration")
+		assert.Equal(t, int64(0), fields[AccessLogFieldRequestSize], "access log should log request size")
+		assert.Equal(t, int64(0), fields[AccessLogFieldResponseSize], "access log should log response size")
+	}
+
+	utils.TestCallFooS(t, &dummyAccessLog{}, nil, opts, ctx)
+	if assert.Equal(t, 1, len(recordedLogs.All()), "access log should log finished streams") {
+		entry := recordedLogs.TakeAll()[0]
+		fields := entry.ContextMap()
+		assert.Equal(t, "finished stream", entry.Message, "streams should be logged as streams")
+		assert.Equal(t, "OK", fields[AccessLogFieldCode], "access log should log status code")
+		assert.Equal(t, "/foobar.DummyService/FooS", fields[FieldMethod], "access log should log method")
+		assert.Equal(t, "I'm a unique ID", fields[FieldRequestID], "access log should log request ID")
+		assert.Equal(t, int64(6), fields[AccessLogFieldMessagesReceived], "access log should log received messages count")
+		assert.Equal(t, int64(2), fields[AccessLogFieldMessagesSent], "access log should log sent messages count")
+	}
+
+	utils.TestCallFoo(t, &dummyAccessLog{err: status.Error(codes.Internal, "boom")}, nil, opts, ctx)
+	if assert.Equal(t, 1, len(recordedLogs.All()), "access log should log failed unary calls") {
+		entry := recordedLogs.TakeAll()[0]
+		assert.Equal(t, zapcore.ErrorLevel, entry.Level, "internal errors should be logged as errors")
+		assert.Equal(t, "Internal", entry.ContextMap()[AccessLogFieldCode], "access log should log status code")
+	}
+
+	// Fields already in request logger should not be duplicated
+	core, recordedLogs = observer.New(zapcore.DebugLevel)
+	l, _ = New(WithLogger(zap.New(core)), WithAccessLog(), WithFields(FieldMethod))
+	utils.TestCallFoo(t, &dummyAccessLog{}, nil, []grpc.ServerOption{grpc.UnaryInterceptor(l.UnaryInterceptor())})
+	if assert.Equal(t, 1, len(recordedLogs.All()), "access log should log finished unary calls") {
+		count := 0
+		for _, f := range recordedLogs.All()[0].Context {
+			if f.Key == FieldMethod {
+				count++
+			}
+		}
+		assert.Equal(t, 1, count, "access log should not duplicate request logger fields")
+	}
+}
+
+func TestWithAccessLogLevels(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(
+		WithLogger(zap.New(core)),
+		WithAccessLogLevels(map[codes.Code]zapcore.Level{
+			codes.OK:       zapcore.DebugLevel,
+			codes.NotFound: zapcore.WarnLevel,
+		}),
+	)
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(l.UnaryInterceptor())}
+
+	check := func(err error, expected zapcore.Level) {
+		utils.TestCallFoo(t, &dummyAccessLog{err: err}, nil, opts)
+		if assert.Equal(t, 1, len(recordedLogs.All()), "access log should log finished unary calls") {
+			assert.Equal(t, expected, recordedLogs.TakeAll()[0].Level, "access log should use configured level for %s", status.Code(err))
+		}
+	}
+	check(nil, zapcore.DebugLevel)
+	check(status.Error(codes.NotFound, "not found"), zapcore.WarnLevel)
+	check(status.Error(codes.Unavailable, "unavailable"), zapcore.WarnLevel)
+	check(status.Error(codes.Unimplemented, "unimplemented"), zapcore.ErrorLevel)
+
+	// Levels disabled in logger should not be logged
+	core, recordedLogs = observer.New(zapcore.InfoLevel)
+	l, _ = New(WithLogger(zap.New(core)), WithAccessLogLevels(map[codes.Code]zapcore.Level{codes.OK: zapcore.DebugLevel}))
+	utils.TestCallFoo(t, &dummyAccessLog{}, nil, []grpc.ServerOption{grpc.UnaryInterceptor(l.UnaryInterceptor())})
+	assert.Equal(t, 0, len(recordedLogs.All()), "access log should not log disabled levels")
+}
+
+func TestWithAccessLogSampling(t *testing.T) {
+	l, err := New(WithAccessLogSampling("Foo", 2))
+	assert.Nil(t, l, "WithAccessLogSampling() should not return a logger with an invalid method")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithAccessLogSampling() should return a ErrInvalidOptionValue error with an invalid method")
+	l, err = New(WithAccessLogSampling("/foobar.DummyService/Foo", 0))
+	assert.Nil(t, l, "WithAccessLogSampling() should not return a logger with an invalid sampling")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithAccessLogSampling() should return a ErrInvalidOptionValue error with an invalid sampling")
+
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ = New(WithLogger(zap.New(core)), WithAccessLogSampling("/foobar.DummyService/*", 3))
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(l.UnaryInterceptor())}
+	for i := 0; i < 5; i++ {
+		utils.TestCallFoo(t, &dummyAccessLog{}, nil, opts)
+	}
+	assert.Equal(t, 2, len(recordedLogs.TakeAll()), "access log should sample successful calls")
+	for i := 0; i < 5; i++ {
+		utils.TestCallFoo(t, &dummyAccessLog{err: status.Error(codes.Internal, "boom")}, nil, opts)
+	}
+	assert.Equal(t, 5, len(recordedLogs.TakeAll()), "access log should not sample failed calls")
+}
@@ -0,0 +1,340 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.0
+// 	protoc        v3.19.4
+// source: grpcutils/zaplogger/admin.proto
+
+package adminpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetLevelsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetLevelsRequest) Reset() {
+	*x = GetLevelsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_grpcutils_zaplogger_admin_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetLevelsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLevelsRequest) ProtoMessage() {}
+
+func (x *GetLevelsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcutils_zaplogger_admin_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLevelsRequest.ProtoReflect.Descriptor instead.
+func (*GetLevelsRequest) Descriptor() ([]byte, []int) {
+	return file_grpcutils_zaplogger_admin_proto_rawDescGZIP(), []int{0}
+}
+
+// Levels are log levels, like "debug" or "info"
+type Levels struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// level is the default log level
+	Level string `protobuf:"bytes,1,opt,name=level,proto3" json:"level,omitempty"`
+	// methods are the log levels by method pattern
+	Methods map[string]string `protobuf:"bytes,2,rep,name=methods,proto3" json:"methods,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// request_ids are the log levels by request correlation identifier
+	RequestIds map[string]string `protobuf:"bytes,3,rep,name=request_ids,json=requestIds,proto3" json:"request_ids,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *Levels) Reset() {
+	*x = Levels{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_grpcutils_zaplogger_admin_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Levels) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Levels) ProtoMessage() {}
+
+func (x *Levels) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcutils_zaplogger_admin_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Levels.ProtoReflect.Descriptor instead.
+func (*Levels) Descriptor() ([]byte, []int) {
+	return file_grpcutils_zaplogger_admin_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Levels) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *Levels) GetMethods() map[string]string {
+	if x != nil {
+		return x.Methods
+	}
+	return nil
+}
+
+func (x *Levels) GetRequestIds() map[string]string {
+	if x != nil {
+		return x.RequestIds
+	}
+	return nil
+}
+
+type SetLevelRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// method is the method pattern to set the log level of, like "/package.Service/*" or
+	// "/package.Service/Method"
+	Method string `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	// request_id is the request correlation identifier to set the log level of
+	RequestId string `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// level is the log level to set, an empty level removing the method or request ID level
+	Level string `protobuf:"bytes,3,opt,name=level,proto3" json:"level,omitempty"`
+}
+
+func (x *SetLevelRequest) Reset() {
+	*x = SetLevelRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_grpcutils_zaplogger_admin_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SetLevelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetLevelRequest) ProtoMessage() {}
+
+func (x *SetLevelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcutils_zaplogger_admin_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetLevelRequest.ProtoReflect.Descriptor instead.
+func (*SetLevelRequest) Descriptor() ([]byte, []int) {
+	return file_grpcutils_zaplogger_admin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SetLevelRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *SetLevelRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *SetLevelRequest) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+var File_grpcutils_zaplogger_admin_proto protoreflect.FileDescriptor
+
+var file_grpcutils_zaplogger_admin_proto_rawDesc = []byte{
+	0x0a, 0x1f, 0x67, 0x72, 0x70, 0x63, 0x75, 0x74, 0x69, 0x6c, 0x73, 0x2f, 0x7a, 0x61, 0x70, 0x6c,
+	0x6f, 0x67, 0x67, 0x65, 0x72, 0x2f, 0x61, 0x64, 0x6d, 0x69, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x13, 0x67, 0x72, 0x70, 0x63, 0x75, 0x74, 0x69, 0x6c, 0x73, 0x2e, 0x7a, 0x61, 0x70,
+	0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x22, 0x12, 0x0a, 0x10, 0x47, 0x65, 0x74, 0x4c, 0x65, 0x76,
+	0x65, 0x6c, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0xab, 0x02, 0x0a, 0x06, 0x4c,
+	0x65, 0x76, 0x65, 0x6c, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x12, 0x42, 0x0a, 0x07, 0x6d,
+	0x65, 0x74, 0x68, 0x6f, 0x64, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x67,
+	0x72, 0x70, 0x63, 0x75, 0x74, 0x69, 0x6c, 0x73, 0x2e, 0x7a, 0x61, 0x70, 0x6c, 0x6f, 0x67, 0x67,
+	0x65, 0x72, 0x2e, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x73, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64,
+	0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x73, 0x12,
+	0x4c, 0x0a, 0x0b, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x03,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x2b, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x75, 0x74, 0x69, 0x6c, 0x73,
+	0x2e, 0x7a, 0x61, 0x70, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x2e, 0x4c, 0x65, 0x76, 0x65, 0x6c,
+	0x73, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x52, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x73, 0x1a, 0x3a, 0x0a,
+	0x0c, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a,
+	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3d, 0x0a, 0x0f, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x5e, 0x0a, 0x0f, 0x53, 0x65, 0x74, 0x4c,
+	0x65, 0x76, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6d,
+	0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x65, 0x74,
+	0x68, 0x6f, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x69,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x32, 0xac, 0x01, 0x0a, 0x0a, 0x4c, 0x65, 0x76,
+	0x65, 0x6c, 0x41, 0x64, 0x6d, 0x69, 0x6e, 0x12, 0x4f, 0x0a, 0x09, 0x47, 0x65, 0x74, 0x4c, 0x65,
+	0x76, 0x65, 0x6c, 0x73, 0x12, 0x25, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x75, 0x74, 0x69, 0x6c, 0x73,
+	0x2e, 0x7a, 0x61, 0x70, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x4c, 0x65,
+	0x76, 0x65, 0x6c, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x67, 0x72,
+	0x70, 0x63, 0x75, 0x74, 0x69, 0x6c, 0x73, 0x2e, 0x7a, 0x61, 0x70, 0x6c, 0x6f, 0x67, 0x67, 0x65,
+	0x72, 0x2e, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x73, 0x12, 0x4d, 0x0a, 0x08, 0x53, 0x65, 0x74, 0x4c,
+	0x65, 0x76, 0x65, 0x6c, 0x12, 0x24, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x75, 0x74, 0x69, 0x6c, 0x73,
+	0x2e, 0x7a, 0x61, 0x70, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x2e, 0x53, 0x65, 0x74, 0x4c, 0x65,
+	0x76, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x67, 0x72, 0x70,
+	0x63, 0x75, 0x74, 0x69, 0x6c, 0x73, 0x2e, 0x7a, 0x61, 0x70, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72,
+	0x2e, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x73, 0x42, 0x36, 0x5a, 0x34, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x75, 0x63, 0x72, 0x6f, 0x75, 0x7a, 0x65, 0x74, 0x2f,
+	0x67, 0x72, 0x70, 0x63, 0x75, 0x74, 0x69, 0x6c, 0x73, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x7a, 0x61,
+	0x70, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x2f, 0x61, 0x64, 0x6d, 0x69, 0x6e, 0x70, 0x62, 0x62,
+	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_grpcutils_zaplogger_admin_proto_rawDescOnce sync.Once
+	file_grpcutils_zaplogger_admin_proto_rawDescData = file_grpcutils_zaplogger_admin_proto_rawDesc
+)
+
+func file_grpcutils_zaplogger_admin_proto_rawDescGZIP() []byte {
+	file_grpcutils_zaplogger_admin_proto_rawDescOnce.Do(func() {
+		file_grpcutils_zaplogger_admin_proto_rawDescData = protoimpl.X.CompressGZIP(file_grpcutils_zaplogger_admin_proto_rawDescData)
+	})
+	return file_grpcutils_zaplogger_admin_proto_rawDescData
+}
+
+var file_grpcutils_zaplogger_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_grpcutils_zaplogger_admin_proto_goTypes = []interface{}{
+	(*GetLevelsRequest)(nil), // 0: grpcutils.zaplogger.GetLevelsRequest
+	(*Levels)(nil),           // 1: grpcutils.zaplogger.Levels
+	(*SetLevelRequest)(nil),  // 2: grpcutils.zaplogger.SetLevelRequest
+	nil,                      // 3: grpcutils.zaplogger.Levels.MethodsEntry
+	nil,                      // 4: grpcutils.zaplogger.Levels.RequestIdsEntry
+}
+var file_grpcutils_zaplogger_admin_proto_depIdxs = []int32{
+	3, // 0: grpcutils.zaplogger.Levels.methods:type_name -> grpcutils.zaplogger.Levels.MethodsEntry
+	4, // 1: grpcutils.zaplogger.Levels.request_ids:type_name -> grpcutils.zaplogger.Levels.RequestIdsEntry
+	0, // 2: grpcutils.zaplogger.LevelAdmin.GetLevels:input_type -> grpcutils.zaplogger.GetLevelsRequest
+	2, // 3: grpcutils.zaplogger.LevelAdmin.SetLevel:input_type -> grpcutils.zaplogger.SetLevelRequest
+	1, // 4: grpcutils.zaplogger.LevelAdmin.GetLevels:output_type -> grpcutils.zaplogger.Levels
+	1, // 5: grpcutils.zaplogger.LevelAdmin.SetLevel:output_type -> grpcutils.zaplogger.Levels
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_grpcutils_zaplogger_admin_proto_init() }
+func file_grpcutils_zaplogger_admin_proto_init() {
+	if File_grpcutils_zaplogger_admin_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_grpcutils_zaplogger_admin_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetLevelsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_grpcutils_zaplogger_admin_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Levels); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_grpcutils_zaplogger_admin_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SetLevelRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_grpcutils_zaplogger_admin_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_grpcutils_zaplogger_admin_proto_goTypes,
+		DependencyIndexes: file_grpcutils_zaplogger_admin_proto_depIdxs,
+		MessageInfos:      file_grpcutils_zaplogger_admin_proto_msgTypes,
+	}.Build()
+	File_grpcutils_zaplogger_admin_proto = out.File
+	file_grpcutils_zaplogger_admin_proto_rawDesc = nil
+	file_grpcutils_zaplogger_admin_proto_goTypes = nil
+	file_grpcutils_zaplogger_admin_proto_depIdxs = nil
+}
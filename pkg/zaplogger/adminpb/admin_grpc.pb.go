@@ -0,0 +1,147 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.19.4
+// source: grpcutils/zaplogger/admin.proto
+
+package adminpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// LevelAdminClient is the client API for LevelAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LevelAdminClient interface {
+	// GetLevels returns the current log levels
+	GetLevels(ctx context.Context, in *GetLevelsRequest, opts ...grpc.CallOption) (*Levels, error)
+	// SetLevel changes the default log level, or the log level of a method or a request ID, and
+	// returns the new log levels
+	SetLevel(ctx context.Context, in *SetLevelRequest, opts ...grpc.CallOption) (*Levels, error)
+}
+
+type levelAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLevelAdminClient(cc grpc.ClientConnInterface) LevelAdminClient {
+	return &levelAdminClient{cc}
+}
+
+func (c *levelAdminClient) GetLevels(ctx context.Context, in *GetLevelsRequest, opts ...grpc.CallOption) (*Levels, error) {
+	out := new(Levels)
+	err := c.cc.Invoke(ctx, "/grpcutils.zaplogger.LevelAdmin/GetLevels", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *levelAdminClient) SetLevel(ctx context.Context, in *SetLevelRequest, opts ...grpc.CallOption) (*Levels, error) {
+	out := new(Levels)
+	err := c.cc.Invoke(ctx, "/grpcutils.zaplogger.LevelAdmin/SetLevel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LevelAdminServer is the server API for LevelAdmin service.
+// All implementations must embed UnimplementedLevelAdminServer
+// for forward compatibility
+type LevelAdminServer interface {
+	// GetLevels returns the current log levels
+	GetLevels(context.Context, *GetLevelsRequest) (*Levels, error)
+	// SetLevel changes the default log level, or the log level of a method or a request ID, and
+	// returns the new log levels
+	SetLevel(context.Context, *SetLevelRequest) (*Levels, error)
+	mustEmbedUnimplementedLevelAdminServer()
+}
+
+// UnimplementedLevelAdminServer must be embedded to have forward compatible implementations.
+type UnimplementedLevelAdminServer struct {
+}
+
+func (UnimplementedLevelAdminServer) GetLevels(context.Context, *GetLevelsRequest) (*Levels, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLevels not implemented")
+}
+func (UnimplementedLevelAdminServer) SetLevel(context.Context, *SetLevelRequest) (*Levels, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLevel not implemented")
+}
+func (UnimplementedLevelAdminServer) mustEmbedUnimplementedLevelAdminServer() {}
+
+// UnsafeLevelAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LevelAdminServer will
+// result in compilation errors.
+type UnsafeLevelAdminServer interface {
+	mustEmbedUnimplementedLevelAdminServer()
+}
+
+func RegisterLevelAdminServer(s grpc.ServiceRegistrar, srv LevelAdminServer) {
+	s.RegisterService(&LevelAdmin_ServiceDesc, srv)
+}
+
+func _LevelAdmin_GetLevels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLevelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LevelAdminServer).GetLevels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcutils.zaplogger.LevelAdmin/GetLevels",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LevelAdminServer).GetLevels(ctx, req.(*GetLevelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LevelAdmin_SetLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LevelAdminServer).SetLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcutils.zaplogger.LevelAdmin/SetLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LevelAdminServer).SetLevel(ctx, req.(*SetLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// LevelAdmin_ServiceDesc is the grpc.ServiceDesc for LevelAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LevelAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpcutils.zaplogger.LevelAdmin",
+	HandlerType: (*LevelAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLevels",
+			Handler:    _LevelAdmin_GetLevels_Handler,
+		},
+		{
+			MethodName: "SetLevel",
+			Handler:    _LevelAdmin_SetLevel_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "grpcutils/zaplogger/admin.proto",
+}
@@ -0,0 +1,3 @@
+// Package adminpb holds the generated code of the LevelAdmin gRPC service, defined in
+// proto/grpcutils/zaplogger/admin.proto and implemented by zaplogger.LevelController.AdminServer.
+package adminpb
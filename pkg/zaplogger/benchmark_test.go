@@ -0,0 +1,117 @@
+package zaplogger
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+)
+
+type dummyBenchmark struct {
+	foobar.UnimplementedDummyServiceServer
+}
+
+func (d *dummyBenchmark) Foo(ctx context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	logger, _ := GetFromContext(ctx, true)
+	logger.Debug("not logged")
+	return &foobar.Empty{}, nil
+}
+
+func benchmarkLogger(b *testing.B, opts ...Option) *Logger {
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(io.Discard), zapcore.InfoLevel)
+	l, err := New(append([]Option{WithLogger(zap.New(core))}, opts...)...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return l
+}
+
+var benchmarkFields = []Option{
+	WithServerName("benchmark"),
+	WithFields(FieldServerName, FieldServerType, FieldRemoteAddr, FieldMethod, FieldRequestID),
+	WithFieldExtractors(MetadataFields("user-agent")),
+}
+
+// BenchmarkUnaryCall compares unary calls throughput over bufconn with and without the logger
+// interceptor
+func BenchmarkUnaryCall(b *testing.B) {
+	benchmarks := map[string][]grpc.ServerOption{
+		"without logger": nil,
+		"logger": {
+			grpc.UnaryInterceptor(benchmarkLogger(b).UnaryInterceptor()),
+		},
+		"logger with fields": {
+			grpc.UnaryInterceptor(benchmarkLogger(b, benchmarkFields...).UnaryInterceptor()),
+		},
+	}
+	for name, opts := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			listener := bufconn.Listen(1024 * 1024)
+			server := grpc.NewServer(opts...)
+			foobar.RegisterDummyServiceServer(server, &dummyBenchmark{})
+			go func() {
+				_ = server.Serve(listener)
+			}()
+			defer server.Stop()
+			conn, err := grpc.Dial(
+				"bufconn",
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+					return listener.Dial()
+				}),
+			)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer conn.Close()
+			client := foobar.NewDummyServiceClient(conn)
+			ctx := requestid.AppendToOutgoingContext(context.Background(), "I'm a unique ID")
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.Foo(ctx, &foobar.Empty{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkUnaryInterceptor measures the allocations of the logger interceptor alone
+func BenchmarkUnaryInterceptor(b *testing.B) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4242}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(requestid.MetadataName, "I'm a unique ID", "user-agent", "benchmark"))
+	infos := &grpc.UnaryServerInfo{FullMethod: "/foobar.DummyService/Foo", Server: &dummyBenchmark{}}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return (&dummyBenchmark{}).Foo(ctx, req.(*foobar.Empty))
+	}
+	benchmarks := map[string]*Logger{
+		"logger":             benchmarkLogger(b),
+		"logger with fields": benchmarkLogger(b, benchmarkFields...),
+	}
+	for name, l := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			interceptor := l.UnaryInterceptor()
+			req := &foobar.Empty{}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := interceptor(ctx, req, infos, handler); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
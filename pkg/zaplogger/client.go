@@ -0,0 +1,161 @@
+package zaplogger
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/logfields"
+)
+
+// UnaryClientInterceptor returns a gRPC client unary interceptor that logs one message per
+// finished call, with its status code and duration.
+// The FieldMethod, FieldTarget, FieldRequestID, FieldAttempt (both from outgoing metadata),
+// FieldTraceID and FieldSpanID fields are added if set with WithFields, according to their failure
+// policy, as well as the fields of WithFieldExtractors.
+// Calls fail with an Internal status code, without being made, if a field with the FailureFail
+// policy is unavailable.
+// Message level depends on the call status code, like for access log.
+func (l *Logger) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		fields, err := l.clientFields(ctx, method, cc)
+		if err != nil {
+			return status.Error(codes.Internal, "failed setting client call log fields")
+		}
+		start := time.Now()
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		l.logClientCall("finished client unary call", err, append(fields,
+			zap.Duration(AccessLogFieldDuration, time.Since(start)),
+		))
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a gRPC client stream interceptor that logs one message per
+// finished stream, with its status code, duration and message counts.
+// A stream is finished when receiving a message fails, or when the response of a client streaming
+// call is received.
+// Fields are added like for UnaryClientInterceptor.
+// Message level depends on the stream status code, like for access log.
+func (l *Logger) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		fields, err := l.clientFields(ctx, method, cc)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed setting client call log fields")
+		}
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			l.logClientCall("finished client stream", err, append(fields,
+				zap.Duration(AccessLogFieldDuration, time.Since(start)),
+			))
+			return nil, err
+		}
+		cs := &loggingClientStream{ClientStream: stream, serverStreams: desc.ServerStreams}
+		cs.finish = func(err error) {
+			l.logClientCall("finished client stream", err, append(fields,
+				zap.Duration(AccessLogFieldDuration, time.Since(start)),
+				zap.Int64(AccessLogFieldMessagesReceived, atomic.LoadInt64(&cs.received)),
+				zap.Int64(AccessLogFieldMessagesSent, atomic.LoadInt64(&cs.sent)),
+			))
+		}
+		return cs, nil
+	}
+}
+
+// clientFields returns the fields of a client call, extracted like the ones of server calls, with
+// room for the fields added by logClientCall.
+func (l *Logger) clientFields(ctx context.Context, method string, cc *grpc.ClientConn) ([]zap.Field, error) {
+	var buf [logfields.MaxFields]logfields.Field
+	extracted, err := l.plan.extract.ExtractClient(ctx, method, buf[:0])
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]zap.Field, 0, len(extracted)+len(l.extractors)+6)
+	if l.plan.target && cc != nil {
+		fields = append(fields, zap.String(FieldTarget, cc.Target()))
+	}
+	return l.zapFields(ctx, method, extracted, fields), nil
+}
+
+// logClientCall logs a finished client call with fields, at the level of its status code.
+func (l *Logger) logClientCall(msg string, err error, fields []zap.Field) {
+	code := status.Code(err)
+	levels := DefaultAccessLogLevels
+	if l.accessLog != nil {
+		levels = l.accessLog.levels
+	}
+	level, ok := levels[code]
+	if !ok {
+		level = zapcore.ErrorLevel
+	}
+	ce := l.logger.Check(level, msg)
+	if ce == nil {
+		return
+	}
+	fields = append(fields, zap.String(AccessLogFieldCode, code.String()))
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	ce.Write(fields...)
+}
+
+// loggingClientStream counts the messages received and sent on a client stream, calling finish
+// once when it ends.
+type loggingClientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	finish        func(err error)
+	once          sync.Once
+
+	received int64
+	sent     int64
+}
+
+// SendMsg sends a message, counting it
+func (s *loggingClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.sent, 1)
+	}
+	return err
+}
+
+// RecvMsg receives a message, counting it and finishing the stream if it ended
+func (s *loggingClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		atomic.AddInt64(&s.received, 1)
+		if !s.serverStreams {
+			s.once.Do(func() { s.finish(nil) })
+		}
+	case err == io.EOF:
+		s.once.Do(func() { s.finish(nil) })
+	default:
+		s.once.Do(func() { s.finish(err) })
+	}
+	return err
+}
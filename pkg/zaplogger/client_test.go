@@ -0,0 +1,100 @@
+package zaplogger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+)
+
+func TestLogger_UnaryClientInterceptor(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(WithLogger(zap.New(core)), WithFields(FieldMethod, FieldTarget, FieldRequestID, FieldAttempt))
+	clientOpts := []grpc.DialOption{grpc.WithUnaryInterceptor(l.UnaryClientInterceptor())}
+	ctx := requestid.AppendToOutgoingContext(context.Background(), "I'm a unique ID")
+	ctx = metadata.AppendToOutgoingContext(ctx, AttemptMetadataName, "2")
+
+	utils.TestCallFoo(t, &dummyAccessLog{}, clientOpts, nil, ctx)
+	if assert.Equal(t, 1, len(recordedLogs.All()), "client calls should be logged") {
+		entry := recordedLogs.TakeAll()[0]
+		fields := entry.ContextMap()
+		assert.Equal(t, zapcore.InfoLevel, entry.Level, "successful client calls should be logged as info")
+		assert.Equal(t, "finished client unary call", entry.Message, "unary client calls should be logged as unary")
+		assert.Equal(t, "OK", fields[AccessLogFieldCode], "client calls should be logged with status code")
+		assert.Equal(t, "/foobar.DummyService/Foo", fields[FieldMethod], "client calls should be logged with method")
+		assert.Contains(t, fields, FieldTarget, "client calls should be logged with target")
+		assert.Equal(t, "I'm a unique ID", fields[FieldRequestID], "client calls should be logged with outgoing request ID")
+		assert.Equal(t, uint64(2), fields[FieldAttempt], "client calls should be logged with attempt, encoded like server calls")
+		assert.Contains(t, fields, AccessLogFieldDuration, "client calls should be logged with duration")
+	}
+
+	utils.TestCallFoo(t, &dummyAccessLog{err: status.Error(codes.Internal, "boom")}, clientOpts, nil)
+	if assert.Equal(t, 1, len(recordedLogs.All()), "failed client calls should be logged") {
+		entry := recordedLogs.TakeAll()[0]
+		assert.Equal(t, zapcore.ErrorLevel, entry.Level, "internal errors should be logged as errors")
+		assert.Equal(t, "Internal", entry.ContextMap()[AccessLogFieldCode], "client calls should be logged with status code")
+		assert.NotContains(t, entry.ContextMap(), FieldRequestID, "client calls without request ID should not be logged with one")
+	}
+
+	// Only selected fields should be logged
+	core, recordedLogs = observer.New(zapcore.DebugLevel)
+	l, _ = New(WithLogger(zap.New(core)))
+	utils.TestCallFoo(t, &dummyAccessLog{}, []grpc.DialOption{grpc.WithUnaryInterceptor(l.UnaryClientInterceptor())}, nil, ctx)
+	if assert.Equal(t, 1, len(recordedLogs.All()), "client calls should be logged") {
+		fields := recordedLogs.All()[0].ContextMap()
+		for _, field := range []string{FieldMethod, FieldTarget, FieldRequestID, FieldAttempt} {
+			assert.NotContains(t, fields, field, "client calls should not be logged with unselected field %s", field)
+		}
+	}
+}
+
+func TestLogger_UnaryClientInterceptor_fieldPolicies(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(
+		WithLogger(zap.New(core)),
+		WithFields(FieldRequestID, FieldAttempt),
+		WithFieldPlaceholder(FieldAttempt, "none"),
+		WithFieldExtractors(func(_ context.Context, fullMethod string) []zap.Field {
+			return []zap.Field{zap.Int("method_length", len(fullMethod))}
+		}),
+	)
+	utils.TestCallFoo(t, &dummyAccessLog{}, []grpc.DialOption{grpc.WithUnaryInterceptor(l.UnaryClientInterceptor())}, nil)
+	if assert.Equal(t, 1, len(recordedLogs.All()), "client calls should be logged") {
+		fields := recordedLogs.TakeAll()[0].ContextMap()
+		assert.NotContains(t, fields, FieldRequestID, "unavailable fields should be skipped by default")
+		assert.Equal(t, "none", fields[FieldAttempt], "unavailable fields should use their placeholder")
+		assert.Equal(t, int64(len("/foobar.DummyService/Foo")), fields["method_length"], "field extractors should be used")
+	}
+
+	l, _ = New(WithLogger(zap.New(core)), WithFields(FieldRequestID), WithFieldFailurePolicy(FieldRequestID, FailureFail))
+	_, _, _, err := utils.TestCallFoo(t, &dummyAccessLog{}, []grpc.DialOption{grpc.WithUnaryInterceptor(l.UnaryClientInterceptor())}, nil)
+	assert.Equal(t, codes.Internal, status.Code(err), "client calls should fail when a field with the fail policy is unavailable")
+	assert.Empty(t, recordedLogs.All(), "client calls failing on fields should not be logged")
+}
+
+func TestLogger_StreamClientInterceptor(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(WithLogger(zap.New(core)), WithFields(FieldMethod))
+	clientOpts := []grpc.DialOption{grpc.WithStreamInterceptor(l.StreamClientInterceptor())}
+
+	utils.TestCallFooS(t, &dummyAccessLog{}, clientOpts, nil)
+	if assert.Equal(t, 1, len(recordedLogs.All()), "client streams should be logged once") {
+		entry := recordedLogs.TakeAll()[0]
+		fields := entry.ContextMap()
+		assert.Equal(t, "finished client stream", entry.Message, "client streams should be logged as streams")
+		assert.Equal(t, "OK", fields[AccessLogFieldCode], "client streams should be logged with status code")
+		assert.Equal(t, "/foobar.DummyService/FooS", fields[FieldMethod], "client streams should be logged with method")
+		assert.Equal(t, int64(6), fields[AccessLogFieldMessagesSent], "client streams should be logged with sent messages count")
+		assert.Equal(t, int64(2), fields[AccessLogFieldMessagesReceived], "client streams should be logged with received messages count")
+	}
+}
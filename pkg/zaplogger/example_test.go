@@ -4,10 +4,14 @@ import (
 	"context"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
 	"github.com/jucrouzet/grpcutils/pkg/zaplogger"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger/adminpb"
 )
 
 // ExampleNew creates a new zaplogger
@@ -26,6 +30,20 @@ func ExampleNew() {
 	l.GetLogger().Debug("hello world")
 }
 
+// ExampleWithFieldFailurePolicy logs remote address as "in-process" when it is unavailable, and
+// fails calls without a request correlation identifier
+func ExampleWithFieldFailurePolicy() {
+	l, err := zaplogger.New(
+		zaplogger.WithFields(zaplogger.FieldRemoteAddr, zaplogger.FieldRequestID),
+		zaplogger.WithFieldPlaceholder(zaplogger.FieldRemoteAddr, "in-process"),
+		zaplogger.WithFieldFailurePolicy(zaplogger.FieldRequestID, zaplogger.FailureFail),
+	)
+	if err != nil {
+		panic(err)
+	}
+	l.GetLogger().Debug("hello world")
+}
+
 // ExampleWithLogger creates a new zaplogger specifying the zap logger
 func ExampleWithLogger() {
 	logger, err := zap.NewProduction()
@@ -70,6 +88,127 @@ func Example_otherInterceptors() {
 	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
 }
 
+// ExampleWithAccessLog logs one message per finished call, only logging one of 100 successful
+// health checks
+func ExampleWithAccessLog() {
+	l, err := zaplogger.New(
+		zaplogger.WithAccessLog(),
+		zaplogger.WithAccessLogLevels(map[codes.Code]zapcore.Level{
+			codes.NotFound: zapcore.WarnLevel,
+		}),
+		zaplogger.WithAccessLogSampling("/grpc.health.v1.Health/*", 100),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(l.UnaryInterceptor()),
+		grpc.StreamInterceptor(l.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
+
+// ExampleWithPayloadLog logs the payloads of calls, redacting the fields marked with the
+// (grpcutils.sensitive) option and the street of addresses
+func ExampleWithPayloadLog() {
+	l, err := zaplogger.New(
+		zaplogger.WithPayloadLog(),
+		zaplogger.WithPayloadRedaction("address.street"),
+		zaplogger.WithPayloadMaxSize(1024),
+	)
+	if err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(l.UnaryInterceptor()),
+		grpc.StreamInterceptor(l.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+}
+
+// ExampleWithLevelController controls log levels at runtime, through the LevelAdmin gRPC service,
+// and allows trusted callers to ask for debug logs of their calls
+func ExampleWithLevelController() {
+	levels := zaplogger.NewLevelController(zapcore.InfoLevel)
+	l, err := zaplogger.New(
+		zaplogger.WithLevelController(levels),
+		zaplogger.WithDebugLogMetadata(func(ctx context.Context) bool {
+			// return true for authenticated administrators
+			return false
+		}),
+	)
+	if err != nil {
+		panic(err)
+	}
+	if err := levels.SetMethodLevel("/foobar.DummyService/*", zapcore.DebugLevel); err != nil {
+		panic(err)
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(l.UnaryInterceptor()),
+		grpc.StreamInterceptor(l.StreamInterceptor()),
+	)
+	foobar.RegisterDummyServiceServer(server, &foobar.UnimplementedDummyServiceServer{})
+	adminpb.RegisterLevelAdminServer(server, levels.AdminServer())
+}
+
+// ExampleWithFieldExtractors adds the tenant identifier and user agent metadata values, and the
+// name of the authenticated user, to request loggers
+func ExampleWithFieldExtractors() {
+	type user struct {
+		name string
+	}
+	l, err := zaplogger.New(
+		zaplogger.WithFieldExtractors(
+			zaplogger.MetadataFields("x-tenant-id", "user-agent"),
+			zaplogger.PrincipalFields(func(u *user) []zap.Field {
+				return []zap.Field{zap.String("user", u.name)}
+			}),
+		),
+	)
+	if err != nil {
+		panic(err)
+	}
+	l.GetLogger().Debug("hello world")
+}
+
+// ExampleLogger_UnaryClientInterceptor logs the outgoing calls of a client connection
+func ExampleLogger_UnaryClientInterceptor() {
+	l, err := zaplogger.New(
+		zaplogger.WithFields(
+			zaplogger.FieldMethod,
+			zaplogger.FieldTarget,
+			zaplogger.FieldRequestID,
+			zaplogger.FieldAttempt,
+		),
+	)
+	if err != nil {
+		panic(err)
+	}
+	conn, err := grpc.Dial(
+		"localhost:8080",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(l.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(l.StreamClientInterceptor()),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	_, _ = foobar.NewDummyServiceClient(conn).Foo(context.Background(), &foobar.Empty{})
+}
+
+// ExampleSetGRPCLogger writes gRPC internal logs to zap, gRPC info messages being logged at debug
+// level
+func ExampleSetGRPCLogger() {
+	l, err := zaplogger.New()
+	if err != nil {
+		panic(err)
+	}
+	if err := zaplogger.SetGRPCLogger(l, zaplogger.WithGRPCInfoLevel(zapcore.DebugLevel)); err != nil {
+		panic(err)
+	}
+}
+
 func ExampleGetFromContext_unary() {
 	// in the body of a service method like :
 	// func MyServiceUnaryMethod(ctx context.Context, param service.Type) (service.Type, error) {
@@ -90,5 +229,19 @@ func ExampleGetFromContext_stream() {
 	logger.With(zap.String("foo", "bar")).Info("important message")
 }
 
+func ExampleAddFields() {
+	// in the body of a service method like :
+	// func MyServiceUnaryMethod(ctx context.Context, param service.Type) (service.Type, error) {
+	if err := zaplogger.AddFields(ctx, zap.String("order_id", "42")); err != nil {
+		panic(err)
+	}
+	// following loggers and access log have the order_id field
+	logger, err := zaplogger.GetFromContext(ctx)
+	if err != nil {
+		panic(err)
+	}
+	logger.Info("order created")
+}
+
 var ctx context.Context
 var s foobar.DummyService_FooSServer
@@ -0,0 +1,63 @@
+package zaplogger
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/jucrouzet/grpcutils/pkg/authorization"
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+)
+
+// FieldExtractor is the function type for functions returning fields to add to the request logger
+// of a call, like a tenant identifier or a client version.
+type FieldExtractor func(ctx context.Context, fullMethod string) []zap.Field
+
+// WithFieldExtractors adds the fields returned by extractors to the request loggers.
+// Extractors are called once per call, in order, when the request logger is created.
+func WithFieldExtractors(extractors ...FieldExtractor) Option {
+	return func(l *Logger) error {
+		for _, extractor := range extractors {
+			if extractor == nil {
+				return errors.New("cannot use a nil field extractor")
+			}
+		}
+		l.extractors = append(l.extractors, extractors...)
+		return nil
+	}
+}
+
+// MetadataFields returns a FieldExtractor adding the values of the specified incoming metadata
+// keys, like "user-agent", as fields named after the keys.
+// Keys with several values are added as arrays, missing keys are not added.
+func MetadataFields(keys ...string) FieldExtractor {
+	return func(ctx context.Context, _ string) []zap.Field {
+		md := metadatautil.FromIncoming(ctx)
+		var fields []zap.Field
+		for _, key := range keys {
+			switch values := md.Get(key); len(values) {
+			case 0:
+			case 1:
+				fields = append(fields, zap.String(key, values[0]))
+			default:
+				fields = append(fields, zap.Strings(key, values))
+			}
+		}
+		return fields
+	}
+}
+
+// PrincipalFields returns a FieldExtractor adding the fields returned by fieldsOf for the
+// authenticated principal of the call, as set by the github.com/jucrouzet/grpcutils/pkg/authorization
+// interceptors, which must be called before zaplogger's ones.
+// T must be the type returned by the authorization CredentialValidator.
+func PrincipalFields[T any](fieldsOf func(principal T) []zap.Field) FieldExtractor {
+	return func(ctx context.Context, _ string) []zap.Field {
+		var principal T
+		if err := authorization.GetFromContext(ctx, &principal); err != nil {
+			return nil
+		}
+		return fieldsOf(principal)
+	}
+}
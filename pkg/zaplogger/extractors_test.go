@@ -0,0 +1,74 @@
+package zaplogger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/authorization"
+)
+
+type dummyExtractors struct {
+	foobar.UnimplementedDummyServiceServer
+}
+
+func (d *dummyExtractors) Foo(ctx context.Context, _ *foobar.Empty) (*foobar.Empty, error) {
+	logger, _ := GetFromContext(ctx)
+	logger.Info("message")
+	return &foobar.Empty{}, nil
+}
+
+type dummyPrincipal struct {
+	user string
+}
+
+func TestWithFieldExtractors(t *testing.T) {
+	l, err := New(WithFieldExtractors(nil))
+	assert.Nil(t, l, "WithFieldExtractors() should not return a logger with a nil extractor")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithFieldExtractors() should return a ErrInvalidOptionValue error with a nil extractor")
+
+	a, _ := authorization.New(authorization.WithMethodFunction("bearer", func(_ context.Context, credential string) (any, error) {
+		return dummyPrincipal{user: credential}, nil
+	}))
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ = New(
+		WithLogger(zap.New(core)),
+		WithFieldExtractors(
+			func(_ context.Context, fullMethod string) []zap.Field {
+				return []zap.Field{zap.Int("method_length", len(fullMethod))}
+			},
+			MetadataFields("x-tenant-id", "x-client-version", "x-missing"),
+			PrincipalFields(func(p dummyPrincipal) []zap.Field {
+				return []zap.Field{zap.String("user", p.user)}
+			}),
+		),
+	)
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(a.UnaryInterceptor(), l.UnaryInterceptor())}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", "acme", "x-client-version", "1.0", "x-client-version", "1.1")
+	ctx, _ = authorization.AppendToOutgoingContext(ctx, "bearer", "john")
+	utils.TestCallFoo(t, &dummyExtractors{}, nil, opts, ctx)
+	if assert.Equal(t, 1, len(recordedLogs.All()), "handler should log") {
+		fields := recordedLogs.All()[0].ContextMap()
+		assert.Equal(t, int64(len("/foobar.DummyService/Foo")), fields["method_length"], "custom extractor fields should be added")
+		assert.Equal(t, "acme", fields["x-tenant-id"], "metadata values should be added")
+		assert.Equal(t, []interface{}{"1.0", "1.1"}, fields["x-client-version"], "metadata multiple values should be added as arrays")
+		assert.NotContains(t, fields, "x-missing", "missing metadata should not be added")
+		assert.Equal(t, "john", fields["user"], "principal fields should be added")
+	}
+
+	recordedLogs.TakeAll()
+	utils.TestCallFoo(t, &dummyExtractors{}, nil, []grpc.ServerOption{grpc.UnaryInterceptor(l.UnaryInterceptor())})
+	if assert.Equal(t, 1, len(recordedLogs.All()), "handler should log") {
+		fields := recordedLogs.All()[0].ContextMap()
+		assert.NotContains(t, fields, "x-tenant-id", "missing metadata should not be added")
+		assert.NotContains(t, fields, "user", "principal fields should not be added without principal")
+	}
+}
@@ -0,0 +1,187 @@
+package zaplogger
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/grpclog"
+)
+
+// GRPCLogFieldComponent is the field holding the gRPC component, like "transport" or "core", of
+// gRPC internal log messages
+const GRPCLogFieldComponent = "grpc_component"
+
+// GRPCLogger is a grpclog.LoggerV2 writing gRPC internal logs to the zap logger of a Logger, named
+// "grpc".
+type GRPCLogger struct {
+	logger    *zap.Logger
+	verbosity int
+	infoLevel zapcore.Level
+}
+
+var _ grpclog.DepthLoggerV2 = &GRPCLogger{}
+
+// GRPCLoggerOption is the GRPCLogger option functions type
+type GRPCLoggerOption func(*GRPCLogger) error
+
+// WithGRPCVerbosity sets the verbosity of gRPC internal logs, as the GRPC_GO_LOG_VERBOSITY_LEVEL
+// environment variable does for gRPC's default logger.
+// If not set, verbosity is 0.
+func WithGRPCVerbosity(verbosity int) GRPCLoggerOption {
+	return func(g *GRPCLogger) error {
+		if verbosity < 0 {
+			return errors.New("verbosity cannot be negative")
+		}
+		g.verbosity = verbosity
+		return nil
+	}
+}
+
+// WithGRPCInfoLevel sets the zap level of gRPC internal info messages, which can be verbose.
+// If not set, zapcore.InfoLevel is used.
+func WithGRPCInfoLevel(level zapcore.Level) GRPCLoggerOption {
+	return func(g *GRPCLogger) error {
+		if level > zapcore.InfoLevel {
+			return errors.New("info level cannot be above info")
+		}
+		g.infoLevel = level
+		return nil
+	}
+}
+
+// NewGRPCLogger creates a new instance of GRPCLogger writing to the zap logger of l.
+func NewGRPCLogger(l *Logger, opts ...GRPCLoggerOption) (*GRPCLogger, error) {
+	if l == nil {
+		return nil, fmt.Errorf("%w : cannot use a nil logger", ErrInvalidOptionValue)
+	}
+	g := &GRPCLogger{
+		logger:    l.GetLogger().Named("grpc"),
+		infoLevel: zapcore.InfoLevel,
+	}
+	for _, opt := range opts {
+		if err := opt(g); err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
+		}
+	}
+	return g, nil
+}
+
+// SetGRPCLogger makes gRPC write its internal logs to the zap logger of l, see NewGRPCLogger.
+// As grpclog.SetLoggerV2, it must be called before any other gRPC function, in an init function
+// for instance.
+func SetGRPCLogger(l *Logger, opts ...GRPCLoggerOption) error {
+	g, err := NewGRPCLogger(l, opts...)
+	if err != nil {
+		return err
+	}
+	grpclog.SetLoggerV2(g)
+	return nil
+}
+
+// Info logs to INFO log
+func (g *GRPCLogger) Info(args ...interface{}) {
+	g.log(2, g.infoLevel, fmt.Sprint(args...))
+}
+
+// Infoln logs to INFO log
+func (g *GRPCLogger) Infoln(args ...interface{}) {
+	g.log(2, g.infoLevel, sprintln(args...))
+}
+
+// Infof logs to INFO log
+func (g *GRPCLogger) Infof(format string, args ...interface{}) {
+	g.log(2, g.infoLevel, fmt.Sprintf(format, args...))
+}
+
+// InfoDepth logs to INFO log at the specified depth
+func (g *GRPCLogger) InfoDepth(depth int, args ...interface{}) {
+	g.log(depth+2, g.infoLevel, sprintln(args...))
+}
+
+// Warning logs to WARNING log
+func (g *GRPCLogger) Warning(args ...interface{}) {
+	g.log(2, zapcore.WarnLevel, fmt.Sprint(args...))
+}
+
+// Warningln logs to WARNING log
+func (g *GRPCLogger) Warningln(args ...interface{}) {
+	g.log(2, zapcore.WarnLevel, sprintln(args...))
+}
+
+// Warningf logs to WARNING log
+func (g *GRPCLogger) Warningf(format string, args ...interface{}) {
+	g.log(2, zapcore.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+// WarningDepth logs to WARNING log at the specified depth
+func (g *GRPCLogger) WarningDepth(depth int, args ...interface{}) {
+	g.log(depth+2, zapcore.WarnLevel, sprintln(args...))
+}
+
+// Error logs to ERROR log
+func (g *GRPCLogger) Error(args ...interface{}) {
+	g.log(2, zapcore.ErrorLevel, fmt.Sprint(args...))
+}
+
+// Errorln logs to ERROR log
+func (g *GRPCLogger) Errorln(args ...interface{}) {
+	g.log(2, zapcore.ErrorLevel, sprintln(args...))
+}
+
+// Errorf logs to ERROR log
+func (g *GRPCLogger) Errorf(format string, args ...interface{}) {
+	g.log(2, zapcore.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+// ErrorDepth logs to ERROR log at the specified depth
+func (g *GRPCLogger) ErrorDepth(depth int, args ...interface{}) {
+	g.log(depth+2, zapcore.ErrorLevel, sprintln(args...))
+}
+
+// Fatal logs to FATAL log and exits
+func (g *GRPCLogger) Fatal(args ...interface{}) {
+	g.log(2, zapcore.FatalLevel, fmt.Sprint(args...))
+}
+
+// Fatalln logs to FATAL log and exits
+func (g *GRPCLogger) Fatalln(args ...interface{}) {
+	g.log(2, zapcore.FatalLevel, sprintln(args...))
+}
+
+// Fatalf logs to FATAL log and exits
+func (g *GRPCLogger) Fatalf(format string, args ...interface{}) {
+	g.log(2, zapcore.FatalLevel, fmt.Sprintf(format, args...))
+}
+
+// FatalDepth logs to FATAL log at the specified depth and exits
+func (g *GRPCLogger) FatalDepth(depth int, args ...interface{}) {
+	g.log(depth+2, zapcore.FatalLevel, sprintln(args...))
+}
+
+// V reports whether verbosity level l is at least the requested verbose level
+func (g *GRPCLogger) V(l int) bool {
+	return l <= g.verbosity
+}
+
+var componentRegex = regexp.MustCompile(`(?s)^\[([^\]\s]+)\] ?(.*)$`)
+
+// log logs msg at level, reporting the caller skip frames above it.
+func (g *GRPCLogger) log(skip int, level zapcore.Level, msg string) {
+	logger := g.logger.WithOptions(zap.AddCallerSkip(skip))
+	var fields []zap.Field
+	if m := componentRegex.FindStringSubmatch(msg); m != nil {
+		msg = m[2]
+		fields = append(fields, zap.String(GRPCLogFieldComponent, m[1]))
+	}
+	if ce := logger.Check(level, msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+func sprintln(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
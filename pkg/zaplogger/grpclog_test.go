@@ -0,0 +1,59 @@
+package zaplogger
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewGRPCLogger(t *testing.T) {
+	g, err := NewGRPCLogger(nil)
+	assert.Nil(t, g, "NewGRPCLogger() should not return a GRPCLogger with a nil logger")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "NewGRPCLogger() should return a ErrInvalidOptionValue error with a nil logger")
+
+	l, _ := New()
+	g, err = NewGRPCLogger(l, WithGRPCVerbosity(-1))
+	assert.Nil(t, g, "NewGRPCLogger() should not return a GRPCLogger with a negative verbosity")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "NewGRPCLogger() should return a ErrInvalidOptionValue error with a negative verbosity")
+	g, err = NewGRPCLogger(l, WithGRPCInfoLevel(zapcore.WarnLevel))
+	assert.Nil(t, g, "NewGRPCLogger() should not return a GRPCLogger with an info level above info")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "NewGRPCLogger() should return a ErrInvalidOptionValue error with an info level above info")
+
+	g, _ = NewGRPCLogger(l, WithGRPCVerbosity(2))
+	assert.True(t, g.V(2), "V() should return true for levels up to verbosity")
+	assert.False(t, g.V(3), "V() should return false for levels above verbosity")
+}
+
+func TestGRPCLogger(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(WithLogger(zap.New(core, zap.AddCaller())))
+	g, _ := NewGRPCLogger(l, WithGRPCInfoLevel(zapcore.DebugLevel))
+
+	g.Infof("[transport] %s closed", "connection")
+	g.WarningDepth(0, "[core]", "channel", "IDLE")
+	g.Errorln("failed", 42)
+	g.Info("[not a component]")
+	logs := recordedLogs.TakeAll()
+	if assert.Equal(t, 4, len(logs), "gRPC messages should be logged") {
+		assert.Equal(t, zapcore.DebugLevel, logs[0].Level, "info messages should use the info level")
+		assert.Equal(t, "connection closed", logs[0].Message, "component should be removed from messages")
+		assert.Equal(t, "transport", logs[0].ContextMap()[GRPCLogFieldComponent], "component should be logged")
+		assert.Equal(t, "grpc", logs[0].LoggerName, "gRPC messages should be logged with the grpc logger")
+		assert.Equal(t, "grpclog_test.go", filepath.Base(logs[0].Caller.File), "caller should be the gRPC logger caller")
+
+		assert.Equal(t, zapcore.WarnLevel, logs[1].Level, "warning messages should be logged as warnings")
+		assert.Equal(t, "channel IDLE", logs[1].Message, "depth messages should be formatted as with fmt.Println")
+		assert.Equal(t, "core", logs[1].ContextMap()[GRPCLogFieldComponent], "component should be logged")
+		assert.Equal(t, "grpclog_test.go", filepath.Base(logs[1].Caller.File), "caller should be at depth")
+
+		assert.Equal(t, zapcore.ErrorLevel, logs[2].Level, "error messages should be logged as errors")
+		assert.Equal(t, "failed 42", logs[2].Message, "messages should be formatted as with fmt.Println")
+		assert.NotContains(t, logs[2].ContextMap(), GRPCLogFieldComponent, "messages without component should not have one")
+
+		assert.Equal(t, "[not a component]", logs[3].Message, "messages not starting with a component should not be changed")
+	}
+}
@@ -0,0 +1,219 @@
+package zaplogger
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/methodpattern"
+	"github.com/jucrouzet/grpcutils/pkg/metadatautil"
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+)
+
+// DebugLogMetadataName is the name of the metadata flag asking for debug logs of a call, see
+// WithDebugLogMetadata.
+const DebugLogMetadataName = "x-debug-log"
+
+// LevelController controls the log level of a Logger at runtime, globally, by method or by request
+// correlation identifier.
+// Its levels filter log entries before the core of the zap logger given to WithLogger, which still
+// applies its own level, sampling and filtering : it should be enabled for all the levels that can
+// be set.
+type LevelController struct {
+	level zap.AtomicLevel
+
+	mu         sync.RWMutex
+	methods    map[string]zapcore.Level
+	requestIDs map[string]zapcore.Level
+}
+
+// NewLevelController creates a new LevelController with the specified default level.
+func NewLevelController(level zapcore.Level) *LevelController {
+	return &LevelController{
+		level:      zap.NewAtomicLevelAt(level),
+		methods:    make(map[string]zapcore.Level),
+		requestIDs: make(map[string]zapcore.Level),
+	}
+}
+
+// Level returns the default log level.
+func (c *LevelController) Level() zapcore.Level {
+	return c.level.Level()
+}
+
+// SetLevel changes the default log level.
+func (c *LevelController) SetLevel(level zapcore.Level) {
+	c.level.SetLevel(level)
+}
+
+// SetMethodLevel sets the log level of calls to the methods matching `method`, like
+// "/package.Service/Method" or "/package.Service/*".
+// It applies to calls started after it is set.
+func (c *LevelController) SetMethodLevel(method string, level zapcore.Level) error {
+	if err := methodpattern.Validate(method); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.methods[method] = level
+	return nil
+}
+
+// UnsetMethodLevel removes the log level set with SetMethodLevel.
+func (c *LevelController) UnsetMethodLevel(method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.methods, method)
+}
+
+// MethodLevels returns the log levels set with SetMethodLevel.
+func (c *LevelController) MethodLevels() map[string]zapcore.Level {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	levels := make(map[string]zapcore.Level, len(c.methods))
+	for method, level := range c.methods {
+		levels[method] = level
+	}
+	return levels
+}
+
+// SetRequestIDLevel sets the log level of calls with the specified request correlation identifier
+// (see github.com/jucrouzet/grpcutils/pkg/requestid).
+// It takes precedence over method levels.
+func (c *LevelController) SetRequestIDLevel(id string, level zapcore.Level) error {
+	if id == "" {
+		return errors.New("request ID cannot be empty")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requestIDs[id] = level
+	return nil
+}
+
+// UnsetRequestIDLevel removes the log level set with SetRequestIDLevel.
+func (c *LevelController) UnsetRequestIDLevel(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.requestIDs, id)
+}
+
+// RequestIDLevels returns the log levels set with SetRequestIDLevel.
+func (c *LevelController) RequestIDLevels() map[string]zapcore.Level {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	levels := make(map[string]zapcore.Level, len(c.requestIDs))
+	for id, level := range c.requestIDs {
+		levels[id] = level
+	}
+	return levels
+}
+
+// callLevel returns the log level set for a call, if any.
+func (c *LevelController) callLevel(ctx context.Context, fullMethod string) (zapcore.Level, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if id := requestid.GetFromContext(ctx); id != "" {
+		if level, ok := c.requestIDs[id]; ok {
+			return level, true
+		}
+	}
+	_, level, ok := methodpattern.Lookup(c.methods, fullMethod)
+	return level, ok
+}
+
+// WithLevelController makes the Logger levels controlled at runtime by c.
+func WithLevelController(c *LevelController) Option {
+	return func(l *Logger) error {
+		if c == nil {
+			return errors.New("cannot use a nil level controller")
+		}
+		l.levels = c
+		return nil
+	}
+}
+
+// WithDebugLogMetadata makes the interceptors log at debug level the calls having a "true" or "1"
+// DebugLogMetadataName metadata flag, if trusted returns true for the call context.
+// As debug logs can be verbose and contain sensitive data, trusted should only accept
+// authenticated callers, for instance using github.com/jucrouzet/grpcutils/pkg/authorization.
+// Other calls are logged from info level, or the level of WithLevelController, and the zap logger
+// given to WithLogger should be enabled at debug level.
+func WithDebugLogMetadata(trusted func(ctx context.Context) bool) Option {
+	return func(l *Logger) error {
+		if trusted == nil {
+			return errors.New("cannot use a nil trusted function")
+		}
+		l.debugLogTrusted = trusted
+		return nil
+	}
+}
+
+// withCallLevel returns logger with the level set for the call, if any.
+func (l *Logger) withCallLevel(ctx context.Context, fullMethod string, logger *zap.Logger) *zap.Logger {
+	if l.debugLogTrusted != nil && hasDebugLogFlag(ctx) && l.debugLogTrusted(ctx) {
+		return logger.WithOptions(withLevel(zapcore.DebugLevel))
+	}
+	if l.levels == nil {
+		return logger
+	}
+	if level, ok := l.levels.callLevel(ctx, fullMethod); ok {
+		return logger.WithOptions(withLevel(level))
+	}
+	return logger
+}
+
+func hasDebugLogFlag(ctx context.Context) bool {
+	for _, v := range metadatautil.Values(metadatautil.FromIncoming(ctx), DebugLogMetadataName) {
+		if v == "true" || v == "1" {
+			return true
+		}
+	}
+	return false
+}
+
+// levelCore is a zapcore.Core which level is controlled by its enabler, entries it enables being
+// then checked by the wrapped core.
+type levelCore struct {
+	zapcore.Core
+	enabler zapcore.LevelEnabler
+}
+
+// wrapLevelCore wraps the logger core so that its level can be changed per call, using enabler as
+// default level.
+func wrapLevelCore(enabler zapcore.LevelEnabler) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelCore{Core: core, enabler: enabler}
+	})
+}
+
+// withLevel changes the level of a logger wrapped with wrapLevelCore.
+func withLevel(level zapcore.Level) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if lc, ok := core.(*levelCore); ok {
+			return &levelCore{Core: lc.Core, enabler: level}
+		}
+		return core
+	})
+}
+
+// Enabled returns whether the level is enabled
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.enabler.Enabled(level)
+}
+
+// With adds fields to the core
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), enabler: c.enabler}
+}
+
+// Check lets the wrapped core check the entry, so that its sampling or filtering applies, if its
+// level is enabled
+func (c *levelCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return c.Core.Check(entry, ce)
+	}
+	return ce
+}
@@ -0,0 +1,77 @@
+package zaplogger
+
+import (
+	"context"
+
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger/adminpb"
+)
+
+// AdminServer returns an implementation of the LevelAdmin gRPC service (see
+// github.com/jucrouzet/grpcutils/pkg/zaplogger/adminpb), viewing and changing the controller log
+// levels at runtime.
+// As it allows to make logs verbose, it should only be callable by administrators, for instance
+// using github.com/jucrouzet/grpcutils/pkg/authorization.
+func (c *LevelController) AdminServer() adminpb.LevelAdminServer {
+	return &levelAdmin{controller: c}
+}
+
+type levelAdmin struct {
+	adminpb.UnimplementedLevelAdminServer
+	controller *LevelController
+}
+
+// GetLevels returns the current log levels
+func (a *levelAdmin) GetLevels(context.Context, *adminpb.GetLevelsRequest) (*adminpb.Levels, error) {
+	return a.levels(), nil
+}
+
+// SetLevel changes the default log level, or the log level of a method or a request ID
+func (a *levelAdmin) SetLevel(_ context.Context, req *adminpb.SetLevelRequest) (*adminpb.Levels, error) {
+	if req.GetMethod() != "" && req.GetRequestId() != "" {
+		return nil, status.Error(codes.InvalidArgument, "cannot set the level of both a method and a request ID")
+	}
+	var level zapcore.Level
+	if req.GetLevel() != "" {
+		if err := level.UnmarshalText([]byte(req.GetLevel())); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid level: %s", err.Error())
+		}
+	}
+	switch {
+	case req.GetMethod() != "" && req.GetLevel() == "":
+		a.controller.UnsetMethodLevel(req.GetMethod())
+	case req.GetMethod() != "":
+		if err := a.controller.SetMethodLevel(req.GetMethod(), level); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	case req.GetRequestId() != "" && req.GetLevel() == "":
+		a.controller.UnsetRequestIDLevel(req.GetRequestId())
+	case req.GetRequestId() != "":
+		if err := a.controller.SetRequestIDLevel(req.GetRequestId(), level); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	case req.GetLevel() == "":
+		return nil, status.Error(codes.InvalidArgument, "default level cannot be empty")
+	default:
+		a.controller.SetLevel(level)
+	}
+	return a.levels(), nil
+}
+
+func (a *levelAdmin) levels() *adminpb.Levels {
+	levels := &adminpb.Levels{
+		Level:      a.controller.Level().String(),
+		Methods:    make(map[string]string),
+		RequestIds: make(map[string]string),
+	}
+	for method, level := range a.controller.MethodLevels() {
+		levels.Methods[method] = level.String()
+	}
+	for id, level := range a.controller.RequestIDLevels() {
+		levels.RequestIds[id] = level.String()
+	}
+	return levels
+}
@@ -0,0 +1,142 @@
+package zaplogger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/jucrouzet/grpcutils/pkg/requestid"
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+	"github.com/jucrouzet/grpcutils/pkg/zaplogger/adminpb"
+)
+
+// debugCall calls the unary interceptor of l with a handler logging a debug message, returning
+// whether it was logged.
+func debugCall(ctx context.Context, l *Logger, recordedLogs *observer.ObservedLogs, method string) bool {
+	_, _ = l.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		logger, _ := GetFromContext(ctx)
+		logger.Debug("debug message")
+		return nil, nil
+	})
+	return len(recordedLogs.TakeAll()) > 0
+}
+
+func TestWithLevelController(t *testing.T) {
+	l, err := New(WithLevelController(nil))
+	assert.Nil(t, l, "WithLevelController() should not return a logger with a nil controller")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithLevelController() should return a ErrInvalidOptionValue error with a nil controller")
+
+	c := NewLevelController(zapcore.InfoLevel)
+	assert.NotNil(t, c.SetMethodLevel("Foo", zapcore.DebugLevel), "SetMethodLevel() should return an error with an invalid method")
+	assert.NotNil(t, c.SetRequestIDLevel("", zapcore.DebugLevel), "SetRequestIDLevel() should return an error with an empty request ID")
+
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ = New(WithLogger(zap.New(core)), WithLevelController(c))
+	assert.False(t, debugCall(context.Background(), l, recordedLogs, "/foobar.DummyService/Foo"), "controller level should replace logger level")
+
+	assert.Nil(t, c.SetMethodLevel("/foobar.DummyService/*", zapcore.DebugLevel), "SetMethodLevel() should not return an error")
+	assert.True(t, debugCall(context.Background(), l, recordedLogs, "/foobar.DummyService/Foo"), "method level should apply to calls")
+	assert.False(t, debugCall(context.Background(), l, recordedLogs, "/other.Service/Foo"), "method level should not apply to other methods calls")
+	c.UnsetMethodLevel("/foobar.DummyService/*")
+	assert.False(t, debugCall(context.Background(), l, recordedLogs, "/foobar.DummyService/Foo"), "unset method level should not apply to calls")
+
+	assert.Nil(t, c.SetRequestIDLevel("I'm a unique ID", zapcore.DebugLevel), "SetRequestIDLevel() should not return an error")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestid.MetadataName, "I'm a unique ID"))
+	assert.True(t, debugCall(ctx, l, recordedLogs, "/foobar.DummyService/Foo"), "request ID level should apply to calls")
+	assert.Nil(t, c.SetMethodLevel("/foobar.DummyService/Foo", zapcore.ErrorLevel), "SetMethodLevel() should not return an error")
+	assert.True(t, debugCall(ctx, l, recordedLogs, "/foobar.DummyService/Foo"), "request ID level should take precedence over method level")
+
+	c.SetLevel(zapcore.WarnLevel)
+	l.GetLogger().Info("info message")
+	assert.Equal(t, 0, len(recordedLogs.TakeAll()), "SetLevel() should change the default level")
+	c.SetLevel(zapcore.DebugLevel)
+	l.GetLogger().Debug("debug message")
+	assert.Equal(t, 1, len(recordedLogs.TakeAll()), "SetLevel() should change the default level")
+}
+
+func TestWithDebugLogMetadata(t *testing.T) {
+	l, err := New(WithDebugLogMetadata(nil))
+	assert.Nil(t, l, "WithDebugLogMetadata() should not return a logger with a nil function")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithDebugLogMetadata() should return a ErrInvalidOptionValue error with a nil function")
+
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ = New(WithLogger(zap.New(core)), WithDebugLogMetadata(func(ctx context.Context) bool {
+		md, _ := metadata.FromIncomingContext(ctx)
+		return len(md.Get("trusted")) > 0
+	}))
+	trusted := metadata.NewIncomingContext(context.Background(), metadata.Pairs(DebugLogMetadataName, "true", "trusted", "yes"))
+	untrusted := metadata.NewIncomingContext(context.Background(), metadata.Pairs(DebugLogMetadataName, "1"))
+	unflagged := metadata.NewIncomingContext(context.Background(), metadata.Pairs("trusted", "yes"))
+	assert.True(t, debugCall(trusted, l, recordedLogs, "/foobar.DummyService/Foo"), "debug log flag should apply to trusted calls")
+	assert.False(t, debugCall(untrusted, l, recordedLogs, "/foobar.DummyService/Foo"), "debug log flag should not apply to untrusted calls")
+	assert.False(t, debugCall(unflagged, l, recordedLogs, "/foobar.DummyService/Foo"), "calls without debug log flag should use info level")
+	l.GetLogger().Debug("debug message")
+	assert.Equal(t, 0, len(recordedLogs.TakeAll()), "logger default level should be info")
+
+	// Stream calls
+	l, _ = New(WithLogger(zap.New(core)), WithDebugLogMetadata(func(context.Context) bool { return true }))
+	_ = l.StreamInterceptor()(nil, &streamutil.ServerStream{Ctx: untrusted}, &grpc.StreamServerInfo{FullMethod: "/foobar.DummyService/FooS"}, func(_ interface{}, s grpc.ServerStream) error {
+		logger, _ := GetFromContext(s.Context())
+		logger.Debug("debug message")
+		return nil
+	})
+	assert.Equal(t, 1, len(recordedLogs.TakeAll()), "debug log flag should apply to streams")
+}
+
+func TestWithLevelController_wrappedCore(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.InfoLevel)
+	sampled := zapcore.NewSamplerWithOptions(core, time.Minute, 1, 0)
+	c := NewLevelController(zapcore.DebugLevel)
+	l, _ := New(WithLogger(zap.New(sampled)), WithLevelController(c))
+
+	for i := 0; i < 3; i++ {
+		l.GetLogger().Info("info message")
+	}
+	assert.Equal(t, 1, len(recordedLogs.TakeAll()), "logger sampling should still apply")
+	l.GetLogger().Debug("debug message")
+	assert.Equal(t, 0, len(recordedLogs.TakeAll()), "logger level should still apply")
+}
+
+func TestLevelController_AdminServer(t *testing.T) {
+	c := NewLevelController(zapcore.InfoLevel)
+	admin := c.AdminServer()
+	ctx := context.Background()
+
+	levels, err := admin.SetLevel(ctx, &adminpb.SetLevelRequest{Level: "warn"})
+	assert.Nil(t, err, "SetLevel() should not return an error")
+	assert.Equal(t, zapcore.WarnLevel, c.Level(), "SetLevel() should set default level")
+	assert.Equal(t, "warn", levels.GetLevel(), "SetLevel() should return levels")
+
+	_, err = admin.SetLevel(ctx, &adminpb.SetLevelRequest{Method: "/foobar.DummyService/Foo", Level: "debug"})
+	assert.Nil(t, err, "SetLevel() should not return an error")
+	_, err = admin.SetLevel(ctx, &adminpb.SetLevelRequest{RequestId: "I'm a unique ID", Level: "debug"})
+	assert.Nil(t, err, "SetLevel() should not return an error")
+	levels, _ = admin.GetLevels(ctx, &adminpb.GetLevelsRequest{})
+	assert.Equal(t, map[string]string{"/foobar.DummyService/Foo": "debug"}, levels.GetMethods(), "GetLevels() should return method levels")
+	assert.Equal(t, map[string]string{"I'm a unique ID": "debug"}, levels.GetRequestIds(), "GetLevels() should return request ID levels")
+
+	_, _ = admin.SetLevel(ctx, &adminpb.SetLevelRequest{Method: "/foobar.DummyService/Foo"})
+	levels, _ = admin.SetLevel(ctx, &adminpb.SetLevelRequest{RequestId: "I'm a unique ID"})
+	assert.Empty(t, levels.GetMethods(), "SetLevel() without level should remove method level")
+	assert.Empty(t, levels.GetRequestIds(), "SetLevel() without level should remove request ID level")
+
+	invalids := map[string]*adminpb.SetLevelRequest{
+		"an invalid level":               {Level: "verbose"},
+		"an empty default level":         {},
+		"an invalid method":              {Method: "Foo", Level: "debug"},
+		"both a method and a request ID": {Method: "/foobar.DummyService/Foo", RequestId: "I'm a unique ID", Level: "debug"},
+	}
+	for name, req := range invalids {
+		_, err = admin.SetLevel(ctx, req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "SetLevel() should return an InvalidArgument error with %s", name)
+	}
+}
@@ -0,0 +1,201 @@
+package zaplogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/jucrouzet/grpcutils/pkg/annotations"
+)
+
+const (
+	// PayloadLogFieldPayload is the payload log field holding the JSON encoded message
+	PayloadLogFieldPayload = "payload"
+	// PayloadLogFieldSequence is the payload log field holding the sequence number, starting at 1,
+	// of a message in its direction of a stream
+	PayloadLogFieldSequence = "sequence"
+	// PayloadLogFieldTruncated is the payload log field set to true when the payload was truncated
+	PayloadLogFieldTruncated = "payload_truncated"
+
+	// DefaultPayloadMaxSize is the default maximum size, in bytes, of logged payloads
+	DefaultPayloadMaxSize = 4096
+	// RedactedValue replaces the value of redacted fields in logged payloads
+	RedactedValue = "[REDACTED]"
+)
+
+type payloadLog struct {
+	redacted map[string]struct{}
+	maxSize  int
+}
+
+var fieldPathRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// WithPayloadLog makes the interceptors log, at debug level, the JSON encoded request and response
+// messages of unary calls, and every message received and sent on streams with its sequence
+// number.
+// Fields marked with the (grpcutils.sensitive) option (see
+// github.com/jucrouzet/grpcutils/pkg/annotations) are always redacted.
+func WithPayloadLog() Option {
+	return func(l *Logger) error {
+		l.enablePayloadLog()
+		return nil
+	}
+}
+
+// WithPayloadRedaction redacts fields from logged payloads, in addition to the fields marked with
+// the (grpcutils.sensitive) option.
+// Fields are specified by their path of protobuf field names from the logged message, like
+// "password" or "address.street", repeated and map fields being traversed without index or key.
+// It enables payload log.
+func WithPayloadRedaction(paths ...string) Option {
+	return func(l *Logger) error {
+		for _, path := range paths {
+			if !fieldPathRegex.MatchString(path) {
+				return fmt.Errorf(`invalid field path "%s"`, path)
+			}
+		}
+		l.enablePayloadLog()
+		for _, path := range paths {
+			l.payloadLog.redacted[path] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithPayloadMaxSize sets the maximum size, in bytes, of logged payloads, longer payloads being
+// truncated.
+// If not set, DefaultPayloadMaxSize is used.
+// It enables payload log.
+func WithPayloadMaxSize(size int) Option {
+	return func(l *Logger) error {
+		if size < 1 {
+			return errors.New("payload maximum size must be at least 1")
+		}
+		l.enablePayloadLog()
+		l.payloadLog.maxSize = size
+		return nil
+	}
+}
+
+func (l *Logger) enablePayloadLog() {
+	if l.payloadLog != nil {
+		return
+	}
+	l.payloadLog = &payloadLog{
+		redacted: make(map[string]struct{}),
+		maxSize:  DefaultPayloadMaxSize,
+	}
+}
+
+// log logs the payload of message m, if it is a protobuf message.
+func (p *payloadLog) log(logger *zap.Logger, msg string, m interface{}, fields ...zap.Field) {
+	ce := logger.Check(zapcore.DebugLevel, msg)
+	if ce == nil {
+		return
+	}
+	pm, ok := m.(proto.Message)
+	if !ok || pm == nil {
+		return
+	}
+	payload, err := p.encode(pm)
+	if err != nil {
+		ce.Write(append(fields, zap.NamedError("payload_error", err))...)
+		return
+	}
+	if len(payload) > p.maxSize {
+		i := p.maxSize
+		for i > 0 && !utf8.RuneStart(payload[i]) {
+			i--
+		}
+		payload = payload[:i]
+		fields = append(fields, zap.Bool(PayloadLogFieldTruncated, true))
+	}
+	ce.Write(append(fields, zap.ByteString(PayloadLogFieldPayload, payload))...)
+}
+
+// encode returns the JSON encoding of m with its sensitive fields redacted.
+func (p *payloadLog) encode(m proto.Message) ([]byte, error) {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var decoded map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&decoded); err != nil {
+		// Well known types can be encoded as JSON values other than objects
+		return data, nil
+	}
+	p.redact(m.ProtoReflect(), decoded, "")
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(decoded); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// redact replaces the value of the sensitive fields of m in obj, its decoded JSON encoding.
+func (p *payloadLog) redact(m protoreflect.Message, obj map[string]interface{}, path string) {
+	if a, ok := m.Interface().(*anypb.Any); ok {
+		if inner, err := a.UnmarshalNew(); err == nil {
+			p.redact(inner.ProtoReflect(), obj, path)
+		}
+		return
+	}
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		value, ok := obj[fd.JSONName()]
+		if !ok {
+			continue
+		}
+		fieldPath := string(fd.Name())
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+		if _, ok := p.redacted[fieldPath]; ok || annotations.IsSensitive(fd) {
+			obj[fd.JSONName()] = RedactedValue
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			entries, _ := value.(map[string]interface{})
+			if fd.MapValue().Message() == nil || entries == nil {
+				continue
+			}
+			m.Get(fd).Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				if entry, ok := entries[k.String()].(map[string]interface{}); ok {
+					p.redact(v.Message(), entry, fieldPath)
+				}
+				return true
+			})
+		case fd.IsList():
+			items, _ := value.([]interface{})
+			if fd.Message() == nil || items == nil {
+				continue
+			}
+			list := m.Get(fd).List()
+			for j := 0; j < list.Len() && j < len(items); j++ {
+				if item, ok := items[j].(map[string]interface{}); ok {
+					p.redact(list.Get(j).Message(), item, fieldPath)
+				}
+			}
+		case fd.Message() != nil:
+			if sub, ok := value.(map[string]interface{}); ok {
+				p.redact(m.Get(fd).Message(), sub, fieldPath)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package zaplogger
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
+	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+)
+
+func TestWithPayloadRedaction(t *testing.T) {
+	for _, path := range []string{"", "address.", ".street", "address street"} {
+		l, err := New(WithPayloadRedaction(path))
+		assert.Nil(t, l, "WithPayloadRedaction() should not return a logger with invalid path %q", path)
+		assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithPayloadRedaction() should return a ErrInvalidOptionValue error with invalid path %q", path)
+	}
+	l, err := New(WithPayloadMaxSize(0))
+	assert.Nil(t, l, "WithPayloadMaxSize() should not return a logger with a zero size")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithPayloadMaxSize() should return a ErrInvalidOptionValue error with a zero size")
+
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ = New(WithLogger(zap.New(core)), WithPayloadRedaction("name", "previous_addresses.city"))
+	req := &foobar.User{
+		Name:     "John",
+		Password: "secret",
+		Address:  &foobar.Address{Street: "1 main street", City: "Paris"},
+		PreviousAddresses: []*foobar.Address{
+			{Street: "2 main street", City: "Lyon"},
+		},
+		AddressesByLabel: map[string]*foobar.Address{
+			"work": {Street: "3 main street", City: "Nice"},
+		},
+	}
+	infos := &grpc.UnaryServerInfo{FullMethod: "/foobar.DummyService/Foo"}
+	_, _ = l.UnaryInterceptor()(context.Background(), req, infos, func(context.Context, interface{}) (interface{}, error) {
+		return &foobar.Empty{}, nil
+	})
+	logs := recordedLogs.TakeAll()
+	if assert.Equal(t, 2, len(logs), "payload log should log request and response") {
+		assert.Equal(t, zapcore.DebugLevel, logs[0].Level, "payloads should be logged as debug")
+		assert.Equal(t, "request payload", logs[0].Message, "request should be logged first")
+		assert.Equal(t, "/foobar.DummyService/Foo", logs[0].ContextMap()[FieldMethod], "payload log should log method")
+		var payload map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(logs[0].ContextMap()[PayloadLogFieldPayload].(string)), &payload), "payload should be JSON")
+		assert.Equal(t, map[string]interface{}{
+			"name":     RedactedValue,
+			"password": RedactedValue,
+			"address":  map[string]interface{}{"street": RedactedValue, "city": "Paris"},
+			"previousAddresses": []interface{}{
+				map[string]interface{}{"street": RedactedValue, "city": RedactedValue},
+			},
+			"addressesByLabel": map[string]interface{}{
+				"work": map[string]interface{}{"street": RedactedValue, "city": "Nice"},
+			},
+		}, payload, "payload should be redacted")
+		assert.Equal(t, "response payload", logs[1].Message, "response should be logged last")
+		assert.Equal(t, "{}", logs[1].ContextMap()[PayloadLogFieldPayload], "response payload should be logged")
+	}
+
+	// Sensitive fields of messages in google.protobuf.Any values should be redacted
+	user, _ := anypb.New(&foobar.User{Name: "John", Password: "secret"})
+	_, _ = l.UnaryInterceptor()(context.Background(), user, infos, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Internal, "boom")
+	})
+	logs = recordedLogs.TakeAll()
+	if assert.Equal(t, 1, len(logs), "payload log should not log response of failed calls") {
+		payload := logs[0].ContextMap()[PayloadLogFieldPayload].(string)
+		assert.NotContains(t, payload, "secret", "payload of Any values should be redacted")
+		assert.NotContains(t, payload, "John", "payload of Any values should be redacted")
+	}
+}
+
+func TestWithPayloadMaxSize(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(WithLogger(zap.New(core)), WithPayloadMaxSize(20))
+	req := &foobar.User{Name: strings.Repeat("é", 20)}
+	infos := &grpc.UnaryServerInfo{FullMethod: "/foobar.DummyService/Foo"}
+	_, _ = l.UnaryInterceptor()(context.Background(), req, infos, func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	logs := recordedLogs.TakeAll()
+	if assert.Equal(t, 1, len(logs), "payload log should log request") {
+		fields := logs[0].ContextMap()
+		assert.Equal(t, `{"name":"ééééé`, fields[PayloadLogFieldPayload], "payload should be truncated on a character boundary")
+		assert.Equal(t, true, fields[PayloadLogFieldTruncated], "truncated payloads should be flagged")
+	}
+
+	// Payload should not be encoded when debug level is disabled
+	core, recordedLogs = observer.New(zapcore.InfoLevel)
+	l, _ = New(WithLogger(zap.New(core)), WithPayloadLog())
+	_, _ = l.UnaryInterceptor()(context.Background(), req, infos, func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	assert.Equal(t, 0, len(recordedLogs.All()), "payload log should not log when debug level is disabled")
+}
+
+func TestWithPayloadLog_stream(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(WithLogger(zap.New(core)), WithPayloadLog(), WithAccessLog())
+	utils.TestCallFooS(t, &dummyAccessLog{}, nil, []grpc.ServerOption{grpc.StreamInterceptor(l.StreamInterceptor())})
+
+	var received, sent []int64
+	for _, entry := range recordedLogs.All() {
+		fields := entry.ContextMap()
+		switch entry.Message {
+		case "stream message received":
+			received = append(received, fields[PayloadLogFieldSequence].(int64))
+		case "stream message sent":
+			sent = append(sent, fields[PayloadLogFieldSequence].(int64))
+		default:
+			continue
+		}
+		assert.Equal(t, "{}", fields[PayloadLogFieldPayload], "stream messages payload should be logged")
+		assert.Equal(t, "/foobar.DummyService/FooS", fields[FieldMethod], "stream messages should be logged with method")
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6}, received, "received messages should be logged with sequence numbers")
+	assert.Equal(t, []int64{1, 2}, sent, "sent messages should be logged with sequence numbers")
+	assert.Equal(t, "finished stream", recordedLogs.All()[len(recordedLogs.All())-1].Message, "access log should still be logged")
+}
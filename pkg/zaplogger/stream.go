@@ -0,0 +1,40 @@
+package zaplogger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/jucrouzet/grpcutils/pkg/streamutil"
+)
+
+// loggingStream returns stream with ctx as context, counting the messages received and sent,
+// logging their payload if payload log is enabled.
+func (l *Logger) loggingStream(
+	stream grpc.ServerStream,
+	ctx context.Context,
+	holder *loggerHolder,
+	fullMethod string,
+) *streamutil.ServerStream {
+	ns := &streamutil.ServerStream{ServerStream: stream, Ctx: ctx}
+	if l.payloadLog == nil {
+		return ns
+	}
+	fields := l.callFields(stream.Context(), fullMethod)
+	ns.RecvMsgHook = func(m interface{}, next streamutil.MsgHandler) error {
+		if err := next(m); err != nil {
+			return err
+		}
+		l.payloadLog.log(holder.get(), "stream message received", m, append(fields, zap.Int64(PayloadLogFieldSequence, ns.MessagesReceived()))...)
+		return nil
+	}
+	ns.SendMsgHook = func(m interface{}, next streamutil.MsgHandler) error {
+		if err := next(m); err != nil {
+			return err
+		}
+		l.payloadLog.log(holder.get(), "stream message sent", m, append(fields, zap.Int64(PayloadLogFieldSequence, ns.MessagesSent()))...)
+		return nil
+	}
+	return ns
+}
@@ -5,14 +5,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
-	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
-	"github.com/jucrouzet/grpcutils/pkg/remoteaddr"
+	"github.com/jucrouzet/grpcutils/internal/pkg/logfields"
 	"github.com/jucrouzet/grpcutils/pkg/requestid"
 )
 
@@ -21,6 +23,14 @@ type Logger struct {
 	fields     []Field
 	logger     *zap.Logger
 	serverName string
+	policies   map[string]logfields.Policy
+	plan       fieldPlan
+	accessLog  *accessLog
+	payloadLog *payloadLog
+	extractors []FieldExtractor
+
+	levels          *LevelController
+	debugLogTrusted func(ctx context.Context) bool
 }
 
 // Option is the Logger option functions type
@@ -31,16 +41,54 @@ type Field string
 
 const (
 	// FieldServerName adds the server name in log messages
-	FieldServerName = "server_name"
+	FieldServerName = logfields.ServerName
 	// FieldServerType adds the server type in log messages
-	FieldServerType = "server_type"
+	FieldServerType = logfields.ServerType
 	// FieldRemoteAddr adds the remote address of the caller in log messages
-	FieldRemoteAddr = "remote_addr"
+	FieldRemoteAddr = logfields.RemoteAddr
 	// FieldMethod adds the called method name in log messages
-	FieldMethod = "method"
+	FieldMethod = logfields.Method
 	// FieldRequestID adds the request unique correlation ID in log messages
 	// See github.com/jucrouzet/grpcutils/pkg/requestid
-	FieldRequestID = "requestid"
+	FieldRequestID = logfields.RequestID
+	// FieldGeoCountry adds the country of the caller's remote address in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/geoip
+	FieldGeoCountry = logfields.GeoCountry
+	// FieldASN adds the autonomous system number of the caller's remote address in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/geoip
+	FieldASN = logfields.ASN
+	// FieldTraceID adds the OpenTelemetry trace ID of the call in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/tracing
+	FieldTraceID = logfields.TraceID
+	// FieldSpanID adds the OpenTelemetry span ID of the call in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/tracing
+	FieldSpanID = logfields.SpanID
+	// FieldTarget adds the target of the client connection in client calls log messages
+	FieldTarget = "target"
+	// FieldAttempt adds the attempt number of calls, from the AttemptMetadataName incoming metadata,
+	// or outgoing metadata for client calls, in log messages
+	// See github.com/jucrouzet/grpcutils/pkg/retry
+	FieldAttempt = logfields.Attempt
+)
+
+// AttemptMetadataName is the name of the metadata holding the attempt number, starting at 1, of a
+// retried call.
+const AttemptMetadataName = logfields.AttemptMetadataName
+
+// FailurePolicy is the behaviour of the interceptors when the value of a field is unavailable, like
+// the remote address of calls over some in-process transports.
+type FailurePolicy = logfields.FailurePolicy
+
+const (
+	// FailureSkip omits unavailable fields from log messages, it is the default policy
+	FailureSkip = logfields.FailureSkip
+	// FailurePlaceholder adds unavailable fields to log messages with a placeholder value
+	FailurePlaceholder = logfields.FailurePlaceholder
+	// FailureFail fails calls with a codes.Internal error when the field is unavailable
+	FailureFail = logfields.FailureFail
+	// DefaultPlaceholder is the value of unavailable fields with FailurePlaceholder, unless set with
+	// WithFieldPlaceholder
+	DefaultPlaceholder = logfields.DefaultPlaceholder
 )
 
 var (
@@ -54,6 +102,24 @@ type contextValueKeyType string
 
 var contextValueKey = contextValueKeyType("github.com/jucrouzet/grpcutils/zaplogger value")
 
+// loggerHolder holds the request logger of a call, allowing to add fields to it during the call.
+type loggerHolder struct {
+	mu     sync.RWMutex
+	logger *zap.Logger
+}
+
+func (h *loggerHolder) get() *zap.Logger {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.logger
+}
+
+func (h *loggerHolder) with(fields ...zap.Field) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.logger = h.logger.With(fields...)
+}
+
 // GetFromContext returns the logger from a context that has been set in UnaryInterceptor or
 // StreamInterceptor.
 // If logger is not set in context and noopLoggerIfNotPresent is not specified or false
@@ -61,14 +127,28 @@ var contextValueKey = contextValueKeyType("github.com/jucrouzet/grpcutils/zaplog
 // If logger is not set in context and noopLoggerIfNotPresent is true, a noop logger is returned
 // and err can be ignored.
 func GetFromContext(ctx context.Context, noopLoggerIfNotPresent ...bool) (*zap.Logger, error) {
-	logger, ok := ctx.Value(contextValueKey).(*zap.Logger)
-	if !ok || logger == nil {
+	holder, ok := ctx.Value(contextValueKey).(*loggerHolder)
+	if !ok || holder == nil {
 		if len(noopLoggerIfNotPresent) > 0 && noopLoggerIfNotPresent[0] {
 			return zap.New(nil), nil
 		}
 		return nil, ErrNoLoggerInContext
 	}
-	return logger, nil
+	return holder.get(), nil
+}
+
+// AddFields adds fields to the request logger of a context that has been set in UnaryInterceptor
+// or StreamInterceptor, for the rest of the call : the loggers returned by later GetFromContext
+// calls and the access and payload logs have these fields.
+// It is safe for concurrent use, for instance by the goroutines of a stream handler.
+// If logger is not set in context, ErrNoLoggerInContext is returned.
+func AddFields(ctx context.Context, fields ...zap.Field) error {
+	holder, ok := ctx.Value(contextValueKey).(*loggerHolder)
+	if !ok || holder == nil {
+		return ErrNoLoggerInContext
+	}
+	holder.with(fields...)
+	return nil
 }
 
 // WithLogger specifies which uber/zap instance to use for logging.
@@ -93,6 +173,33 @@ func WithFields(fields ...Field) Option {
 	}
 }
 
+// WithFieldFailurePolicy sets the behaviour of the interceptors when the value of field is
+// unavailable.
+// If not set, FailureSkip is used.
+func WithFieldFailurePolicy(field Field, policy FailurePolicy) Option {
+	return func(l *Logger) error {
+		if !policy.Valid() {
+			return fmt.Errorf("invalid failure policy %d", policy)
+		}
+		p := l.policies[string(field)]
+		p.Failure = policy
+		l.policies[string(field)] = p
+		return nil
+	}
+}
+
+// WithFieldPlaceholder makes the interceptors use placeholder as value of field when it is
+// unavailable, setting its failure policy to FailurePlaceholder.
+func WithFieldPlaceholder(field Field, placeholder string) Option {
+	return func(l *Logger) error {
+		if placeholder == "" {
+			return errors.New("placeholder cannot be empty")
+		}
+		l.policies[string(field)] = logfields.Policy{Failure: FailurePlaceholder, Placeholder: placeholder}
+		return nil
+	}
+}
+
 // WithServerName sets the FieldServerName field value to log message.
 func WithServerName(serverName string) Option {
 	return func(l *Logger) error {
@@ -106,7 +213,9 @@ func WithServerName(serverName string) Option {
 
 // New creates a new instance of Logger with specified options
 func New(opts ...Option) (*Logger, error) {
-	l := &Logger{}
+	l := &Logger{
+		policies: make(map[string]logfields.Policy),
+	}
 	for _, opt := range opts {
 		if err := opt(l); err != nil {
 			return nil, fmt.Errorf("%w : %s", ErrInvalidOptionValue, err.Error())
@@ -119,7 +228,13 @@ func New(opts ...Option) (*Logger, error) {
 		}
 		l.logger = ll
 	}
-	if l.hasField(FieldServerName) && l.serverName != "" {
+	if l.levels != nil {
+		l.logger = l.logger.WithOptions(wrapLevelCore(l.levels.level))
+	} else if l.debugLogTrusted != nil {
+		l.logger = l.logger.WithOptions(wrapLevelCore(zapcore.InfoLevel))
+	}
+	l.plan = l.compilePlan()
+	if l.plan.serverName && l.serverName != "" {
 		l.logger = l.logger.With(zap.String(FieldServerName, l.serverName))
 	}
 	return l, nil
@@ -130,7 +245,26 @@ func (l *Logger) GetLogger() *zap.Logger {
 	return l.logger
 }
 
-// UnaryInterceptor returns a gRPC server unary interceptor that sets logger in call context
+// ForCall returns the logger of a call for components logging outside of its handler, like
+// interceptors rejecting it: the request logger of ctx if it has one (see GetFromContext), else
+// the zap logger with the method and request correlation identifier of the call.
+// It can be called on a nil Logger, returning a noop logger if ctx has no request logger.
+func (l *Logger) ForCall(ctx context.Context, fullMethod string) *zap.Logger {
+	if logger, err := GetFromContext(ctx); err == nil {
+		return logger
+	}
+	if l == nil {
+		return zap.NewNop()
+	}
+	logger := l.logger.With(zap.String(FieldMethod, fullMethod))
+	if id := requestid.GetFromContext(ctx); id != "" {
+		logger = logger.With(zap.String(FieldRequestID, id))
+	}
+	return logger
+}
+
+// UnaryInterceptor returns a gRPC server unary interceptor that sets logger in call context, and
+// logs finished calls if access log is enabled and payloads if payload log is enabled.
 func (l *Logger) UnaryInterceptor() grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
@@ -138,15 +272,34 @@ func (l *Logger) UnaryInterceptor() grpc.UnaryServerInterceptor {
 		infos *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		logger, err := l.getForUnary(ctx, infos)
+		logger, err := l.getForCall(ctx, infos.FullMethod, infos.Server)
 		if err != nil {
 			return nil, status.Error(codes.Internal, "failed setting request logger")
 		}
-		return handler(context.WithValue(ctx, contextValueKey, logger), req)
+		holder := &loggerHolder{logger: logger}
+		if l.accessLog == nil && l.payloadLog == nil {
+			return handler(context.WithValue(ctx, contextValueKey, holder), req)
+		}
+		var payloadFields []zap.Field
+		if l.payloadLog != nil {
+			payloadFields = l.callFields(ctx, infos.FullMethod)
+			l.payloadLog.log(logger, "request payload", req, payloadFields...)
+		}
+		start := time.Now()
+		res, err := handler(context.WithValue(ctx, contextValueKey, holder), req)
+		if l.payloadLog != nil && err == nil {
+			l.payloadLog.log(holder.get(), "response payload", res, payloadFields...)
+		}
+		if l.accessLog != nil {
+			l.logUnaryAccess(ctx, holder.get(), infos.FullMethod, start, req, res, err)
+		}
+		return res, err
 	}
 }
 
-// StreamInterceptor returns a gRPC server stream interceptor that sets logger in stream context
+// StreamInterceptor returns a gRPC server stream interceptor that sets logger in stream context,
+// and logs finished streams if access log is enabled and messages payloads if payload log is
+// enabled.
 func (l *Logger) StreamInterceptor() grpc.StreamServerInterceptor {
 	return func(
 		srv interface{},
@@ -154,28 +307,31 @@ func (l *Logger) StreamInterceptor() grpc.StreamServerInterceptor {
 		infos *grpc.StreamServerInfo,
 		handler grpc.StreamHandler,
 	) error {
-		logger, err := l.getForStream(stream.Context(), infos, srv)
+		logger, err := l.getForCall(stream.Context(), infos.FullMethod, srv)
 		if err != nil {
 			return status.Error(codes.Internal, "failed setting request logger")
 		}
-		ns := &utils.ServerStream{
-			ServerStream: stream,
-			Ctx:          context.WithValue(stream.Context(), contextValueKey, logger),
+		holder := &loggerHolder{logger: logger}
+		ctx := context.WithValue(stream.Context(), contextValueKey, holder)
+		ns := l.loggingStream(stream, ctx, holder, infos.FullMethod)
+		if l.accessLog == nil {
+			return handler(srv, ns)
 		}
-		return handler(srv, ns)
+		start := time.Now()
+		err = handler(srv, ns)
+		l.logStreamAccess(stream.Context(), holder.get(), infos.FullMethod, start, ns, err)
+		return err
 	}
 }
 
 func (l *Logger) addField(field Field) {
-	for _, f := range l.fields {
-		if f == field {
-			return
-		}
+	if !l.HasField(field) {
+		l.fields = append(l.fields, field)
 	}
-	l.fields = append(l.fields, field)
 }
 
-func (l *Logger) hasField(field Field) bool {
+// HasField returns whether field is added to log messages
+func (l *Logger) HasField(field Field) bool {
 	for _, f := range l.fields {
 		if f == field {
 			return true
@@ -184,48 +340,69 @@ func (l *Logger) hasField(field Field) bool {
 	return false
 }
 
-func (l *Logger) getForUnary(ctx context.Context, infos *grpc.UnaryServerInfo) (*zap.Logger, error) {
-	logger := l.GetLogger()
-	if l.hasField(FieldRemoteAddr) {
-		addr, err := remoteaddr.GetFromContext(ctx)
-		if err != nil {
-			return nil, err
+// fieldPlan is the field configuration of a Logger, compiled in New so that calls don't look it up.
+type fieldPlan struct {
+	extract    *logfields.Plan
+	serverName bool
+	method     bool
+	remoteAddr bool
+	requestID  bool
+	target     bool
+}
+
+func (l *Logger) compilePlan() fieldPlan {
+	keys := make([]string, 0, len(l.fields))
+	p := fieldPlan{}
+	for _, f := range l.fields {
+		keys = append(keys, string(f))
+		switch f {
+		case FieldServerName:
+			p.serverName = true
+		case FieldMethod:
+			p.method = true
+		case FieldRemoteAddr:
+			p.remoteAddr = true
+		case FieldRequestID:
+			p.requestID = true
+		case FieldTarget:
+			p.target = true
 		}
-		logger = logger.With(zap.String(FieldRemoteAddr, addr.String()))
-	}
-	if l.hasField(FieldMethod) {
-		logger = logger.With(zap.String(FieldMethod, infos.FullMethod))
-	}
-	if l.hasField(FieldServerType) {
-		logger = logger.With(zap.String(FieldServerType, fmt.Sprintf("%T", infos.Server)))
-	}
-	if l.hasField(FieldRequestID) && requestid.GetFromContext(ctx) != "" {
-		logger = logger.With(zap.String(FieldRequestID, requestid.GetFromContext(ctx)))
 	}
-	return logger, nil
+	p.extract = logfields.NewPlan(keys, l.policies)
+	return p
 }
 
-func (l *Logger) getForStream(
-	ctx context.Context,
-	infos *grpc.StreamServerInfo,
-	srv interface{},
-) (*zap.Logger, error) {
+// getForCall returns the request logger of a call, server being the service implementation.
+// Builtin and extracted fields are added with a single With, as each one clones the logger core.
+func (l *Logger) getForCall(ctx context.Context, fullMethod string, server interface{}) (*zap.Logger, error) {
+	var buf [logfields.MaxFields]logfields.Field
+	extracted, err := l.plan.extract.Extract(ctx, fullMethod, server, buf[:0])
+	if err != nil {
+		return nil, err
+	}
 	logger := l.GetLogger()
-	if l.hasField(FieldRemoteAddr) {
-		addr, err := remoteaddr.GetFromContext(ctx)
-		if err != nil {
-			return nil, err
+	if len(extracted) > 0 || len(l.extractors) > 0 {
+		fields := l.zapFields(ctx, fullMethod, extracted, make([]zap.Field, 0, len(extracted)+len(l.extractors)))
+		if len(fields) > 0 {
+			logger = logger.With(fields...)
 		}
-		logger = logger.With(zap.String(FieldRemoteAddr, addr.String()))
-	}
-	if l.hasField(FieldMethod) {
-		logger = logger.With(zap.String(FieldMethod, infos.FullMethod))
 	}
-	if l.hasField(FieldServerType) {
-		logger = logger.With(zap.String(FieldServerType, fmt.Sprintf("%T", srv)))
+	return l.withCallLevel(ctx, fullMethod, logger), nil
+}
+
+// zapFields appends the zap fields of extracted ones, then the ones of the field extractors, to
+// fields, and returns them.
+// It is the only encoding of extracted fields, so that server and client calls log them alike.
+func (l *Logger) zapFields(ctx context.Context, fullMethod string, extracted []logfields.Field, fields []zap.Field) []zap.Field {
+	for _, f := range extracted {
+		if f.IsUint {
+			fields = append(fields, zap.Uint(f.Key, f.Uint))
+		} else {
+			fields = append(fields, zap.String(f.Key, f.String))
+		}
 	}
-	if l.hasField(FieldRequestID) && requestid.GetFromContext(ctx) != "" {
-		logger = logger.With(zap.String(FieldRequestID, requestid.GetFromContext(ctx)))
+	for _, extractor := range l.extractors {
+		fields = append(fields, extractor(ctx, fullMethod)...)
 	}
-	return logger, nil
+	return fields
 }
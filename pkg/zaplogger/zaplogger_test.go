@@ -2,17 +2,25 @@ package zaplogger
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 
 	"github.com/jucrouzet/grpcutils/internal/pkg/foobar"
 	"github.com/jucrouzet/grpcutils/internal/pkg/utils"
+	"github.com/jucrouzet/grpcutils/pkg/geoip"
 	"github.com/jucrouzet/grpcutils/pkg/requestid"
 )
 
@@ -26,6 +34,30 @@ func TestGetFromContext(t *testing.T) {
 	assert.NotNil(t, l, "GetFromContext with a non-zapplogger context should return a logger")
 }
 
+func TestLogger_ForCall(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(WithLogger(zap.New(core)))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestid.MetadataName, "foo"))
+	l.ForCall(ctx, "/foobar.DummyService/Foo").Info("test")
+	if assert.Equal(t, 1, len(recordedLogs.All()), "ForCall() should return the logger without request logger") {
+		assert.Equal(t, map[string]interface{}{
+			FieldMethod:    "/foobar.DummyService/Foo",
+			FieldRequestID: "foo",
+		}, recordedLogs.All()[0].ContextMap(), "ForCall() should add call fields")
+	}
+
+	requestCore, requestLogs := observer.New(zapcore.DebugLevel)
+	ctx = context.WithValue(ctx, contextValueKey, &loggerHolder{logger: zap.New(requestCore)})
+	l.ForCall(ctx, "/foobar.DummyService/Foo").Info("test")
+	assert.Equal(t, 1, len(requestLogs.All()), "ForCall() should return the request logger")
+	assert.Equal(t, 1, len(recordedLogs.All()), "ForCall() should not use the logger with a request logger")
+
+	var nilLogger *Logger
+	nilLogger.ForCall(ctx, "/foobar.DummyService/Foo").Info("test")
+	assert.Equal(t, 2, len(requestLogs.All()), "ForCall() on a nil Logger should return the request logger")
+	assert.NotNil(t, nilLogger.ForCall(context.Background(), "/foobar.DummyService/Foo"), "ForCall() on a nil Logger should return a noop logger")
+}
+
 func TestWithLogger(t *testing.T) {
 	l, err := New(WithLogger(nil))
 	assert.ErrorIs(t, err, ErrInvalidOptionValue, "using a nil logger should return an ErrInvalidOptionValue")
@@ -323,3 +355,222 @@ func TestWithServerName(t *testing.T) {
 	assert.ErrorIs(t, err, ErrInvalidOptionValue, "using an empty server name should return an ErrInvalidOptionValue")
 	assert.Nil(t, l, "using an empty server name should return a nil logger")
 }
+
+type geoDatabase map[string]*geoip.Record
+
+func (g geoDatabase) Lookup(ip net.IP) (*geoip.Record, error) {
+	return g[ip.String()], nil
+}
+
+type dummyLoggerGeoIP struct {
+	foobar.UnimplementedDummyServiceServer
+}
+
+func (d *dummyLoggerGeoIP) Foo(ctx context.Context, in *foobar.Empty) (*foobar.Empty, error) {
+	logger, _ := GetFromContext(ctx, true)
+	logger.Debug("test")
+	return &foobar.Empty{}, nil
+}
+
+func TestGeoIPFields(t *testing.T) {
+	e, _ := geoip.New(geoip.WithDatabase(geoDatabase{
+		"192.0.2.1": {Country: "FR", ASN: 64496},
+		"192.0.2.2": {Country: "DE"},
+	}))
+	withPeer := func(ip string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip)}}), req)
+		}
+	}
+	call := func(ip string, fields ...Field) map[string]interface{} {
+		core, recordedLogs := observer.New(zapcore.DebugLevel)
+		l, _ := New(WithLogger(zap.New(core)), WithFields(fields...))
+		opts := []grpc.ServerOption{
+			grpc.ChainUnaryInterceptor(withPeer(ip), e.UnaryInterceptor(), l.UnaryInterceptor()),
+		}
+		utils.TestCallFoo(t, &dummyLoggerGeoIP{}, nil, opts)
+		if !assert.Equal(t, 1, len(recordedLogs.All()), "there should be a log message") {
+			return nil
+		}
+		return recordedLogs.All()[0].ContextMap()
+	}
+
+	fields := call("192.0.2.1", FieldGeoCountry, FieldASN)
+	assert.Equal(t, "FR", fields[FieldGeoCountry], "geo country field should be set")
+	assert.Equal(t, uint64(64496), fields[FieldASN], "ASN field should be set")
+
+	fields = call("192.0.2.2", FieldGeoCountry, FieldASN)
+	assert.Equal(t, "DE", fields[FieldGeoCountry], "geo country field should be set")
+	assert.NotContains(t, fields, FieldASN, "ASN field should not be set when unknown")
+
+	fields = call("192.0.2.3", FieldGeoCountry, FieldASN)
+	assert.NotContains(t, fields, FieldGeoCountry, "geo country field should not be set when remote address is unknown")
+
+	fields = call("192.0.2.1", FieldASN)
+	assert.NotContains(t, fields, FieldGeoCountry, "geo country field should not be set when not in fields")
+	assert.Equal(t, uint64(64496), fields[FieldASN], "ASN field should be set")
+}
+
+func TestTraceFields(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(WithLogger(zap.New(core)), WithFields(FieldTraceID, FieldSpanID))
+	call := func(ctx context.Context) map[string]interface{} {
+		_, _ = l.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/foobar.DummyService/Foo"}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			logger, _ := GetFromContext(ctx)
+			logger.Info("message")
+			return nil, nil
+		})
+		logs := recordedLogs.TakeAll()
+		if !assert.Equal(t, 1, len(logs), "there should be a log message") {
+			return nil
+		}
+		return logs[0].ContextMap()
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	})
+	fields := call(trace.ContextWithSpanContext(context.Background(), sc))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields[FieldTraceID], "trace ID field should be set")
+	assert.Equal(t, "00f067aa0ba902b7", fields[FieldSpanID], "span ID field should be set")
+
+	fields = call(context.Background())
+	assert.NotContains(t, fields, FieldTraceID, "trace ID field should not be set without span")
+	assert.NotContains(t, fields, FieldSpanID, "span ID field should not be set without span")
+}
+
+func TestAttemptField(t *testing.T) {
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(WithLogger(zap.New(core)), WithFields(FieldAttempt))
+	call := func(ctx context.Context) map[string]interface{} {
+		_, _ = l.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/foobar.DummyService/Foo"}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			logger, _ := GetFromContext(ctx)
+			logger.Info("message")
+			return nil, nil
+		})
+		logs := recordedLogs.TakeAll()
+		if !assert.Equal(t, 1, len(logs), "there should be a log message") {
+			return nil
+		}
+		return logs[0].ContextMap()
+	}
+
+	fields := call(metadata.NewIncomingContext(context.Background(), metadata.Pairs(AttemptMetadataName, "2")))
+	assert.Equal(t, uint64(2), fields[FieldAttempt], "attempt field should be set from incoming metadata")
+
+	fields = call(metadata.NewIncomingContext(context.Background(), metadata.Pairs(AttemptMetadataName, "second")))
+	assert.NotContains(t, fields, FieldAttempt, "attempt field should not be set with an invalid attempt number")
+
+	fields = call(context.Background())
+	assert.NotContains(t, fields, FieldAttempt, "attempt field should not be set without attempt metadata")
+}
+
+type dummyLoggerAddFields struct {
+	foobar.UnimplementedDummyServiceServer
+}
+
+func (d *dummyLoggerAddFields) Foo(ctx context.Context, in *foobar.Empty) (*foobar.Empty, error) {
+	logger, _ := GetFromContext(ctx)
+	logger.Info("before")
+	_ = AddFields(ctx, zap.String("order_id", "42"))
+	logger, _ = GetFromContext(ctx)
+	logger.Info("after")
+	return &foobar.Empty{}, nil
+}
+
+func (d *dummyLoggerAddFields) FooS(s foobar.DummyService_FooSServer) error {
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func(i int) {
+			_ = AddFields(s.Context(), zap.Int(fmt.Sprintf("field_%d", i), i))
+			done <- struct{}{}
+		}(i)
+	}
+	<-done
+	<-done
+	for {
+		_, err := s.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestAddFields(t *testing.T) {
+	assert.ErrorIs(t, AddFields(context.Background(), zap.String("foo", "bar")), ErrNoLoggerInContext, "AddFields with a non-zapplogger context should return a ErrNoLoggerInContext error")
+
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ := New(WithLogger(zap.New(core)), WithAccessLog())
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(l.UnaryInterceptor()),
+		grpc.StreamInterceptor(l.StreamInterceptor()),
+	}
+	utils.TestCallFoo(t, &dummyLoggerAddFields{}, nil, opts)
+	logs := recordedLogs.TakeAll()
+	if assert.Equal(t, 3, len(logs), "handler and access log should log") {
+		assert.NotContains(t, logs[0].ContextMap(), "order_id", "AddFields() should not change previous loggers")
+		assert.Equal(t, "42", logs[1].ContextMap()["order_id"], "AddFields() should add fields to following loggers")
+		assert.Equal(t, "42", logs[2].ContextMap()["order_id"], "AddFields() should add fields to access log")
+	}
+
+	utils.TestCallFooS(t, &dummyLoggerAddFields{}, nil, opts)
+	logs = recordedLogs.TakeAll()
+	if assert.Equal(t, 1, len(logs), "access log should log") {
+		assert.Equal(t, int64(0), logs[0].ContextMap()["field_0"], "AddFields() should add fields concurrently")
+		assert.Equal(t, int64(1), logs[0].ContextMap()["field_1"], "AddFields() should add fields concurrently")
+	}
+}
+
+func TestWithFieldFailurePolicy(t *testing.T) {
+	l, err := New(WithFieldFailurePolicy(FieldRemoteAddr, FailurePolicy(42)))
+	assert.Nil(t, l, "WithFieldFailurePolicy() should not return a logger with an invalid policy")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithFieldFailurePolicy() should return a ErrInvalidOptionValue error with an invalid policy")
+	l, err = New(WithFieldPlaceholder(FieldRemoteAddr, ""))
+	assert.Nil(t, l, "WithFieldPlaceholder() should not return a logger with an empty placeholder")
+	assert.ErrorIs(t, err, ErrInvalidOptionValue, "WithFieldPlaceholder() should return a ErrInvalidOptionValue error with an empty placeholder")
+
+	// In-process calls, without peer
+	call := func(l *Logger, recordedLogs *observer.ObservedLogs) (map[string]interface{}, error) {
+		_, err := l.UnaryInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/foobar.DummyService/Foo"}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			logger, _ := GetFromContext(ctx)
+			logger.Info("test")
+			return nil, nil
+		})
+		logs := recordedLogs.TakeAll()
+		if len(logs) == 0 {
+			return nil, err
+		}
+		return logs[0].ContextMap(), err
+	}
+	core, recordedLogs := observer.New(zapcore.DebugLevel)
+	l, _ = New(WithLogger(zap.New(core)), WithFields(FieldRemoteAddr, FieldRequestID, FieldMethod))
+	fields, err := call(l, recordedLogs)
+	assert.Nil(t, err, "unavailable fields should not fail calls by default")
+	assert.NotContains(t, fields, FieldRemoteAddr, "unavailable fields should be skipped by default")
+	assert.NotContains(t, fields, FieldRequestID, "unavailable fields should be skipped by default")
+	assert.Equal(t, "/foobar.DummyService/Foo", fields[FieldMethod], "available fields should be logged")
+
+	l, _ = New(
+		WithLogger(zap.New(core)),
+		WithFields(FieldRemoteAddr, FieldRequestID),
+		WithFieldFailurePolicy(FieldRemoteAddr, FailurePlaceholder),
+		WithFieldPlaceholder(FieldRequestID, "none"),
+	)
+	fields, err = call(l, recordedLogs)
+	assert.Nil(t, err, "unavailable fields with placeholder policy should not fail calls")
+	assert.Equal(t, DefaultPlaceholder, fields[FieldRemoteAddr], "unavailable fields should be logged with default placeholder")
+	assert.Equal(t, "none", fields[FieldRequestID], "unavailable fields should be logged with placeholder")
+
+	l, _ = New(WithLogger(zap.New(core)), WithFields(FieldRemoteAddr), WithFieldFailurePolicy(FieldRemoteAddr, FailureFail))
+	_, err = call(l, recordedLogs)
+	assert.Equal(t, codes.Internal, status.Code(err), "unavailable fields with fail policy should fail calls")
+
+	// Calls over bufconn have a remote address
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(l.UnaryInterceptor())}
+	_, _, _, err = utils.TestCallFoo(t, &dummyAccessLog{}, nil, opts)
+	assert.Nil(t, err, "available fields with fail policy should not fail calls")
+}